@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+
+	"github.com/flemzord/sclaw/internal/agent"
+	"github.com/flemzord/sclaw/internal/budget"
+	"github.com/flemzord/sclaw/internal/config"
+)
+
+// budgetLedgerFile is the name of the token ledger in the data directory.
+const budgetLedgerFile = "budget.json"
+
+// dayBudgetKey is the ledger key of the spending shared by all
+// conversations.
+const dayBudgetKey = "all"
+
+// tokenBudgets are the token budgets of the configuration, backed by a
+// ledger kept across runs and restarts.
+type tokenBudgets struct {
+	ledger *budget.Ledger
+	cfg    config.BudgetsConfig
+}
+
+// openBudgets opens the ledger of cfg in dataDir. It returns nil when no
+// budget is configured.
+func openBudgets(cfg config.BudgetsConfig, dataDir string, logger *slog.Logger) (*tokenBudgets, error) {
+	if cfg.Session <= 0 && cfg.Day <= 0 {
+		return nil, nil
+	}
+	ledger, err := budget.Open(filepath.Join(dataDir, budgetLedgerFile), logger)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenBudgets{ledger: ledger, cfg: cfg}, nil
+}
+
+// forSession returns the budgets charged by a turn of the conversation
+// session: its own and the daily one. It returns nil when b is nil.
+func (b *tokenBudgets) forSession(session string) []agent.Budget {
+	if b == nil {
+		return nil
+	}
+	var out []agent.Budget
+	if b.cfg.Session > 0 {
+		out = append(out, b.ledger.Limit("session:"+session, budget.PeriodTotal, b.cfg.Session))
+	}
+	if b.cfg.Day > 0 {
+		out = append(out, b.ledger.Limit(dayBudgetKey, budget.PeriodDay, b.cfg.Day))
+	}
+	return out
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/agent"
+	"github.com/flemzord/sclaw/internal/channel/stdio"
+	"github.com/flemzord/sclaw/internal/config"
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/hook"
+	"github.com/flemzord/sclaw/internal/metrics"
+	"github.com/flemzord/sclaw/internal/prompt"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/skill"
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/spf13/cobra"
+)
+
+// chatApprovalTimeout bounds how long a tool approval prompt waits.
+const chatApprovalTimeout = 5 * time.Minute
+
+func chatCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "chat",
+		Short: "Chat with the configured agent in the terminal",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfgPath, err := configFlag(cmd)
+			if err != nil {
+				return err
+			}
+
+			cfg, err := config.Load(cfgPath)
+			if err != nil {
+				return err
+			}
+			if err := config.Validate(cfg); err != nil {
+				return err
+			}
+
+			// Keep the terminal for the conversation: only warnings are logged.
+			logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+				Level: slog.LevelWarn,
+			}))
+
+			appCtx := core.NewAppContext(logger, defaultDataDir(), defaultWorkspace())
+			appCtx = appCtx.WithModuleConfigs(cfg.Modules)
+
+			app := core.NewApp(appCtx)
+			if err := app.LoadModules(config.Resolve(cfg)); err != nil {
+				return err
+			}
+			defer app.Stop()
+
+			app.SetAdminAddr(cfg.Admin.Addr)
+			if err := app.StartAdmin(); err != nil {
+				return err
+			}
+
+			chain, err := buildChain(app.Modules(), cfg.Providers, appCtx.Metrics, logger)
+			if err != nil {
+				return err
+			}
+			reg, err := buildRegistry(app.Modules())
+			if err != nil {
+				return err
+			}
+			hooks, err := hook.FromModules(app.Modules(), cfg.Hooks.Order)
+			if err != nil {
+				return err
+			}
+			skills, err := skill.Load(appCtx.Workspace)
+			if err != nil {
+				return err
+			}
+			budgets, err := openBudgets(cfg.Budgets, appCtx.DataDir, logger)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			chain.Start(ctx)
+			defer chain.Stop()
+
+			if _, err := chain.GetProvider(provider.RolePrimary); err != nil {
+				return err
+			}
+			p := chain.ForRole(provider.RolePrimary)
+
+			out := cmd.OutOrStdout()
+			lines := stdio.ReadLines(cmd.InOrStdin())
+
+			executor := agent.NewToolExecutor(agent.ToolExecutorConfig{
+				Registry:        reg,
+				PolicyCtx:       tool.PolicyContextDM,
+				Elevated:        tool.NewElevatedState(),
+				Requester:       &stdio.Approver{Out: out, Answers: lines},
+				ApprovalTimeout: chatApprovalTimeout,
+				Env: tool.ExecutionEnv{
+					Workspace: appCtx.Workspace,
+					DataDir:   appCtx.DataDir,
+				},
+				Metrics: appCtx.Metrics,
+			})
+
+			system, _ := cmd.Flags().GetString("system")
+			session := &chatSession{
+				loop:    agent.NewLoop(p, executor, loopConfig(chain, appCtx.Metrics)),
+				chain:   chain,
+				out:     out,
+				lines:   lines,
+				system:  system,
+				tools:   agent.ToolDefinitions(reg),
+				hooks:   hooks,
+				skills:  skills,
+				prompt:  prompt.DefaultEngine(appCtx.Workspace, time.Local),
+				budgets: budgets,
+				session: newChatSessionID(),
+			}
+
+			fmt.Fprintf(out, "sclaw chat — model %s, %d tool(s), %d skill(s). Type /reset to clear history, /providers for provider status, /exit to quit.\n",
+				p.ModelName(), len(session.tools), len(skills))
+			return session.run(ctx)
+		},
+	}
+	cmd.Flags().StringP("config", "c", "", "Path to configuration file")
+	cmd.Flags().String("system", "", "Persona for the conversation when the workspace has no "+prompt.DefaultPersonaFile)
+	return cmd
+}
+
+// buildChain assembles a provider chain from the loaded modules that
+// implement provider.Provider, in load order, balancing and hedging each
+// role as configured, and recording its calls in reg. Streams fail over
+// mid-way; the chat prints a marker when partial output is discarded.
+func buildChain(mods []core.Module, cfg config.ProvidersConfig, reg *metrics.Registry, logger *slog.Logger) (*provider.Chain, error) {
+	var entries []provider.ChainEntry
+	for _, mod := range mods {
+		p, ok := mod.(provider.Provider)
+		if !ok {
+			continue
+		}
+		entries = append(entries, provider.EntryFor(string(mod.ModuleInfo().ID), p))
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: configure at least one provider module", provider.ErrNoProvider)
+	}
+	opts := []provider.ChainOption{
+		provider.WithLogger(logger),
+		provider.WithStreamFailover(provider.StreamFailoverReset),
+		provider.WithMetrics(reg),
+	}
+	for role, name := range cfg.Strategies {
+		s, err := provider.ParseStrategy(name)
+		if err != nil {
+			return nil, fmt.Errorf("providers.strategies.%s: %w", role, err)
+		}
+		opts = append(opts, provider.WithStrategy(provider.Role(role), s))
+	}
+	for role, delay := range cfg.Hedging {
+		if delay < 0 {
+			return nil, fmt.Errorf("providers.hedging.%s: delay must not be negative", role)
+		}
+		opts = append(opts, provider.WithHedging(provider.Role(role), delay))
+	}
+	return provider.NewChain(entries, opts...)
+}
+
+// buildRegistry registers the loaded modules that implement tool.Tool.
+func buildRegistry(mods []core.Module) (*tool.Registry, error) {
+	reg := tool.NewRegistry()
+	for _, mod := range mods {
+		t, ok := mod.(tool.Tool)
+		if !ok {
+			continue
+		}
+		if err := reg.Register(t); err != nil {
+			return nil, fmt.Errorf("registering tool module %s: %w", mod.ModuleInfo().ID, err)
+		}
+	}
+	return reg, nil
+}
+
+// chatSession is a terminal conversation with a single agent loop.
+type chatSession struct {
+	loop    *agent.Loop
+	chain   *provider.Chain
+	out     io.Writer
+	lines   <-chan string
+	system  string
+	tools   []provider.ToolDefinition
+	hooks   *hook.Pipeline
+	skills  []skill.Skill
+	prompt  *prompt.Engine
+	budgets *tokenBudgets
+	session string // identifies the conversation to its budget
+	history []provider.LLMMessage
+}
+
+// newChatSessionID returns an identifier for a new terminal conversation.
+func newChatSessionID() string {
+	return "chat/" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// run reads user lines until EOF, /exit or ctx cancellation.
+func (s *chatSession) run(ctx context.Context) error {
+	for {
+		fmt.Fprint(s.out, "> ")
+
+		var line string
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(s.out)
+			return nil
+		case l, ok := <-s.lines:
+			if !ok {
+				fmt.Fprintln(s.out)
+				return nil
+			}
+			line = strings.TrimSpace(l)
+		}
+
+		switch line {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return nil
+		case "/reset":
+			s.history = nil
+			s.session = newChatSessionID()
+			fmt.Fprintln(s.out, "History cleared.")
+			continue
+		case "/providers":
+			s.printProviders()
+			continue
+		}
+
+		if err := s.turn(ctx, line); err != nil {
+			fmt.Fprintf(s.out, "error: %v\n", err)
+		}
+	}
+}
+
+// printProviders prints the health and call statistics of each provider
+// of the chain. KEYS counts the API keys not cooling down.
+func (s *chatSession) printProviders() {
+	now := time.Now()
+	w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tROLE\tMODEL\tSTATE\tFAILURES\tREQUESTS\tERRORS\tP50\tP90\tP99\tKEYS")
+	for _, e := range s.chain.Snapshot() {
+		state := string(e.State)
+		if e.CooldownUntil.After(now) {
+			state += fmt.Sprintf(" (%s left)", e.CooldownUntil.Sub(now).Round(time.Second))
+		}
+		keys := "-"
+		if len(e.Keys) > 0 {
+			available := 0
+			for _, k := range e.Keys {
+				if !k.CooldownUntil.After(now) {
+					available++
+				}
+			}
+			keys = fmt.Sprintf("%d/%d", available, len(e.Keys))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n",
+			e.Name, e.Role, e.Model, state, e.Failures, e.Requests, e.Errors,
+			e.LatencyP50.Round(time.Millisecond), e.LatencyP90.Round(time.Millisecond), e.LatencyP99.Round(time.Millisecond),
+			keys)
+	}
+	_ = w.Flush()
+}
+
+// turn streams one agent run, printing text as it arrives, tool markers
+// and a usage line. History is only extended when the run completes.
+func (s *chatSession) turn(ctx context.Context, text string) error {
+	msgs := append(slices.Clip(s.history), provider.LLMMessage{
+		Role:    provider.MessageRoleUser,
+		Content: text,
+	})
+
+	events, err := s.loop.RunStream(ctx, agent.Request{
+		Messages:     msgs,
+		SystemPrompt: s.system,
+		Tools:        s.tools,
+		Budgets:      s.budgets.forSession(s.session),
+		Hooks:        s.hooks,
+		Skills:       s.skills,
+		Prompt:       s.prompt,
+	})
+	if err != nil {
+		return err
+	}
+
+	var usage provider.TokenUsage
+	var runErr error
+	for ev := range events {
+		switch ev.Type {
+		case agent.StreamEventText:
+			fmt.Fprint(s.out, ev.Content)
+		case agent.StreamEventToolStart:
+			fmt.Fprintf(s.out, "\n[tool_start] %s %s\n", ev.ToolCall.Name, ev.ToolCall.Arguments)
+		case agent.StreamEventToolEnd:
+			status := "ok"
+			if ev.ToolCall.Output.IsError {
+				status = "error"
+			}
+			fmt.Fprintf(s.out, "[tool_end] %s %s (%s)\n",
+				ev.ToolCall.Name, status, ev.ToolCall.Duration.Round(time.Millisecond))
+		case agent.StreamEventReset:
+			fmt.Fprintln(s.out, "\n[reset] provider failed mid-stream, restarting")
+		case agent.StreamEventUsage:
+			usage.PromptTokens += ev.Usage.PromptTokens
+			usage.CompletionTokens += ev.Usage.CompletionTokens
+			usage.TotalTokens += ev.Usage.TotalTokens
+		case agent.StreamEventDone:
+			if ev.History != nil {
+				s.history = ev.History
+			} else {
+				s.history = append(msgs, ev.Messages...)
+			}
+		case agent.StreamEventError:
+			runErr = ev.Err
+		}
+	}
+
+	fmt.Fprintln(s.out)
+	fmt.Fprintf(s.out, "[usage] prompt=%d completion=%d total=%d\n",
+		usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+	return runErr
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/agent"
+	"github.com/flemzord/sclaw/internal/channel/stdio"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/internal/tool/tooltest"
+)
+
+// newChatSession returns a chat session served by p through a chain,
+// reading the lines of input and writing to the returned buffer. The
+// session has one tool, "lookup", that runs without approval.
+func newChatSession(t *testing.T, p *providertest.MockProvider, input string) (*chatSession, *bytes.Buffer) {
+	t.Helper()
+	if p.ModelNameFunc == nil {
+		p.ModelNameFunc = func() string { return "test" }
+	}
+	if p.ContextWindowSizeFunc == nil {
+		p.ContextWindowSizeFunc = func() int { return 4096 }
+	}
+	chain, err := provider.NewChain([]provider.ChainEntry{provider.EntryFor("provider.test", p)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := tool.NewRegistry()
+	if err := reg.Register(&tooltest.MockTool{
+		NameFunc:          func() string { return "lookup" },
+		DefaultPolicyFunc: func() tool.ApprovalLevel { return tool.ApprovalAllow },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	executor := agent.NewToolExecutor(agent.ToolExecutorConfig{
+		Registry:  reg,
+		PolicyCtx: tool.PolicyContextDM,
+		Elevated:  tool.NewElevatedState(),
+	})
+
+	out := &bytes.Buffer{}
+	return &chatSession{
+		loop:    agent.NewLoop(chain.ForRole(provider.RolePrimary), executor, agent.LoopConfig{}),
+		chain:   chain,
+		out:     out,
+		lines:   stdio.ReadLines(strings.NewReader(input)),
+		tools:   agent.ToolDefinitions(reg),
+		session: newChatSessionID(),
+	}, out
+}
+
+// streamOf returns a stream delivering chunks.
+func streamOf(chunks ...provider.StreamChunk) <-chan provider.StreamChunk {
+	ch := make(chan provider.StreamChunk, len(chunks))
+	for _, c := range chunks {
+		ch <- c
+	}
+	close(ch)
+	return ch
+}
+
+func TestChatSession_Commands(t *testing.T) {
+	t.Parallel()
+
+	p := &providertest.MockProvider{}
+	s, out := newChatSession(t, p, "\n/providers\n/reset\n/exit\nnever sent\n")
+	s.history = []provider.LLMMessage{{Role: provider.MessageRoleUser, Content: "old"}}
+
+	if err := s.run(context.Background()); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if s.history != nil {
+		t.Errorf("history = %v, want cleared by /reset", s.history)
+	}
+	if p.StreamCalls != 0 {
+		t.Errorf("stream calls = %d, want none", p.StreamCalls)
+	}
+	for _, want := range []string{"PROVIDER", "provider.test", "History cleared."} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestChatSession_Turn(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var requests []provider.CompletionRequest
+	p := &providertest.MockProvider{
+		StreamFunc: func(_ context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			requests = append(requests, req)
+			if len(requests) == 1 {
+				return streamOf(
+					provider.StreamChunk{ToolCalls: []provider.ToolCall{{ID: "c1", Name: "lookup", Arguments: []byte(`{"q":"go"}`)}}},
+					provider.StreamChunk{FinishReason: provider.FinishReasonToolUse, Usage: &provider.TokenUsage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}},
+				), nil
+			}
+			return streamOf(
+				provider.StreamChunk{Content: "found "},
+				provider.StreamChunk{Content: "it", FinishReason: provider.FinishReasonStop, Usage: &provider.TokenUsage{PromptTokens: 20, CompletionTokens: 3, TotalTokens: 23}},
+			), nil
+		},
+	}
+	s, out := newChatSession(t, p, "search go\n")
+
+	// EOF ends the session.
+	if err := s.run(context.Background()); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"\n[tool_start] lookup {\"q\":\"go\"}\n",
+		"[tool_end] lookup ok (",
+		"found it\n",
+		"[usage] prompt=30 completion=5 total=35\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	// The user line, the tool call, its result and the reply.
+	if len(s.history) != 4 || s.history[0].Content != "search go" || s.history[3].Content != "found it" {
+		t.Errorf("history = %+v, want the completed turn", s.history)
+	}
+}
+
+func TestChatSession_TurnError(t *testing.T) {
+	t.Parallel()
+
+	p := &providertest.MockProvider{
+		StreamFunc: func(context.Context, provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+			return nil, errors.New("bad request")
+		},
+	}
+	s, out := newChatSession(t, p, "hello\n")
+
+	if err := s.run(context.Background()); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "error: ") || !strings.Contains(out.String(), "bad request") {
+		t.Errorf("output = %q, want the error printed", out)
+	}
+	if s.history != nil {
+		t.Errorf("history = %v, want unchanged after a failed turn", s.history)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/agent"
+	"github.com/flemzord/sclaw/internal/channel"
+	"github.com/flemzord/sclaw/internal/config"
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/hook"
+	"github.com/flemzord/sclaw/internal/memory"
+	"github.com/flemzord/sclaw/internal/metrics"
+	"github.com/flemzord/sclaw/internal/prompt"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/router"
+	"github.com/flemzord/sclaw/internal/skill"
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// routerCloseTimeout bounds how long shutdown waits for in-flight turns.
+const routerCloseTimeout = 30 * time.Second
+
+// inboxAttacher is implemented by modules that push inbound messages,
+// like channels and scheduled agent turns.
+type inboxAttacher interface {
+	Attach(inbox channel.Inbox)
+}
+
+// attachRouter wires the loaded modules to a router and attaches it as
+// the inbox of every module that takes one. The router's agent is served
+// by the chain of the provider modules, started with ctx, and uses the
+// tool modules; hook modules run in the order of cfg.Hooks, turns are
+// charged to the budgets of cfg.Budgets, replies go out through the
+// channel modules and history is kept in the first session store module,
+// if any. It returns nil when no module takes an inbox. It must be called before the App starts.
+func attachRouter(ctx context.Context, mods []core.Module, cfg *config.Config, appCtx *core.AppContext, logger *slog.Logger) (*router.Router, error) {
+	dispatcher := channel.NewDispatcher()
+	var attachers []inboxAttacher
+	var store memory.SessionStore
+	for _, mod := range mods {
+		if ch, ok := mod.(channel.Channel); ok {
+			if err := dispatcher.Add(ch); err != nil {
+				return nil, err
+			}
+		}
+		if a, ok := mod.(inboxAttacher); ok {
+			attachers = append(attachers, a)
+		}
+		if s, ok := mod.(memory.SessionStore); ok && store == nil {
+			store = s
+		}
+	}
+	if len(attachers) == 0 {
+		return nil, nil
+	}
+
+	chain, err := buildChain(mods, cfg.Providers, appCtx.Metrics, logger)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := chain.GetProvider(provider.RolePrimary); err != nil {
+		return nil, err
+	}
+	reg, err := buildRegistry(mods)
+	if err != nil {
+		return nil, err
+	}
+	hooks, err := hook.FromModules(mods, cfg.Hooks.Order)
+	if err != nil {
+		return nil, err
+	}
+	skills, err := skill.Load(appCtx.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	budgets, err := openBudgets(cfg.Budgets, appCtx.DataDir, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := router.New(router.Config{
+		Resolver: router.StaticResolver(&router.Agent{
+			ID:       "main",
+			Provider: chain.ForRole(provider.RolePrimary),
+			Tools:    reg,
+			Env: tool.ExecutionEnv{
+				Workspace: appCtx.Workspace,
+				DataDir:   appCtx.DataDir,
+			},
+			Prompt: prompt.DefaultEngine(appCtx.Workspace, time.Local),
+			Skills: skills,
+			Loop:   loopConfig(chain, appCtx.Metrics),
+		}),
+		Sender:    dispatcher,
+		Approvals: dispatcher.Approvals,
+		Budgets: func(msg *message.InboundMessage) []agent.Budget {
+			return budgets.forSession(router.KeyFromInbound(msg).String())
+		},
+		Hooks:   hooks,
+		Store:   store,
+		Metrics: appCtx.Metrics,
+		Logger:  logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chain.Start(ctx)
+	for _, a := range attachers {
+		a.Attach(r)
+	}
+	return r, nil
+}
+
+// closeRouter waits for the in-flight turns of r, if not nil, for up to
+// routerCloseTimeout.
+func closeRouter(r *router.Router, logger *slog.Logger) {
+	if r == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), routerCloseTimeout)
+	defer cancel()
+	if err := r.Close(ctx); err != nil {
+		logger.Error("router close error", "error", err)
+	}
+}
+
+// loopConfig returns the agent loop configuration recording in reg.
+// Old turns are summarized with the internal provider of chain when one
+// is configured; otherwise they are truncated.
+func loopConfig(chain *provider.Chain, reg *metrics.Registry) agent.LoopConfig {
+	cfg := agent.LoopConfig{Metrics: reg}
+	if _, err := chain.GetProvider(provider.RoleInternal); err == nil {
+		cfg.Compaction.Compactor = agent.SummaryCompactor{Provider: chain.ForRole(provider.RoleInternal)}
+	}
+	return cfg
+}
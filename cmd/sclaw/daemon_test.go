@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/budget"
+	"github.com/flemzord/sclaw/internal/channel/loopback"
+	"github.com/flemzord/sclaw/internal/config"
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/cron"
+	"github.com/flemzord/sclaw/internal/hook"
+	"github.com/flemzord/sclaw/internal/hook/hooktest"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+	"github.com/flemzord/sclaw/internal/router"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// providerModule is a provider loaded as a module.
+type providerModule struct {
+	*providertest.MockProvider
+}
+
+func (providerModule) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{ID: "provider.test"}
+}
+
+func newProviderModule() providerModule {
+	return providerModule{&providertest.MockProvider{
+		CompleteFunc: func(_ context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+			return provider.CompletionResponse{Content: "report: " + req.Messages[len(req.Messages)-1].Content}, nil
+		},
+		ContextWindowSizeFunc: func() int { return 4096 },
+		ModelNameFunc:         func() string { return "test" },
+		HealthCheckFunc:       func(context.Context) error { return nil },
+	}}
+}
+
+// newAgentTurns returns a scheduled turn asking for the daily report in
+// chat 42 of the loopback channel.
+func newAgentTurns(t *testing.T) *cron.AgentTurns {
+	t.Helper()
+	turns := &cron.AgentTurns{Turns: []cron.AgentTurn{{
+		Name:     "brief",
+		Schedule: "@daily",
+		Prompt:   "daily report",
+		Channel:  loopback.ModuleID,
+		ChatID:   "42",
+	}}}
+	if err := turns.Provision(nil); err != nil {
+		t.Fatal(err)
+	}
+	return turns
+}
+
+// nextReply returns the next message sent through ch.
+func nextReply(t *testing.T, ch *loopback.Channel) message.OutboundMessage {
+	t.Helper()
+	select {
+	case reply := <-ch.Outbox():
+		return reply
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reply posted")
+		return message.OutboundMessage{}
+	}
+}
+
+func TestAttachRouter_AgentTurn(t *testing.T) {
+	t.Parallel()
+
+	turns := newAgentTurns(t)
+	ch := loopback.New()
+	hk := &hooktest.MockHook{On: []hook.Point{hook.PointInbound, hook.PointOutbound}}
+
+	logger := slog.New(slog.DiscardHandler)
+	appCtx := core.NewAppContext(logger, t.TempDir(), t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, err := attachRouter(ctx, []core.Module{newProviderModule(), ch, turns, hk}, &config.Config{}, appCtx, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil {
+		t.Fatal("attachRouter() = nil, want a router for the inbox modules")
+	}
+	defer closeRouter(r, logger)
+
+	if err := turns.Jobs()[0].Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if reply := nextReply(t, ch); reply.Chat.ID != "42" || reply.TextContent() != "report: daily report" {
+		t.Errorf("reply = %q to chat %q, want the agent's report to 42", reply.TextContent(), reply.Chat.ID)
+	} else if reply.ReplyToID != "" {
+		t.Errorf("reply ReplyToID = %q, want none for a scheduled prompt", reply.ReplyToID)
+	}
+	if got := hk.Seen(); !slices.Equal(got, []hook.Point{hook.PointInbound, hook.PointOutbound}) {
+		t.Errorf("hook points = %v, want inbound and outbound", got)
+	}
+}
+
+func TestAttachRouter_DayBudget(t *testing.T) {
+	t.Parallel()
+
+	turns := newAgentTurns(t)
+	ch := loopback.New()
+	p := newProviderModule()
+	p.CompleteFunc = func(context.Context, provider.CompletionRequest) (provider.CompletionResponse, error) {
+		return provider.CompletionResponse{Content: "report", Usage: provider.TokenUsage{TotalTokens: 1000}}, nil
+	}
+	cfg := &config.Config{Budgets: config.BudgetsConfig{Day: 1000}}
+
+	logger := slog.New(slog.DiscardHandler)
+	appCtx := core.NewAppContext(logger, t.TempDir(), t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, err := attachRouter(ctx, []core.Module{p, ch, turns}, cfg, appCtx, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeRouter(r, logger)
+
+	job := turns.Jobs()[0]
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if reply := nextReply(t, ch); reply.TextContent() != "report" {
+		t.Errorf("first reply = %q, want the report", reply.TextContent())
+	}
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if reply := nextReply(t, ch); reply.TextContent() != router.DefaultErrorReply {
+		t.Errorf("second reply = %q, want the error reply once the day's budget is spent", reply.TextContent())
+	}
+	if p.CompleteCalls != 1 {
+		t.Errorf("provider calls = %d, want 1", p.CompleteCalls)
+	}
+
+	ledger, err := budget.Open(filepath.Join(appCtx.DataDir, budgetLedgerFile), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent := ledger.Spent(dayBudgetKey, budget.PeriodDay); spent != 1000 {
+		t.Errorf("persisted spending = %d, want 1000", spent)
+	}
+}
+
+func TestAttachRouter_NoInbox(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.DiscardHandler)
+	appCtx := core.NewAppContext(logger, t.TempDir(), t.TempDir())
+
+	r, err := attachRouter(context.Background(), []core.Module{newProviderModule()}, &config.Config{}, appCtx, logger)
+	if err != nil || r != nil {
+		t.Errorf("attachRouter() = %v, %v, want no router without inbox modules", r, err)
+	}
+}
@@ -5,11 +5,20 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/flemzord/sclaw/internal/config"
 	"github.com/flemzord/sclaw/internal/core"
 	"github.com/spf13/cobra"
+
+	// Compiled-in modules, registered by their init functions.
+	_ "github.com/flemzord/sclaw/internal/cron"
+	_ "github.com/flemzord/sclaw/internal/memory"
+	_ "github.com/flemzord/sclaw/internal/provider/anthropic"
+	_ "github.com/flemzord/sclaw/internal/provider/ollama"
+	_ "github.com/flemzord/sclaw/internal/provider/openai"
 )
 
 // Set by goreleaser ldflags.
@@ -32,7 +41,7 @@ func rootCmd() *cobra.Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 	}
-	root.AddCommand(versionCmd(), startCmd(), configCmd())
+	root.AddCommand(versionCmd(), startCmd(), chatCmd(), configCmd())
 	return root
 }
 
@@ -60,13 +69,9 @@ func startCmd() *cobra.Command {
 		Use:   "start",
 		Short: "Start sclaw with all configured modules",
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			cfgPath, _ := cmd.Flags().GetString("config")
-			if cfgPath == "" {
-				resolved, err := resolveConfigPath()
-				if err != nil {
-					return err
-				}
-				cfgPath = resolved
+			cfgPath, err := configFlag(cmd)
+			if err != nil {
+				return err
 			}
 
 			cfg, err := config.Load(cfgPath)
@@ -85,12 +90,30 @@ func startCmd() *cobra.Command {
 			appCtx = appCtx.WithModuleConfigs(cfg.Modules)
 
 			app := core.NewApp(appCtx)
+			app.SetAdminAddr(cfg.Admin.Addr)
 			ids := config.Resolve(cfg)
 			if err := app.LoadModules(ids); err != nil {
 				return err
 			}
+			defer app.Stop()
 
-			return app.Run()
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			r, err := attachRouter(ctx, app.Modules(), cfg, appCtx, logger)
+			if err != nil {
+				return err
+			}
+			if err := app.Start(); err != nil {
+				return err
+			}
+
+			<-ctx.Done()
+			logger.Info("shutdown signal received")
+			// Finish in-flight turns while channels can still deliver
+			// their replies, then stop the modules.
+			closeRouter(r, logger)
+			return nil
 		},
 	}
 	cmd.Flags().StringP("config", "c", "", "Path to configuration file")
@@ -138,6 +161,16 @@ func configCmd() *cobra.Command {
 	return cmd
 }
 
+// configFlag returns the --config flag value, or the first config file
+// found in the standard locations when the flag is unset.
+func configFlag(cmd *cobra.Command) (string, error) {
+	cfgPath, _ := cmd.Flags().GetString("config")
+	if cfgPath != "" {
+		return cfgPath, nil
+	}
+	return resolveConfigPath()
+}
+
 // resolveConfigPath searches for a config file in standard locations.
 // Search order: $XDG_CONFIG_HOME/sclaw/sclaw.yaml → ./sclaw.yaml
 func resolveConfigPath() (string, error) {
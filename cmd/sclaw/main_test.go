@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/core"
+)
+
+func TestCompiledModules(t *testing.T) {
+	t.Parallel()
+
+	// Literal IDs: importing the module packages here would register
+	// them regardless of the binary's imports.
+	for _, id := range []string{
+		"channel.stdio",
+		"cron.agent_turn",
+		"memory.file",
+		"provider.anthropic",
+		"provider.ollama",
+		"provider.openai",
+	} {
+		if _, ok := core.GetModule(id); !ok {
+			t.Errorf("module %s not compiled in", id)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+// DefaultCompactionThreshold is the fraction of the context window a
+// request may fill before the history is compacted.
+const DefaultCompactionThreshold = 0.8
+
+// DefaultSummaryPrompt instructs the model used by SummaryCompactor.
+const DefaultSummaryPrompt = "Summarize the conversation below so that it can replace it as context " +
+	"for the rest of the conversation. Keep facts, decisions, user preferences, open tasks and " +
+	"relevant tool results. Be concise and write in the conversation's language."
+
+// summaryPrefix introduces the summary message inserted in the history.
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+// truncationNote opens a kept history that would otherwise start with an
+// assistant turn, which APIs such as Anthropic's reject.
+const truncationNote = "(Earlier messages were removed to fit the context window.)"
+
+// summaryBudgetShare reserves 1/summaryBudgetShare of the budget for the
+// summary.
+const summaryBudgetShare = 4
+
+// CompactionConfig controls how the loop keeps requests within the
+// provider's context window.
+type CompactionConfig struct {
+	// Disabled turns compaction off: requests are sent as they are and
+	// context-length errors are returned to the caller.
+	Disabled bool
+
+	// Threshold is the fraction of ContextWindowSize a request may fill
+	// before the history is compacted. Default: DefaultCompactionThreshold.
+	Threshold float64
+
+	// Compactor shrinks the history. Default: TruncateCompactor.
+	Compactor Compactor
+}
+
+// withDefaults returns a copy with zero fields replaced by defaults.
+func (c CompactionConfig) withDefaults() CompactionConfig {
+	if c.Threshold <= 0 || c.Threshold > 1 {
+		c.Threshold = DefaultCompactionThreshold
+	}
+	if c.Compactor == nil {
+		c.Compactor = TruncateCompactor{}
+	}
+	return c
+}
+
+// Compactor shrinks a conversation history so that it fits in budget
+// tokens. The history never includes the system prompt. Implementations
+// must not separate tool results from the assistant message that
+// requested them, and should keep the most recent messages verbatim.
+type Compactor interface {
+	Compact(ctx context.Context, history []provider.LLMMessage, budget int) ([]provider.LLMMessage, error)
+}
+
+// CompactorFunc adapts a function to the Compactor interface.
+type CompactorFunc func(ctx context.Context, history []provider.LLMMessage, budget int) ([]provider.LLMMessage, error)
+
+// Compact calls f(ctx, history, budget).
+func (f CompactorFunc) Compact(ctx context.Context, history []provider.LLMMessage, budget int) ([]provider.LLMMessage, error) {
+	return f(ctx, history, budget)
+}
+
+// TruncateCompactor drops the oldest turns until the history fits.
+type TruncateCompactor struct{}
+
+// Compact implements Compactor.
+func (TruncateCompactor) Compact(_ context.Context, history []provider.LLMMessage, budget int) ([]provider.LLMMessage, error) {
+	return keepFrom(history, cutIndex(history, budget)), nil
+}
+
+// SummaryCompactor replaces the oldest turns with a summary written by
+// Provider, typically the chain's RoleInternal provider. When no summary
+// can be produced, it falls back to truncation.
+type SummaryCompactor struct {
+	Provider provider.Provider
+
+	// Prompt instructs the model how to summarize.
+	// Default: DefaultSummaryPrompt.
+	Prompt string
+}
+
+// Compact implements Compactor.
+func (c SummaryCompactor) Compact(ctx context.Context, history []provider.LLMMessage, budget int) ([]provider.LLMMessage, error) {
+	reserve := budget / summaryBudgetShare
+	cut := cutIndex(history, budget-reserve)
+	if cut == 0 {
+		return history, nil
+	}
+
+	summary, err := c.summarize(ctx, history[:cut], reserve)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return keepFrom(history, cut), nil
+	}
+
+	kept := keepFrom(history, cut)
+	compacted := make([]provider.LLMMessage, 0, len(kept)+1)
+	compacted = append(compacted, provider.LLMMessage{
+		Role:    provider.MessageRoleSystem,
+		Content: summaryPrefix + summary,
+	})
+	return append(compacted, kept...), nil
+}
+
+// summarize asks the provider for a summary of msgs in at most maxTokens.
+func (c SummaryCompactor) summarize(ctx context.Context, msgs []provider.LLMMessage, maxTokens int) (string, error) {
+	if c.Provider == nil {
+		return "", errors.New("agent: summary compactor has no provider")
+	}
+	prompt := c.Prompt
+	if prompt == "" {
+		prompt = DefaultSummaryPrompt
+	}
+
+	text := transcript(msgs)
+	// Keep the most recent part of the transcript if it cannot fit in the
+	// summarizer's own context window.
+	if window := c.Provider.ContextWindowSize(); window > 0 {
+		text = clipStart(text, window*3/4)
+	}
+
+	resp, err := c.Provider.Complete(ctx, provider.CompletionRequest{
+		Messages: []provider.LLMMessage{
+			{Role: provider.MessageRoleSystem, Content: prompt},
+			{Role: provider.MessageRoleUser, Content: text},
+		},
+		MaxTokens: maxTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf("agent: summarizing history: %w", err)
+	}
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return "", errors.New("agent: summarizing history: empty summary")
+	}
+	return summary, nil
+}
+
+// transcript renders messages as plain text for summarization.
+func transcript(msgs []provider.LLMMessage) string {
+	var b strings.Builder
+	for _, m := range msgs {
+		switch m.Role {
+		case provider.MessageRoleTool:
+			status := "result"
+			if m.IsError {
+				status = "error"
+			}
+			fmt.Fprintf(&b, "tool %s %s: %s\n", m.Name, status, m.Content)
+		default:
+			if text := m.TextContent(); text != "" {
+				fmt.Fprintf(&b, "%s: %s\n", m.Role, text)
+			}
+			for _, tc := range m.ToolCalls {
+				fmt.Fprintf(&b, "%s called %s(%s)\n", m.Role, tc.Name, tc.Arguments)
+			}
+		}
+	}
+	return b.String()
+}
+
+// keepFrom returns the messages of history kept by a cut at cut. When
+// they would start with an assistant turn, a user turn with
+// truncationNote is put in front.
+func keepFrom(history []provider.LLMMessage, cut int) []provider.LLMMessage {
+	kept := history[cut:]
+	if cut == 0 || len(kept) == 0 || kept[0].Role != provider.MessageRoleAssistant {
+		return kept
+	}
+	out := make([]provider.LLMMessage, 0, len(kept)+1)
+	out = append(out, provider.LLMMessage{Role: provider.MessageRoleUser, Content: truncationNote})
+	return append(out, kept...)
+}
+
+// cutIndex returns the index of the first message to keep so that
+// keepFrom(history, i) fits in budget. Cuts happen at user messages when
+// possible, otherwise at any message that is not a tool result. If no
+// cut fits, the last possible cut is returned so that the latest turn is
+// kept.
+func cutIndex(history []provider.LLMMessage, budget int) int {
+	if provider.EstimateMessageTokens(history) <= budget {
+		return 0
+	}
+
+	var user, other []int
+	for i := 1; i < len(history); i++ {
+		switch history[i].Role {
+		case provider.MessageRoleTool:
+			// Never orphan a tool result from its assistant message.
+		case provider.MessageRoleUser:
+			user = append(user, i)
+		default:
+			other = append(other, i)
+		}
+	}
+
+	for _, i := range user {
+		if provider.EstimateMessageTokens(history[i:]) <= budget {
+			return i
+		}
+	}
+	for _, i := range other {
+		if provider.EstimateMessageTokens(keepFrom(history, i)) <= budget {
+			return i
+		}
+	}
+
+	last := 0
+	if len(user) > 0 {
+		last = user[len(user)-1]
+	}
+	if len(other) > 0 && other[len(other)-1] > last {
+		last = other[len(other)-1]
+	}
+	return last
+}
+
+// clipStart drops the beginning of text so that its estimated size is at
+// most maxTokens, cutting on a rune boundary.
+func clipStart(text string, maxTokens int) string {
+	n := provider.EstimateTextTokens(text)
+	if n <= maxTokens {
+		return text
+	}
+	cut := len(text) - int(int64(len(text))*int64(maxTokens)/int64(n))
+	for cut < len(text) && !utf8.RuneStart(text[cut]) {
+		cut++
+	}
+	return text[cut:]
+}
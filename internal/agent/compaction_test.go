@@ -0,0 +1,369 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+)
+
+// bytesPerToken matches the token estimation heuristic of the provider
+// package.
+const bytesPerToken = 4
+
+// longMsg returns a message of roughly tokens estimated tokens.
+func longMsg(role provider.MessageRole, tokens int) provider.LLMMessage {
+	return provider.LLMMessage{Role: role, Content: strings.Repeat("x", tokens*bytesPerToken)}
+}
+
+// toolTurn returns a user message followed by an assistant tool call and
+// its result.
+func toolTurn(id string, tokens int) []provider.LLMMessage {
+	return []provider.LLMMessage{
+		longMsg(provider.MessageRoleUser, tokens),
+		{
+			Role:      provider.MessageRoleAssistant,
+			ToolCalls: []provider.ToolCall{{ID: id, Name: "read", Arguments: json.RawMessage(`{}`)}},
+		},
+		{Role: provider.MessageRoleTool, ToolID: id, Name: "read", Content: strings.Repeat("y", tokens*bytesPerToken)},
+	}
+}
+
+func TestCutIndex(t *testing.T) {
+	t.Parallel()
+
+	var history []provider.LLMMessage
+	history = append(history, toolTurn("a", 100)...)
+	history = append(history, toolTurn("b", 100)...)
+	history = append(history, longMsg(provider.MessageRoleUser, 10))
+
+	tests := []struct {
+		name   string
+		budget int
+		want   int
+	}{
+		{"fits", 10000, 0},
+		{"drop first turn", 250, 3},
+		{"keep last user message", 50, 6},
+		{"nothing fits", 1, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := cutIndex(history, tt.budget)
+			if got != tt.want {
+				t.Errorf("cutIndex(%d) = %d, want %d", tt.budget, got, tt.want)
+			}
+			if got < len(history) && history[got].Role == provider.MessageRoleTool {
+				t.Errorf("cut at %d orphans a tool result", got)
+			}
+		})
+	}
+}
+
+func TestCutIndex_NoUserBoundary(t *testing.T) {
+	t.Parallel()
+
+	// A single long tool-calling turn: cuts fall back to assistant messages
+	// and never land on a tool result.
+	history := []provider.LLMMessage{longMsg(provider.MessageRoleUser, 10)}
+	for _, id := range []string{"a", "b", "c"} {
+		history = append(history, toolTurn(id, 100)[1:]...)
+	}
+
+	got := cutIndex(history, 120)
+	if got != 5 {
+		t.Errorf("cutIndex = %d, want 5", got)
+	}
+}
+
+func TestTruncateCompactor(t *testing.T) {
+	t.Parallel()
+
+	history := append(toolTurn("a", 100), toolTurn("b", 100)...)
+	got, err := TruncateCompactor{}.Compact(context.Background(), history, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[1].ToolCalls[0].ID != "b" {
+		t.Errorf("Compact kept %d messages, want the last turn", len(got))
+	}
+}
+
+func TestTruncateCompactor_AssistantCut(t *testing.T) {
+	t.Parallel()
+
+	// Only cuts at assistant messages fit: the kept history must still
+	// open with a user turn.
+	history := []provider.LLMMessage{longMsg(provider.MessageRoleUser, 10)}
+	for _, id := range []string{"a", "b", "c"} {
+		history = append(history, toolTurn(id, 100)[1:]...)
+	}
+
+	got, err := TruncateCompactor{}.Compact(context.Background(), history, 130)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].Role != provider.MessageRoleUser || got[0].Content != truncationNote {
+		t.Fatalf("Compact = %+v, want the truncation note then the last turn", got)
+	}
+	if got[1].Role != provider.MessageRoleAssistant || got[1].ToolCalls[0].ID != "c" {
+		t.Errorf("kept %+v, want the last tool call", got[1])
+	}
+	if n := provider.EstimateMessageTokens(got); n > 130 {
+		t.Errorf("kept %d tokens, want at most 130", n)
+	}
+}
+
+func TestSummaryCompactor(t *testing.T) {
+	t.Parallel()
+
+	var req provider.CompletionRequest
+	summarizer := &providertest.MockProvider{
+		CompleteFunc: func(_ context.Context, r provider.CompletionRequest) (provider.CompletionResponse, error) {
+			req = r
+			return provider.CompletionResponse{Content: "  user asked to read a  "}, nil
+		},
+		ContextWindowSizeFunc: func() int { return 8192 },
+	}
+
+	history := append(toolTurn("a", 100), toolTurn("b", 100)...)
+	got, err := SummaryCompactor{Provider: summarizer}.Compact(context.Background(), history, 320)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 4 {
+		t.Fatalf("Compact returned %d messages, want summary + last turn", len(got))
+	}
+	if got[0].Role != provider.MessageRoleSystem || got[0].Content != summaryPrefix+"user asked to read a" {
+		t.Errorf("summary message = %+v", got[0])
+	}
+	if got[2].ToolCalls[0].ID != "b" {
+		t.Errorf("last turn not kept verbatim: %+v", got[1:])
+	}
+	if req.MaxTokens != 80 {
+		t.Errorf("summary MaxTokens = %d, want 80", req.MaxTokens)
+	}
+	if !strings.Contains(req.Messages[1].Content, "assistant called read({})") {
+		t.Errorf("transcript missing tool call: %q", req.Messages[1].Content)
+	}
+}
+
+func TestSummaryCompactor_FallsBackToTruncation(t *testing.T) {
+	t.Parallel()
+
+	summarizer := &providertest.MockProvider{
+		CompleteFunc: func(context.Context, provider.CompletionRequest) (provider.CompletionResponse, error) {
+			return provider.CompletionResponse{}, provider.ErrProviderDown
+		},
+		ContextWindowSizeFunc: func() int { return 8192 },
+	}
+
+	history := append(toolTurn("a", 100), toolTurn("b", 100)...)
+	got, err := SummaryCompactor{Provider: summarizer}.Compact(context.Background(), history, 320)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].Role != provider.MessageRoleUser {
+		t.Errorf("Compact = %d messages starting with %s, want the last turn", len(got), got[0].Role)
+	}
+}
+
+// windowProvider is a mockProvider with a small context window that
+// rejects requests with more than maxMessages messages.
+type windowProvider struct {
+	*mockProvider
+	window      int
+	maxMessages int
+
+	mu       sync.Mutex
+	rejected int
+}
+
+func (w *windowProvider) ContextWindowSize() int { return w.window }
+
+func (w *windowProvider) tooLong(req provider.CompletionRequest) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(req.Messages) > w.maxMessages {
+		w.rejected++
+		return true
+	}
+	return false
+}
+
+func (w *windowProvider) Complete(ctx context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+	if w.tooLong(req) {
+		return provider.CompletionResponse{}, provider.ErrContextLength
+	}
+	return w.mockProvider.Complete(ctx, req)
+}
+
+func (w *windowProvider) Stream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+	if w.tooLong(req) {
+		return nil, provider.ErrContextLength
+	}
+	return w.mockProvider.Stream(ctx, req)
+}
+
+func TestRun_CompactsNearWindow(t *testing.T) {
+	t.Parallel()
+
+	p := &windowProvider{
+		mockProvider: &mockProvider{responses: []provider.CompletionResponse{{Content: "ok"}}},
+		window:       500,
+		maxMessages:  100,
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+
+	history := append(toolTurn("a", 200), toolTurn("b", 100)...)
+	history = append(history, userMsg("now"))
+
+	resp, err := loop.Run(context.Background(), Request{Messages: history, SystemPrompt: "sys"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	sent := p.recorded()[0].Messages
+	if sent[0].Role != provider.MessageRoleSystem || sent[0].Content != "sys" {
+		t.Errorf("system prompt not kept first: %+v", sent[0])
+	}
+	if len(sent) != 5 {
+		t.Errorf("sent %d messages, want system + last turn + user", len(sent))
+	}
+	if provider.EstimateMessageTokens(sent) > 400 {
+		t.Errorf("sent %d estimated tokens, want <= 400", provider.EstimateMessageTokens(sent))
+	}
+
+	if len(resp.Messages) != 1 || resp.Messages[0].Content != "ok" {
+		t.Errorf("Messages = %+v, want only the reply", resp.Messages)
+	}
+	if len(resp.History) != 5 || resp.History[4].Content != "ok" {
+		t.Errorf("History = %d messages, want compacted history + reply", len(resp.History))
+	}
+}
+
+func TestRun_NoCompactionBelowThreshold(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{responses: []provider.CompletionResponse{{Content: "ok"}}}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+
+	resp, err := loop.Run(context.Background(), Request{Messages: toolTurn("a", 100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.History != nil {
+		t.Errorf("History = %+v, want nil without compaction", resp.History)
+	}
+	if got := len(p.recorded()[0].Messages); got != 3 {
+		t.Errorf("sent %d messages, want 3", got)
+	}
+}
+
+func TestRun_RetriesOnContextLength(t *testing.T) {
+	t.Parallel()
+
+	// The window is unknown, so only the provider's rejection triggers
+	// compaction.
+	p := &windowProvider{
+		mockProvider: &mockProvider{responses: []provider.CompletionResponse{{Content: "ok"}}},
+		maxMessages:  4,
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+
+	history := append(toolTurn("a", 100), toolTurn("b", 100)...)
+	history = append(history, userMsg("now"))
+
+	resp, err := loop.Run(context.Background(), Request{Messages: history})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if p.rejected != 1 {
+		t.Errorf("rejected = %d, want 1", p.rejected)
+	}
+	if resp.Content != "ok" || resp.History == nil {
+		t.Errorf("resp = %+v, want reply with compacted history", resp)
+	}
+}
+
+func TestRun_ContextLengthCompactionDisabled(t *testing.T) {
+	t.Parallel()
+
+	p := &windowProvider{mockProvider: &mockProvider{}, maxMessages: 1}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{
+		Compaction: CompactionConfig{Disabled: true},
+	})
+
+	_, err := loop.Run(context.Background(), Request{Messages: toolTurn("a", 10)})
+	if !errors.Is(err, provider.ErrContextLength) {
+		t.Fatalf("err = %v, want ErrContextLength", err)
+	}
+	if p.rejected != 1 {
+		t.Errorf("rejected = %d, want 1 (no retry)", p.rejected)
+	}
+}
+
+func TestRunStream_RetriesOnContextLength(t *testing.T) {
+	t.Parallel()
+
+	p := &windowProvider{
+		mockProvider: &mockProvider{streams: [][]provider.StreamChunk{{{Content: "ok"}}}},
+		maxMessages:  4,
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+
+	history := append(toolTurn("a", 100), toolTurn("b", 100)...)
+	history = append(history, userMsg("now"))
+
+	ch, err := loop.RunStream(context.Background(), Request{Messages: history})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var done *StreamEvent
+	for e := range ch {
+		if e.Type == StreamEventError {
+			t.Fatalf("unexpected error event: %v", e.Err)
+		}
+		if e.Type == StreamEventDone {
+			done = &e
+		}
+	}
+	if done == nil {
+		t.Fatal("expected StreamEventDone")
+	}
+	if done.History == nil || done.History[len(done.History)-1].Content != "ok" {
+		t.Errorf("History = %+v, want compacted history ending with reply", done.History)
+	}
+}
+
+func TestClipStart(t *testing.T) {
+	t.Parallel()
+
+	if got := clipStart("short", 100); got != "short" {
+		t.Errorf("clipStart() = %q, want the text unchanged", got)
+	}
+
+	text := strings.Repeat("é", 1000) + "end"
+	for maxTokens := 1; maxTokens <= 50; maxTokens++ {
+		got := clipStart(text, maxTokens)
+		if !utf8.ValidString(got) {
+			t.Fatalf("clipStart(%d) split a rune: %q", maxTokens, got)
+		}
+		if !strings.HasSuffix(got, "end") {
+			t.Fatalf("clipStart(%d) = %q, want the end kept", maxTokens, got)
+		}
+		if n := provider.EstimateTextTokens(got); n > maxTokens {
+			t.Errorf("clipStart(%d) estimates %d tokens", maxTokens, n)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package agent
 
-import "time"
+import (
+	"time"
+
+	"github.com/flemzord/sclaw/internal/metrics"
+)
 
 // Default values for LoopConfig.
 const (
@@ -19,12 +23,27 @@ type LoopConfig struct {
 	// Zero means unlimited.
 	TokenBudget int
 
+	// PromptTokenBudget is the cumulative limit on prompt tokens.
+	// Zero means unlimited.
+	PromptTokenBudget int
+
+	// CompletionTokenBudget is the cumulative limit on completion tokens.
+	// Zero means unlimited.
+	CompletionTokenBudget int
+
 	// Timeout is the maximum wall-clock duration for the loop.
 	Timeout time.Duration
 
 	// LoopThreshold is how many times the same tool call (name + args)
 	// can repeat before the loop is considered stuck.
 	LoopThreshold int
+
+	// Compaction keeps requests within the provider's context window.
+	Compaction CompactionConfig
+
+	// Metrics records the iterations and stop reason of each run.
+	// Optional.
+	Metrics *metrics.Registry
 }
 
 // withDefaults returns a copy with zero fields replaced by defaults.
@@ -38,5 +57,6 @@ func (c LoopConfig) withDefaults() LoopConfig {
 	if c.LoopThreshold <= 0 {
 		c.LoopThreshold = DefaultLoopThreshold
 	}
+	c.Compaction = c.Compaction.withDefaults()
 	return c
 }
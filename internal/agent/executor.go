@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/flemzord/sclaw/internal/metrics"
 	"github.com/flemzord/sclaw/internal/provider"
 	"github.com/flemzord/sclaw/internal/tool"
 )
@@ -19,6 +20,9 @@ type ToolExecutorConfig struct {
 	Requester       tool.ApprovalRequester
 	ApprovalTimeout time.Duration
 	Env             tool.ExecutionEnv
+
+	// Metrics records tool executions and approval requests. Optional.
+	Metrics *metrics.Registry
 }
 
 // ToolExecutor handles parallel tool execution with panic recovery.
@@ -30,19 +34,45 @@ type ToolExecutor struct {
 	requester       tool.ApprovalRequester
 	approvalTimeout time.Duration
 	env             tool.ExecutionEnv
+	metrics         toolMetrics
 }
 
 // NewToolExecutor creates a ToolExecutor from the given configuration.
 func NewToolExecutor(cfg ToolExecutorConfig) *ToolExecutor {
+	m := newToolMetrics(cfg.Metrics)
+	requester := cfg.Requester
+	if requester != nil && cfg.Metrics != nil {
+		requester = approvalRecorder{requester: requester, approvals: m.approvals}
+	}
 	return &ToolExecutor{
 		registry:        cfg.Registry,
 		policyCfg:       cfg.PolicyCfg,
 		policyCtx:       cfg.PolicyCtx,
 		elevated:        cfg.Elevated,
-		requester:       cfg.Requester,
+		requester:       requester,
 		approvalTimeout: cfg.ApprovalTimeout,
 		env:             cfg.Env,
+		metrics:         m,
+	}
+}
+
+// ToolDefinitions describes every tool in the registry in the form expected
+// by CompletionRequest.Tools, sorted by name.
+func ToolDefinitions(reg *tool.Registry) []provider.ToolDefinition {
+	names := reg.Names()
+	defs := make([]provider.ToolDefinition, 0, len(names))
+	for _, name := range names {
+		t, err := reg.Get(name)
+		if err != nil {
+			continue // unregistered concurrently
+		}
+		defs = append(defs, provider.ToolDefinition{
+			Name:        name,
+			Description: t.Description(),
+			Parameters:  t.Schema(),
+		})
 	}
+	return defs
 }
 
 // Execute runs all tool calls in parallel and returns results in input order.
@@ -69,6 +99,7 @@ func (e *ToolExecutor) executeSingle(ctx context.Context, tc provider.ToolCall)
 	record.Arguments = tc.Arguments
 
 	start := time.Now()
+	outcome := toolOutcomeSuccess
 
 	defer func() {
 		record.Duration = time.Since(start)
@@ -78,7 +109,9 @@ func (e *ToolExecutor) executeSingle(ctx context.Context, tc provider.ToolCall)
 				Content: fmt.Sprintf("panic: %v", r),
 				IsError: true,
 			}
+			outcome = toolOutcomePanic
 		}
+		e.metrics.execution(tc.Name, outcome, record.Duration)
 	}()
 
 	out, err := e.registry.Execute(
@@ -92,6 +125,7 @@ func (e *ToolExecutor) executeSingle(ctx context.Context, tc provider.ToolCall)
 		e.approvalTimeout,
 		e.env,
 	)
+	outcome = toolOutcome(out, err)
 	if err != nil {
 		record.Output = tool.Output{
 			Content: err.Error(),
@@ -267,3 +267,28 @@ func TestExecute_ParallelErrorIsolation(t *testing.T) {
 			results[2].Output.IsError, results[2].Output.Content)
 	}
 }
+
+func TestToolDefinitions(t *testing.T) {
+	t.Parallel()
+
+	reg := tool.NewRegistry()
+	for _, mt := range []*mockTool{{name: "write"}, {name: "read"}} {
+		if err := reg.Register(mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defs := ToolDefinitions(reg)
+	if len(defs) != 2 {
+		t.Fatalf("expected 2 definitions, got %d", len(defs))
+	}
+	if defs[0].Name != "read" || defs[1].Name != "write" {
+		t.Errorf("expected sorted names [read write], got [%s %s]", defs[0].Name, defs[1].Name)
+	}
+	if defs[0].Description != "mock tool" {
+		t.Errorf("Description = %q, want %q", defs[0].Description, "mock tool")
+	}
+	if string(defs[0].Parameters) != `{}` {
+		t.Errorf("Parameters = %s, want {}", defs[0].Parameters)
+	}
+}
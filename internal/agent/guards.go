@@ -47,29 +47,94 @@ func (d *loopDetector) reset() {
 	d.counts = make(map[string]int)
 }
 
-// tokenTracker accumulates token usage and checks against a budget.
+// tokenTracker accumulates token usage and checks it against the run's
+// budgets and the caller's external budgets.
 //
 // It is not concurrent-safe by design: each instance is owned by a single
 // goroutine (the Run or RunStream loop).
 type tokenTracker struct {
-	budget int
-	usage  provider.TokenUsage
+	budget           int
+	promptBudget     int
+	completionBudget int
+	external         []Budget
+	usage            provider.TokenUsage
 }
 
 func newTokenTracker(budget int) *tokenTracker {
 	return &tokenTracker{budget: budget}
 }
 
+// newRunTracker creates a tracker for the budgets of cfg and req.
+func newRunTracker(cfg LoopConfig, req Request) *tokenTracker {
+	return &tokenTracker{
+		budget:           cfg.TokenBudget,
+		promptBudget:     cfg.PromptTokenBudget,
+		completionBudget: cfg.CompletionTokenBudget,
+		external:         req.Budgets,
+	}
+}
+
+// add records usage and charges it to the external budgets.
 func (t *tokenTracker) add(usage provider.TokenUsage) {
 	t.usage.PromptTokens += usage.PromptTokens
 	t.usage.CompletionTokens += usage.CompletionTokens
 	t.usage.TotalTokens += usage.TotalTokens
+	for _, b := range t.external {
+		b.Spend(usage)
+	}
 }
 
-// exceeded reports whether the cumulative token usage has reached the budget.
+// exceeded reports whether any budget has been used up.
 // A zero budget means unlimited and never exceeds.
 func (t *tokenTracker) exceeded() bool {
-	return t.budget > 0 && t.usage.TotalTokens >= t.budget
+	if t.budget > 0 && t.usage.TotalTokens >= t.budget {
+		return true
+	}
+	if t.promptBudget > 0 && t.usage.PromptTokens >= t.promptBudget {
+		return true
+	}
+	if t.completionBudget > 0 && t.usage.CompletionTokens >= t.completionBudget {
+		return true
+	}
+	for _, b := range t.external {
+		if b.Remaining() <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// allowance returns the completion tokens a request of prompt tokens may
+// use without going past any budget. ok is false when the request cannot
+// be made at all; maxTokens is 0 when completions are not limited.
+func (t *tokenTracker) allowance(prompt int) (maxTokens int, ok bool) {
+	if t.promptBudget > 0 && t.usage.PromptTokens+prompt > t.promptBudget {
+		return 0, false
+	}
+
+	limit := func(left int) {
+		if maxTokens == 0 || left < maxTokens {
+			maxTokens = left
+		}
+		if left <= 0 {
+			ok = false
+		}
+	}
+
+	ok = true
+	if t.budget > 0 {
+		limit(t.budget - t.usage.TotalTokens - prompt)
+	}
+	if t.completionBudget > 0 {
+		limit(t.completionBudget - t.usage.CompletionTokens)
+	}
+	for _, b := range t.external {
+		limit(b.Remaining() - prompt)
+	}
+	if !ok {
+		return 0, false
+	}
+	return maxTokens, true
 }
 
 func (t *tokenTracker) total() provider.TokenUsage {
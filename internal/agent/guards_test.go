@@ -91,3 +91,76 @@ func TestTokenTracker_UnlimitedBudget(t *testing.T) {
 		t.Error("zero budget should never exceed")
 	}
 }
+
+// fixedBudget is an in-memory Budget.
+type fixedBudget struct {
+	left  int
+	spent int
+}
+
+func (b *fixedBudget) Remaining() int { return b.left - b.spent }
+
+func (b *fixedBudget) Spend(usage provider.TokenUsage) { b.spent += usage.TotalTokens }
+
+func TestTokenTracker_Allowance(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		tracker *tokenTracker
+		prompt  int
+		want    int
+		wantOK  bool
+	}{
+		{"unlimited", &tokenTracker{}, 1 << 20, 0, true},
+		{"total", &tokenTracker{budget: 500}, 100, 400, true},
+		{"total exhausted by prompt", &tokenTracker{budget: 500}, 500, 0, false},
+		{"prompt budget", &tokenTracker{promptBudget: 100}, 101, 0, false},
+		{"completion budget", &tokenTracker{completionBudget: 50}, 1000, 50, true},
+		{"smallest wins", &tokenTracker{budget: 500, completionBudget: 50}, 100, 50, true},
+		{"external", &tokenTracker{external: []Budget{&fixedBudget{left: 300}}}, 100, 200, true},
+		{"external exhausted", &tokenTracker{external: []Budget{&fixedBudget{left: 50}}}, 100, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := tt.tracker.allowance(tt.prompt)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("allowance(%d) = (%d, %v), want (%d, %v)", tt.prompt, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTokenTracker_SeparateBudgets(t *testing.T) {
+	t.Parallel()
+
+	tr := &tokenTracker{promptBudget: 100}
+	tr.add(provider.TokenUsage{PromptTokens: 100, CompletionTokens: 5, TotalTokens: 105})
+	if !tr.exceeded() {
+		t.Error("expected prompt budget exceeded")
+	}
+
+	tr = &tokenTracker{completionBudget: 10}
+	tr.add(provider.TokenUsage{PromptTokens: 1000, CompletionTokens: 5, TotalTokens: 1005})
+	if tr.exceeded() {
+		t.Error("completion budget not yet exceeded")
+	}
+}
+
+func TestTokenTracker_ChargesExternalBudgets(t *testing.T) {
+	t.Parallel()
+
+	b := &fixedBudget{left: 100}
+	tr := &tokenTracker{external: []Budget{b}}
+	tr.add(provider.TokenUsage{TotalTokens: 60})
+	tr.add(provider.TokenUsage{TotalTokens: 40})
+
+	if b.spent != 100 {
+		t.Errorf("spent = %d, want 100", b.spent)
+	}
+	if !tr.exceeded() {
+		t.Error("expected exhausted external budget to be exceeded")
+	}
+}
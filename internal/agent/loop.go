@@ -3,8 +3,12 @@ package agent
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 
+	"github.com/flemzord/sclaw/internal/hook"
 	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/tool"
 )
 
 // Sentinel errors for agent loop termination.
@@ -19,6 +23,7 @@ type Loop struct {
 	provider provider.Provider
 	executor *ToolExecutor
 	config   LoopConfig
+	metrics  loopMetrics
 }
 
 // NewLoop creates a Loop with the given provider, executor, and config.
@@ -27,6 +32,7 @@ func NewLoop(p provider.Provider, executor *ToolExecutor, cfg LoopConfig) *Loop
 		provider: p,
 		executor: executor,
 		config:   cfg.withDefaults(),
+		metrics:  newLoopMetrics(cfg.Metrics),
 	}
 }
 
@@ -42,29 +48,265 @@ func buildInitialMessages(req Request) []provider.LLMMessage {
 	return append(messages, req.Messages...)
 }
 
-// appendToolResults adds tool execution results to the conversation history.
-func appendToolResults(messages []provider.LLMMessage, records []ToolCallRecord) []provider.LLMMessage {
+// assistantMessage builds the assistant turn that requested the given tool
+// calls. The calls are kept on the message so that the tool results which
+// follow can be matched to them by ID.
+func assistantMessage(content string, calls []provider.ToolCall) provider.LLMMessage {
+	return provider.LLMMessage{
+		Role:      provider.MessageRoleAssistant,
+		Content:   content,
+		ToolCalls: calls,
+	}
+}
+
+// toolResults builds the tool messages answering the executed calls.
+func toolResults(records []ToolCallRecord) []provider.LLMMessage {
+	messages := make([]provider.LLMMessage, 0, len(records))
 	for _, rec := range records {
 		messages = append(messages, provider.LLMMessage{
 			Role:    provider.MessageRoleTool,
 			Content: rec.Output.Content,
+			Name:    rec.Name,
 			ToolID:  rec.ID,
+			IsError: rec.Output.IsError,
 		})
 	}
 	return messages
 }
 
+// conversation tracks the messages of one run. context is what the
+// provider sees and may be rewritten by compaction; produced is the
+// untouched transcript of the messages the run added.
+type conversation struct {
+	context   []provider.LLMMessage
+	produced  []provider.LLMMessage
+	system    int // number of leading system prompt messages in context
+	compacted bool
+	hooks     *hook.Pipeline
+	allowed   []string // tools the run may execute; nil means all
+}
+
+func newConversation(req Request) *conversation {
+	c := &conversation{context: buildInitialMessages(req), hooks: req.Hooks}
+	if req.SystemPrompt != "" {
+		c.system = 1
+	}
+	return c
+}
+
+// add appends messages produced by the run.
+func (c *conversation) add(msgs ...provider.LLMMessage) {
+	c.context = append(c.context, msgs...)
+	c.produced = append(c.produced, msgs...)
+}
+
+// checkAllowed returns an error if the active skills do not allow the
+// named tool.
+func (c *conversation) checkAllowed(name string) error {
+	if c.allowed == nil || slices.Contains(c.allowed, name) {
+		return nil
+	}
+	return fmt.Errorf("agent: tool %q is not available with the active skills", name)
+}
+
+// history returns the compacted history without the system prompt, or
+// nil if the conversation was never compacted.
+func (c *conversation) history() []provider.LLMMessage {
+	if !c.compacted {
+		return nil
+	}
+	return c.context[c.system:]
+}
+
+// compact rewrites the conversation context when a request of used
+// prompt tokens nears the provider's context window. With force, it
+// compacts further, because the provider rejected the context as too
+// long. It reports whether the context changed.
+func (l *Loop) compact(ctx context.Context, conv *conversation, tools []provider.ToolDefinition, used int, force bool) (bool, error) {
+	cfg := l.config.Compaction
+	if cfg.Disabled {
+		return false, nil
+	}
+
+	limit := int(float64(l.provider.ContextWindowSize()) * cfg.Threshold)
+	switch {
+	case force && (limit <= 0 || used <= limit):
+		// The count was too optimistic: aim well below it.
+		limit = used * 3 / 4
+	case limit <= 0 || used <= limit:
+		return false, nil
+	}
+
+	// Compactors work with estimates: scale the limit when used came from
+	// the provider's own tokenizer.
+	if estimate := provider.EstimateMessageTokens(conv.context) + provider.EstimateToolTokens(tools); used > 0 && estimate != used {
+		limit = int(int64(limit) * int64(estimate) / int64(used))
+	}
+
+	system, history := conv.context[:conv.system], conv.context[conv.system:]
+	budget := limit - provider.EstimateMessageTokens(system) - provider.EstimateToolTokens(tools)
+	if budget <= 0 {
+		return false, nil
+	}
+
+	compacted, err := cfg.Compactor.Compact(ctx, history, budget)
+	if err != nil {
+		return false, fmt.Errorf("agent: compacting history: %w", err)
+	}
+	if len(compacted) == len(history) &&
+		provider.EstimateMessageTokens(compacted) >= provider.EstimateMessageTokens(history) {
+		return false, nil
+	}
+
+	conv.context = append(slices.Clip(system), compacted...)
+	conv.compacted = true
+	return true, nil
+}
+
+// request builds the provider request for the current context.
+func (l *Loop) request(conv *conversation, tools []provider.ToolDefinition) provider.CompletionRequest {
+	return provider.CompletionRequest{
+		Messages: provider.AdaptMessages(l.provider, conv.context),
+		Tools:    tools,
+	}
+}
+
+// prepare builds the next provider request. It counts the prompt tokens
+// up front, compacts the history when they near the context window (or
+// further, with force), and refuses requests that exceed the remaining
+// token budgets or, when the count is exact, the context window. MaxTokens
+// is capped so that the completion cannot overrun a budget. The request
+// then goes through the before_provider hooks. It reports whether
+// compaction changed the context.
+func (l *Loop) prepare(ctx context.Context, conv *conversation, tools []provider.ToolDefinition, tracker *tokenTracker, force bool) (provider.CompletionRequest, bool, error) {
+	req := l.request(conv, tools)
+	n, exact := provider.CountTokens(ctx, l.provider, req)
+
+	changed, err := l.compact(ctx, conv, tools, n, force)
+	if err != nil {
+		return req, false, err
+	}
+	if changed {
+		req = l.request(conv, tools)
+		n, exact = provider.CountTokens(ctx, l.provider, req)
+	}
+
+	if window := l.provider.ContextWindowSize(); exact && window > 0 && n > window {
+		return req, changed, fmt.Errorf("agent: request needs %d tokens, context window is %d: %w",
+			n, window, provider.ErrContextLength)
+	}
+	maxTokens, ok := tracker.allowance(n)
+	if !ok {
+		return req, changed, fmt.Errorf("agent: no budget left for a request of %d prompt tokens: %w",
+			n, ErrTokenBudgetExceeded)
+	}
+	if maxTokens > 0 && (req.MaxTokens == 0 || maxTokens < req.MaxTokens) {
+		req.MaxTokens = maxTokens
+	}
+
+	if err := conv.hooks.Run(ctx, &hook.Event{Point: hook.PointBeforeProvider, Request: &req}); err != nil {
+		return req, changed, err
+	}
+	return req, changed, nil
+}
+
+// complete calls the provider with a prepared request and, if the provider
+// rejects it as too long, compacts further and retries once.
+func (l *Loop) complete(ctx context.Context, conv *conversation, tools []provider.ToolDefinition, tracker *tokenTracker) (provider.CompletionResponse, error) {
+	req, _, err := l.prepare(ctx, conv, tools, tracker, false)
+	if err != nil {
+		return provider.CompletionResponse{}, err
+	}
+	resp, err := l.provider.Complete(ctx, req)
+	if errors.Is(err, provider.ErrContextLength) {
+		var changed bool
+		var perr error
+		req, changed, perr = l.prepare(ctx, conv, tools, tracker, true)
+		if perr != nil {
+			return resp, errors.Join(err, perr)
+		}
+		if !changed {
+			return resp, err
+		}
+		resp, err = l.provider.Complete(ctx, req)
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	if err := conv.hooks.Run(ctx, &hook.Event{Point: hook.PointAfterProvider, Response: &resp}); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// execute runs tool calls through the before_tool and after_tool hooks
+// around the executor. Calls to tools outside the active skills, and calls
+// vetoed by a hook, are not executed: the reason is reported to the model
+// as a failed result. Results are in input order.
+func (l *Loop) execute(ctx context.Context, conv *conversation, calls []provider.ToolCall) []ToolCallRecord {
+	hooks := conv.hooks
+	if conv.allowed == nil && !hooks.Has(hook.PointBeforeTool) && !hooks.Has(hook.PointAfterTool) {
+		return l.executor.Execute(ctx, calls)
+	}
+
+	records := make([]ToolCallRecord, len(calls))
+	executed := make([]ToolCallRecord, 0, len(calls))
+	var run []provider.ToolCall
+	var runIdx []int
+	for i, call := range calls {
+		err := conv.checkAllowed(call.Name)
+		if err == nil {
+			err = hooks.Run(ctx, &hook.Event{Point: hook.PointBeforeTool, ToolCall: &call})
+		}
+		if err != nil {
+			records[i] = ToolCallRecord{
+				ID:        call.ID,
+				Name:      call.Name,
+				Arguments: call.Arguments,
+				Output:    tool.Output{Content: err.Error(), IsError: true},
+			}
+			continue
+		}
+		run = append(run, call)
+		runIdx = append(runIdx, i)
+	}
+
+	if len(run) > 0 {
+		executed = l.executor.Execute(ctx, run)
+	}
+	for j, rec := range executed {
+		call := run[j]
+		if err := hooks.Run(ctx, &hook.Event{
+			Point:      hook.PointAfterTool,
+			ToolCall:   &call,
+			ToolOutput: &rec.Output,
+		}); err != nil {
+			rec.Output = tool.Output{Content: err.Error(), IsError: true}
+		}
+		records[runIdx[j]] = rec
+	}
+	return records
+}
+
 // Run executes the ReAct loop synchronously and returns the final response.
 //
 // A context.WithTimeout is applied using l.config.Timeout. If the caller's
 // context already carries a shorter deadline, the shorter one takes effect.
-func (l *Loop) Run(ctx context.Context, req Request) (Response, error) {
+func (l *Loop) Run(ctx context.Context, req Request) (resp Response, err error) {
+	req, allowed, err := setupRequest(req)
+	if err != nil {
+		return Response{StopReason: StopReasonError}, err
+	}
+	defer func() { l.metrics.run(resp.Iterations, resp.StopReason) }()
+
 	ctx, cancel := context.WithTimeout(ctx, l.config.Timeout)
 	defer cancel()
 
 	detector := newLoopDetector(l.config.LoopThreshold)
-	tracker := newTokenTracker(l.config.TokenBudget)
-	messages := buildInitialMessages(req)
+	tracker := newRunTracker(l.config, req)
+	conv := newConversation(req)
+	conv.allowed = allowed
 
 	var allToolCalls []ToolCallRecord
 
@@ -76,6 +318,8 @@ func (l *Loop) Run(ctx context.Context, req Request) (Response, error) {
 				TotalUsage: tracker.total(),
 				Iterations: i,
 				StopReason: StopReasonTimeout,
+				Messages:   conv.produced,
+				History:    conv.history(),
 			}, context.DeadlineExceeded
 		}
 
@@ -86,61 +330,69 @@ func (l *Loop) Run(ctx context.Context, req Request) (Response, error) {
 				TotalUsage: tracker.total(),
 				Iterations: i,
 				StopReason: StopReasonTokenBudget,
+				Messages:   conv.produced,
+				History:    conv.history(),
 			}, ErrTokenBudgetExceeded
 		}
 
 		// Call provider.
-		resp, err := l.provider.Complete(ctx, provider.CompletionRequest{
-			Messages: messages,
-			Tools:    req.Tools,
-		})
-		if err != nil {
+		cresp, cerr := l.complete(ctx, conv, req.Tools, tracker)
+		if cerr != nil {
+			reason := StopReasonError
+			if errors.Is(cerr, ErrTokenBudgetExceeded) {
+				reason = StopReasonTokenBudget
+			}
 			return Response{
 				ToolCalls:  allToolCalls,
 				TotalUsage: tracker.total(),
 				Iterations: i,
-				StopReason: StopReasonError,
-			}, err
+				StopReason: reason,
+				Messages:   conv.produced,
+				History:    conv.history(),
+			}, cerr
 		}
 
-		tracker.add(resp.Usage)
+		tracker.add(cresp.Usage)
 
 		// No tool calls → the model is done reasoning.
-		if len(resp.ToolCalls) == 0 {
+		if len(cresp.ToolCalls) == 0 {
+			conv.add(assistantMessage(cresp.Content, nil))
 			return Response{
-				Content:    resp.Content,
+				Content:    cresp.Content,
 				ToolCalls:  allToolCalls,
 				TotalUsage: tracker.total(),
 				Iterations: i + 1,
 				StopReason: StopReasonComplete,
+				Messages:   conv.produced,
+				History:    conv.history(),
 			}, nil
 		}
 
 		// Check for loops before appending assistant message to avoid
 		// leaving an orphan assistant message without tool results.
-		for _, tc := range resp.ToolCalls {
+		for _, tc := range cresp.ToolCalls {
 			if detector.record(tc.Name, tc.Arguments) {
 				return Response{
 					ToolCalls:  allToolCalls,
 					TotalUsage: tracker.total(),
 					Iterations: i + 1,
 					StopReason: StopReasonLoopDetected,
+					Messages:   conv.produced,
+					History:    conv.history(),
 				}, ErrLoopDetected
 			}
 		}
 
-		// Append assistant message with the content (may be empty).
-		messages = append(messages, provider.LLMMessage{
-			Role:    provider.MessageRoleAssistant,
-			Content: resp.Content,
-		})
+		// Append assistant message with the content (may be empty) and
+		// the tool calls it requested.
+		conv.add(assistantMessage(cresp.Content, cresp.ToolCalls))
 
 		// Execute tools in parallel.
-		records := l.executor.Execute(ctx, resp.ToolCalls)
+		records := l.execute(ctx, conv, cresp.ToolCalls)
 		allToolCalls = append(allToolCalls, records...)
 
 		// Re-inject tool results into conversation.
-		messages = appendToolResults(messages, records)
+		conv.add(toolResults(records)...)
 	}
 
 	// Max iterations reached.
@@ -149,14 +401,114 @@ func (l *Loop) Run(ctx context.Context, req Request) (Response, error) {
 		TotalUsage: tracker.total(),
 		Iterations: l.config.MaxIterations,
 		StopReason: StopReasonMaxIterations,
+		Messages:   conv.produced,
+		History:    conv.history(),
 	}, ErrMaxIterationsReached
 }
 
+// streamResult is the accumulated output of one streamed provider call.
+type streamResult struct {
+	content   string
+	toolCalls []provider.ToolCall
+	usage     *provider.TokenUsage
+
+	// emitted reports whether any chunk was forwarded to the caller.
+	emitted bool
+}
+
+// stream runs one streamed provider call, forwarding text chunks to ch.
+// Like complete, it compacts the context when needed and retries once if
+// the provider rejects it as too long before producing any output.
+func (l *Loop) stream(ctx context.Context, ch chan<- StreamEvent, conv *conversation, tools []provider.ToolDefinition, tracker *tokenTracker) (streamResult, error) {
+	req, _, err := l.prepare(ctx, conv, tools, tracker, false)
+	if err != nil {
+		return streamResult{}, err
+	}
+
+	for retried := false; ; retried = true {
+		res, err := l.streamOnce(ctx, ch, req)
+		if err == nil {
+			return res, l.afterStream(ctx, conv.hooks, &res)
+		}
+		if retried || res.emitted || !errors.Is(err, provider.ErrContextLength) {
+			return res, err
+		}
+		var changed bool
+		var perr error
+		req, changed, perr = l.prepare(ctx, conv, tools, tracker, true)
+		if perr != nil {
+			return res, errors.Join(err, perr)
+		}
+		if !changed {
+			return res, err
+		}
+	}
+}
+
+// afterStream runs the after_provider hooks on a completed stream. Hook
+// changes apply to the history and tool calls; streamed text is already
+// delivered.
+func (l *Loop) afterStream(ctx context.Context, hooks *hook.Pipeline, res *streamResult) error {
+	if !hooks.Has(hook.PointAfterProvider) {
+		return nil
+	}
+	resp := provider.CompletionResponse{Content: res.content, ToolCalls: res.toolCalls}
+	if res.usage != nil {
+		resp.Usage = *res.usage
+	}
+	if err := hooks.Run(ctx, &hook.Event{Point: hook.PointAfterProvider, Response: &resp}); err != nil {
+		return err
+	}
+	res.content = resp.Content
+	res.toolCalls = resp.ToolCalls
+	return nil
+}
+
+// streamOnce consumes a single provider stream.
+func (l *Loop) streamOnce(ctx context.Context, ch chan<- StreamEvent, req provider.CompletionRequest) (streamResult, error) {
+	streamCh, err := l.provider.Stream(ctx, req)
+	if err != nil {
+		return streamResult{}, err
+	}
+
+	var res streamResult
+	for chunk := range streamCh {
+		if chunk.Err != nil {
+			// Drain remaining chunks to prevent provider goroutine leak.
+			//nolint:revive // intentional empty drain loop
+			for range streamCh { //nolint:revive
+			}
+			return res, chunk.Err
+		}
+		if chunk.Reset {
+			res = streamResult{emitted: res.emitted}
+			ch <- StreamEvent{Type: StreamEventReset}
+			continue
+		}
+		if chunk.Content != "" {
+			res.content += chunk.Content
+			res.emitted = true
+			ch <- StreamEvent{Type: StreamEventText, Content: chunk.Content}
+		}
+		if len(chunk.ToolCalls) > 0 {
+			res.toolCalls = append(res.toolCalls, chunk.ToolCalls...)
+		}
+		if chunk.Usage != nil {
+			res.usage = chunk.Usage
+		}
+	}
+	return res, nil
+}
+
 // RunStream executes the ReAct loop and streams events over a channel.
 //
 // A context.WithTimeout is applied using l.config.Timeout. If the caller's
 // context already carries a shorter deadline, the shorter one takes effect.
 func (l *Loop) RunStream(ctx context.Context, req Request) (<-chan StreamEvent, error) {
+	req, allowed, err := setupRequest(req)
+	if err != nil {
+		return nil, err
+	}
 	ch := make(chan StreamEvent, 16)
 
 	go func() {
@@ -165,110 +517,86 @@ func (l *Loop) RunStream(ctx context.Context, req Request) (<-chan StreamEvent,
 		ctx, cancel := context.WithTimeout(ctx, l.config.Timeout)
 		defer cancel()
 
-		detector := newLoopDetector(l.config.LoopThreshold)
-		tracker := newTokenTracker(l.config.TokenBudget)
-		messages := buildInitialMessages(req)
+		iterations, err := l.runStream(ctx, ch, req, allowed)
+		if err != nil {
+			ch <- StreamEvent{Type: StreamEventError, Err: err}
+		}
+		l.metrics.run(iterations, stopReasonFor(err))
+	}()
 
-		for i := 0; i < l.config.MaxIterations; i++ {
-			if ctx.Err() != nil {
-				ch <- StreamEvent{Type: StreamEventError, Err: context.DeadlineExceeded}
-				return
-			}
+	return ch, nil
+}
 
-			if tracker.exceeded() {
-				ch <- StreamEvent{Type: StreamEventError, Err: ErrTokenBudgetExceeded}
-				return
-			}
+// runStream implements RunStream, sending every event but the final
+// error to ch. It returns the number of iterations run and the error
+// that ended the run, if any.
+func (l *Loop) runStream(ctx context.Context, ch chan<- StreamEvent, req Request, allowed []string) (int, error) {
+	detector := newLoopDetector(l.config.LoopThreshold)
+	tracker := newRunTracker(l.config, req)
+	conv := newConversation(req)
+	conv.allowed = allowed
 
-			streamCh, err := l.provider.Stream(ctx, provider.CompletionRequest{
-				Messages: messages,
-				Tools:    req.Tools,
-			})
-			if err != nil {
-				ch <- StreamEvent{Type: StreamEventError, Err: err}
-				return
-			}
+	for i := 0; i < l.config.MaxIterations; i++ {
+		if ctx.Err() != nil {
+			return i, context.DeadlineExceeded
+		}
 
-			// Consume stream, forwarding text chunks and accumulating tool calls.
-			var content string
-			var toolCalls []provider.ToolCall
-			var usage *provider.TokenUsage
-
-			var streamErr error
-			for chunk := range streamCh {
-				if chunk.Err != nil {
-					streamErr = chunk.Err
-					break
-				}
-				if chunk.Content != "" {
-					content += chunk.Content
-					ch <- StreamEvent{Type: StreamEventText, Content: chunk.Content}
-				}
-				if len(chunk.ToolCalls) > 0 {
-					toolCalls = append(toolCalls, chunk.ToolCalls...)
-				}
-				if chunk.Usage != nil {
-					usage = chunk.Usage
-				}
-			}
+		if tracker.exceeded() {
+			return i, ErrTokenBudgetExceeded
+		}
 
-			// Drain remaining chunks to prevent provider goroutine leak.
-			if streamErr != nil {
-				//nolint:revive // intentional empty drain loop
-				for range streamCh { //nolint:revive
-				}
-				ch <- StreamEvent{Type: StreamEventError, Err: streamErr}
-				return
-			}
+		// Consume stream, forwarding text chunks and accumulating tool calls.
+		res, err := l.stream(ctx, ch, conv, req.Tools, tracker)
+		if err != nil {
+			return i, err
+		}
 
-			if usage != nil {
-				tracker.add(*usage)
-				ch <- StreamEvent{Type: StreamEventUsage, Usage: usage}
-			}
+		if res.usage != nil {
+			tracker.add(*res.usage)
+			ch <- StreamEvent{Type: StreamEventUsage, Usage: res.usage}
+		}
 
-			// No tool calls → done.
-			if len(toolCalls) == 0 {
-				ch <- StreamEvent{Type: StreamEventDone}
-				return
+		// No tool calls → done.
+		if len(res.toolCalls) == 0 {
+			conv.add(assistantMessage(res.content, nil))
+			ch <- StreamEvent{
+				Type:     StreamEventDone,
+				Messages: conv.produced,
+				History:  conv.history(),
 			}
+			return i + 1, nil
+		}
 
-			// Check loops before appending assistant message to avoid
-			// leaving an orphan assistant message without tool results.
-			for _, tc := range toolCalls {
-				if detector.record(tc.Name, tc.Arguments) {
-					ch <- StreamEvent{Type: StreamEventError, Err: ErrLoopDetected}
-					return
-				}
+		// Check loops before appending assistant message to avoid
+		// leaving an orphan assistant message without tool results.
+		for _, tc := range res.toolCalls {
+			if detector.record(tc.Name, tc.Arguments) {
+				return i + 1, ErrLoopDetected
 			}
+		}
 
-			messages = append(messages, provider.LLMMessage{
-				Role:    provider.MessageRoleAssistant,
-				Content: content,
-			})
-
-			// Signal tool starts.
-			for _, tc := range toolCalls {
-				ch <- StreamEvent{
-					Type:     StreamEventToolStart,
-					ToolCall: &ToolCallRecord{ID: tc.ID, Name: tc.Name, Arguments: tc.Arguments},
-				}
+		conv.add(assistantMessage(res.content, res.toolCalls))
+
+		// Signal tool starts.
+		for _, tc := range res.toolCalls {
+			ch <- StreamEvent{
+				Type:     StreamEventToolStart,
+				ToolCall: &ToolCallRecord{ID: tc.ID, Name: tc.Name, Arguments: tc.Arguments},
 			}
+		}
 
-			records := l.executor.Execute(ctx, toolCalls)
+		records := l.execute(ctx, conv, res.toolCalls)
 
-			for idx := range records {
-				ch <- StreamEvent{
-					Type:     StreamEventToolEnd,
-					ToolCall: &records[idx],
-				}
+		for idx := range records {
+			ch <- StreamEvent{
+				Type:     StreamEventToolEnd,
+				ToolCall: &records[idx],
 			}
-
-			// Re-inject tool results.
-			messages = appendToolResults(messages, records)
 		}
 
-		ch <- StreamEvent{Type: StreamEventError, Err: ErrMaxIterationsReached}
-	}()
+		// Re-inject tool results.
+		conv.add(toolResults(records)...)
+	}
 
-	return ch, nil
+	return l.config.MaxIterations, ErrMaxIterationsReached
 }
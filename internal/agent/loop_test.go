@@ -5,26 +5,40 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/flemzord/sclaw/internal/hook"
+	"github.com/flemzord/sclaw/internal/hook/hooktest"
 	"github.com/flemzord/sclaw/internal/provider"
 	"github.com/flemzord/sclaw/internal/tool"
 )
 
-// mockProvider returns pre-configured responses in sequence.
+// mockProvider returns pre-configured responses in sequence and records
+// every request it receives.
 type mockProvider struct {
 	mu        sync.Mutex
 	responses []provider.CompletionResponse
 	streams   [][]provider.StreamChunk
+	requests  []provider.CompletionRequest
 	callIdx   int
 	streamIdx int
 }
 
-func (m *mockProvider) Complete(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+// recorded returns a copy of the requests received so far.
+func (m *mockProvider) recorded() []provider.CompletionRequest {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return append([]provider.CompletionRequest(nil), m.requests...)
+}
+
+func (m *mockProvider) Complete(_ context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, req)
 	if m.callIdx >= len(m.responses) {
 		return provider.CompletionResponse{}, fmt.Errorf("no more mock responses")
 	}
@@ -33,9 +47,10 @@ func (m *mockProvider) Complete(_ context.Context, _ provider.CompletionRequest)
 	return resp, nil
 }
 
-func (m *mockProvider) Stream(_ context.Context, _ provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+func (m *mockProvider) Stream(_ context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.requests = append(m.requests, req)
 	if m.streamIdx >= len(m.streams) {
 		return nil, fmt.Errorf("no more mock streams")
 	}
@@ -219,6 +234,88 @@ func TestRun_ToolError(t *testing.T) {
 	}
 }
 
+// assertToolHistory checks that msgs ends with an assistant turn carrying
+// the expected tool call followed by the matching tool result.
+func assertToolHistory(t *testing.T, msgs []provider.LLMMessage, wantContent string, wantIsError bool) {
+	t.Helper()
+
+	if len(msgs) < 2 {
+		t.Fatalf("expected at least 2 messages, got %d", len(msgs))
+	}
+	asst := msgs[len(msgs)-2]
+	res := msgs[len(msgs)-1]
+
+	if asst.Role != provider.MessageRoleAssistant {
+		t.Fatalf("expected assistant message, got %s", asst.Role)
+	}
+	if len(asst.ToolCalls) != 1 || asst.ToolCalls[0].ID != "call_1" || asst.ToolCalls[0].Name != "read" {
+		t.Errorf("assistant tool calls = %+v, want one call_1/read", asst.ToolCalls)
+	}
+	if string(asst.ToolCalls[0].Arguments) != `{"path":"a.txt"}` {
+		t.Errorf("assistant tool call arguments = %s", asst.ToolCalls[0].Arguments)
+	}
+
+	if res.Role != provider.MessageRoleTool {
+		t.Fatalf("expected tool message, got %s", res.Role)
+	}
+	if res.ToolID != "call_1" || res.Name != "read" {
+		t.Errorf("tool result ToolID/Name = %q/%q, want call_1/read", res.ToolID, res.Name)
+	}
+	if res.Content != wantContent {
+		t.Errorf("tool result content = %q, want %q", res.Content, wantContent)
+	}
+	if res.IsError != wantIsError {
+		t.Errorf("tool result IsError = %v, want %v", res.IsError, wantIsError)
+	}
+}
+
+// TestRun_ToolHistoryRoundTrip: the follow-up request carries the assistant
+// tool calls and the tool results with name and error flag.
+func TestRun_ToolHistoryRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	readTool := &mockTool{
+		name:   "read",
+		output: tool.Output{Content: "no such file", IsError: true},
+	}
+	p := &mockProvider{
+		responses: []provider.CompletionResponse{
+			{
+				Content:      "reading",
+				ToolCalls:    []provider.ToolCall{{ID: "call_1", Name: "read", Arguments: json.RawMessage(`{"path":"a.txt"}`)}},
+				FinishReason: provider.FinishReasonToolUse,
+			},
+			{Content: "done", FinishReason: provider.FinishReasonStop},
+		},
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(readTool), LoopConfig{MaxIterations: 5})
+
+	resp, err := loop.Run(context.Background(), Request{
+		Messages: []provider.LLMMessage{userMsg("read a.txt")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reqs := p.recorded()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 provider calls, got %d", len(reqs))
+	}
+	assertToolHistory(t, reqs[1].Messages, "no such file", true)
+	if reqs[1].Messages[1].Content != "reading" {
+		t.Errorf("assistant content = %q, want %q", reqs[1].Messages[1].Content, "reading")
+	}
+
+	// The returned transcript excludes the request and ends with the reply.
+	if len(resp.Messages) != 3 {
+		t.Fatalf("expected 3 transcript messages, got %d", len(resp.Messages))
+	}
+	assertToolHistory(t, resp.Messages[:2], "no such file", true)
+	if last := resp.Messages[2]; last.Role != provider.MessageRoleAssistant || last.Content != "done" {
+		t.Errorf("final message = %+v, want assistant 'done'", last)
+	}
+}
+
 // TestRun_ParallelToolErrorIsolation: one tool errors in parallel, others succeed.
 func TestRun_ParallelToolErrorIsolation(t *testing.T) {
 	t.Parallel()
@@ -463,6 +560,52 @@ func TestRunStream_TextChunks(t *testing.T) {
 	}
 }
 
+// TestRunStream_Reset: a reset chunk discards the partial output of the call.
+func TestRunStream_Reset(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{
+		streams: [][]provider.StreamChunk{
+			{
+				{Content: "partial "},
+				{ToolCalls: []provider.ToolCall{{ID: "1", Name: "read", Arguments: json.RawMessage(`{}`)}}},
+				{Reset: true},
+				{Content: "final"},
+				{FinishReason: provider.FinishReasonStop},
+			},
+		},
+	}
+	executor := newLoopTestExecutor()
+	loop := newTestLoop(p, executor, LoopConfig{MaxIterations: 5})
+
+	ch, err := loop.RunStream(context.Background(), Request{
+		Messages: []provider.LLMMessage{userMsg("hi")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var types []StreamEventType
+	var done StreamEvent
+	for e := range ch {
+		types = append(types, e.Type)
+		switch e.Type {
+		case StreamEventDone:
+			done = e
+		case StreamEventError:
+			t.Fatalf("unexpected error event: %v", e.Err)
+		}
+	}
+
+	want := []StreamEventType{StreamEventText, StreamEventReset, StreamEventText, StreamEventDone}
+	if !slices.Equal(types, want) {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+	if len(done.Messages) != 1 || done.Messages[0].Content != "final" || len(done.Messages[0].ToolCalls) != 0 {
+		t.Errorf("messages = %+v, want only the output after the reset", done.Messages)
+	}
+}
+
 // TestRunStream_ToolExecution: stream with tool calls → tool start/end events → final done.
 func TestRunStream_ToolExecution(t *testing.T) {
 	t.Parallel()
@@ -709,3 +852,430 @@ func TestRunStream_Timeout(t *testing.T) {
 		t.Error("expected StreamEventError for cancelled context")
 	}
 }
+
+// TestRunStream_ToolHistoryRoundTrip: streamed tool calls are kept on the
+// assistant message and results carry name and error flag.
+func TestRunStream_ToolHistoryRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	readTool := &mockTool{name: "read", output: tool.Output{Content: "contents"}}
+	p := &mockProvider{
+		streams: [][]provider.StreamChunk{
+			{
+				{ToolCalls: []provider.ToolCall{{ID: "call_1", Name: "read", Arguments: json.RawMessage(`{"path":"a.txt"}`)}}},
+				{FinishReason: provider.FinishReasonToolUse},
+			},
+			{
+				{Content: "done"},
+				{FinishReason: provider.FinishReasonStop},
+			},
+		},
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(readTool), LoopConfig{MaxIterations: 5})
+
+	ch, err := loop.RunStream(context.Background(), Request{
+		Messages: []provider.LLMMessage{userMsg("read a.txt")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var transcript []provider.LLMMessage
+	for e := range ch {
+		if e.Type == StreamEventError {
+			t.Fatalf("unexpected error event: %v", e.Err)
+		}
+		if e.Type == StreamEventDone {
+			transcript = e.Messages
+		}
+	}
+
+	reqs := p.recorded()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 provider calls, got %d", len(reqs))
+	}
+	assertToolHistory(t, reqs[1].Messages, "contents", false)
+
+	if len(transcript) != 3 {
+		t.Fatalf("expected 3 transcript messages on done, got %d", len(transcript))
+	}
+	if last := transcript[2]; last.Role != provider.MessageRoleAssistant || last.Content != "done" {
+		t.Errorf("final message = %+v, want assistant 'done'", last)
+	}
+}
+
+// textOnlyMockProvider wraps mockProvider and declares text-only support.
+type textOnlyMockProvider struct{ *mockProvider }
+
+func (textOnlyMockProvider) SupportsContent(t provider.ContentPartType) bool {
+	return t == provider.ContentPartText
+}
+
+func imageMsg() provider.LLMMessage {
+	return provider.LLMMessage{
+		Role:    provider.MessageRoleUser,
+		Content: "what is this?\n[image: https://example.com/x.png (image/png)]",
+		Parts: []provider.ContentPart{
+			provider.TextPart("what is this?"),
+			provider.ImagePart("https://example.com/x.png", "image/png"),
+		},
+	}
+}
+
+// TestRun_MultimodalPassThrough: parts reach a provider that does not
+// restrict content types untouched.
+func TestRun_MultimodalPassThrough(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{
+		responses: []provider.CompletionResponse{{Content: "a cat", FinishReason: provider.FinishReasonStop}},
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{MaxIterations: 5})
+
+	if _, err := loop.Run(context.Background(), Request{
+		Messages: []provider.LLMMessage{imageMsg()},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := p.recorded()[0].Messages[0]
+	if len(got.Parts) != 2 || got.Parts[1].Type != provider.ContentPartImage {
+		t.Errorf("expected image part to pass through, got %+v", got.Parts)
+	}
+}
+
+// TestRunStream_TextOnlyFallback: a text-only provider receives the text
+// rendering instead of the image part.
+func TestRunStream_TextOnlyFallback(t *testing.T) {
+	t.Parallel()
+
+	mp := &mockProvider{
+		streams: [][]provider.StreamChunk{{{Content: "ok"}, {FinishReason: provider.FinishReasonStop}}},
+	}
+	loop := newTestLoop(textOnlyMockProvider{mp}, newLoopTestExecutor(), LoopConfig{MaxIterations: 5})
+
+	ch, err := loop.RunStream(context.Background(), Request{
+		Messages: []provider.LLMMessage{imageMsg()},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for e := range ch {
+		if e.Type == StreamEventError {
+			t.Fatalf("unexpected error event: %v", e.Err)
+		}
+	}
+
+	got := mp.recorded()[0].Messages[0]
+	if got.Parts != nil {
+		t.Errorf("expected parts to be dropped for text-only provider, got %+v", got.Parts)
+	}
+	if got.Content != imageMsg().Content {
+		t.Errorf("Content = %q, want %q", got.Content, imageMsg().Content)
+	}
+}
+
+// countingMockProvider is a mockProvider that counts prompt tokens with
+// a fixed cost per message, and declares a small context window.
+type countingMockProvider struct {
+	*mockProvider
+	window     int
+	perMessage int
+}
+
+func (c *countingMockProvider) ContextWindowSize() int { return c.window }
+
+func (c *countingMockProvider) CountTokens(_ context.Context, req provider.CompletionRequest) (int, error) {
+	return len(req.Messages) * c.perMessage, nil
+}
+
+// TestRun_RefusesOverBudgetBeforeCall: a prompt larger than the remaining
+// budget is refused without calling the provider.
+func TestRun_RefusesOverBudgetBeforeCall(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{responses: []provider.CompletionResponse{{Content: "unreachable"}}}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{TokenBudget: 50})
+
+	resp, err := loop.Run(context.Background(), Request{
+		Messages: []provider.LLMMessage{userMsg(strings.Repeat("x", 400))},
+	})
+	if !errors.Is(err, ErrTokenBudgetExceeded) {
+		t.Fatalf("err = %v, want ErrTokenBudgetExceeded", err)
+	}
+	if resp.StopReason != StopReasonTokenBudget {
+		t.Errorf("StopReason = %s, want %s", resp.StopReason, StopReasonTokenBudget)
+	}
+	if n := len(p.recorded()); n != 0 {
+		t.Errorf("provider called %d times, want 0", n)
+	}
+}
+
+// TestRun_TokenCounterDrivesCompaction: the provider's own count, not the
+// estimate, decides when to compact.
+func TestRun_TokenCounterDrivesCompaction(t *testing.T) {
+	t.Parallel()
+
+	p := &countingMockProvider{
+		mockProvider: &mockProvider{responses: []provider.CompletionResponse{{Content: "ok"}}},
+		window:       1000,
+		perMessage:   200,
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+
+	// Five short messages: tiny estimate, but 1000 counted tokens.
+	msgs := []provider.LLMMessage{userMsg("a"), userMsg("b"), userMsg("c"), userMsg("d"), userMsg("e")}
+	resp, err := loop.Run(context.Background(), Request{Messages: msgs})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	sent := p.recorded()[0].Messages
+	if len(sent) >= len(msgs) || sent[len(sent)-1].Content != "e" {
+		t.Errorf("sent %d messages, want compacted history ending with the last message", len(sent))
+	}
+	if resp.History == nil {
+		t.Error("expected compacted History")
+	}
+}
+
+// TestRun_RefusesOverWindowWithExactCount: with an exact count that cannot
+// fit the window, the loop refuses instead of calling the provider.
+func TestRun_RefusesOverWindowWithExactCount(t *testing.T) {
+	t.Parallel()
+
+	p := &countingMockProvider{mockProvider: &mockProvider{}, window: 100, perMessage: 500}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+
+	_, err := loop.Run(context.Background(), Request{Messages: []provider.LLMMessage{userMsg("hi")}})
+	if !errors.Is(err, provider.ErrContextLength) {
+		t.Fatalf("err = %v, want ErrContextLength", err)
+	}
+	if n := len(p.recorded()); n != 0 {
+		t.Errorf("provider called %d times, want 0", n)
+	}
+}
+
+// TestRun_CapsMaxTokensToBudget: each call's MaxTokens is limited to what
+// the budget has left after the prompt.
+func TestRun_CapsMaxTokensToBudget(t *testing.T) {
+	t.Parallel()
+
+	readTool := &mockTool{name: "read", output: tool.Output{Content: "data"}}
+	p := &mockProvider{
+		responses: []provider.CompletionResponse{
+			{
+				ToolCalls: []provider.ToolCall{{ID: "1", Name: "read", Arguments: json.RawMessage(`{}`)}},
+				Usage:     provider.TokenUsage{PromptTokens: 200, CompletionTokens: 100, TotalTokens: 300},
+			},
+			{Content: "done"},
+		},
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(readTool), LoopConfig{TokenBudget: 1000, CompletionTokenBudget: 800})
+
+	if _, err := loop.Run(context.Background(), Request{Messages: []provider.LLMMessage{userMsg("go")}}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	reqs := p.recorded()
+	if reqs[0].MaxTokens != 800 {
+		t.Errorf("first MaxTokens = %d, want 800 (completion budget)", reqs[0].MaxTokens)
+	}
+	prompt := provider.EstimateTokens(provider.CompletionRequest{Messages: reqs[1].Messages})
+	if want := 1000 - 300 - prompt; reqs[1].MaxTokens != want {
+		t.Errorf("second MaxTokens = %d, want %d", reqs[1].MaxTokens, want)
+	}
+}
+
+// sharedBudget is an in-memory Budget shared across runs.
+type sharedBudget struct {
+	mu    sync.Mutex
+	limit int
+	spent int
+}
+
+func (b *sharedBudget) Remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit - b.spent
+}
+
+func (b *sharedBudget) Spend(usage provider.TokenUsage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent += usage.TotalTokens
+}
+
+// TestRun_BudgetAcrossRuns: an external budget carries spending from one
+// run to the next.
+func TestRun_BudgetAcrossRuns(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{
+		responses: []provider.CompletionResponse{
+			{Content: "first", Usage: provider.TokenUsage{TotalTokens: 95}},
+			{Content: "unreachable"},
+		},
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+	budget := &sharedBudget{limit: 100}
+	req := Request{Messages: []provider.LLMMessage{userMsg("hello there, how are you")}, Budgets: []Budget{budget}}
+
+	if _, err := loop.Run(context.Background(), req); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	resp, err := loop.Run(context.Background(), req)
+	if !errors.Is(err, ErrTokenBudgetExceeded) {
+		t.Fatalf("second Run err = %v, want ErrTokenBudgetExceeded", err)
+	}
+	if resp.StopReason != StopReasonTokenBudget {
+		t.Errorf("StopReason = %s, want %s", resp.StopReason, StopReasonTokenBudget)
+	}
+	if n := len(p.recorded()); n != 1 {
+		t.Errorf("provider called %d times, want 1", n)
+	}
+}
+
+func newHookPipeline(t *testing.T, hooks ...hook.Hook) *hook.Pipeline {
+	t.Helper()
+	p, err := hook.NewPipeline(hooks, nil)
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+	return p
+}
+
+func TestRun_ProviderHooks(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{responses: []provider.CompletionResponse{{Content: "secret reply"}}}
+	h := &hooktest.MockHook{
+		On: []hook.Point{hook.PointBeforeProvider, hook.PointAfterProvider},
+		HandleFunc: func(_ context.Context, ev *hook.Event) error {
+			switch ev.Point {
+			case hook.PointBeforeProvider:
+				ev.Request.Messages = append(ev.Request.Messages, userMsg("injected"))
+			case hook.PointAfterProvider:
+				ev.Response.Content = strings.ReplaceAll(ev.Response.Content, "secret", "[redacted]")
+			}
+			return nil
+		},
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+
+	resp, err := loop.Run(context.Background(), Request{
+		Messages: []provider.LLMMessage{userMsg("hi")},
+		Hooks:    newHookPipeline(t, h),
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	sent := p.recorded()[0].Messages
+	if sent[len(sent)-1].Content != "injected" {
+		t.Errorf("last sent message = %q, want injected", sent[len(sent)-1].Content)
+	}
+	if resp.Content != "[redacted] reply" {
+		t.Errorf("Content = %q, want redacted reply", resp.Content)
+	}
+}
+
+func TestRun_BeforeProviderVeto(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{responses: []provider.CompletionResponse{{Content: "unused"}}}
+	h := &hooktest.MockHook{
+		On:         []hook.Point{hook.PointBeforeProvider},
+		HandleFunc: func(context.Context, *hook.Event) error { return hook.Veto("over quota") },
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+
+	_, err := loop.Run(context.Background(), Request{
+		Messages: []provider.LLMMessage{userMsg("hi")},
+		Hooks:    newHookPipeline(t, h),
+	})
+	if !errors.Is(err, hook.ErrVetoed) {
+		t.Fatalf("err = %v, want ErrVetoed", err)
+	}
+	if len(p.recorded()) != 0 {
+		t.Errorf("provider called %d times, want 0", len(p.recorded()))
+	}
+}
+
+func TestRun_ToolHooks(t *testing.T) {
+	t.Parallel()
+
+	readTool := &mockTool{name: "read", output: tool.Output{Content: "token=abc"}}
+	p := &mockProvider{
+		responses: []provider.CompletionResponse{
+			{ToolCalls: []provider.ToolCall{
+				{ID: "1", Name: "read", Arguments: json.RawMessage(`{}`)},
+				{ID: "2", Name: "delete", Arguments: json.RawMessage(`{}`)},
+			}},
+			{Content: "done"},
+		},
+	}
+	h := &hooktest.MockHook{
+		On: []hook.Point{hook.PointBeforeTool, hook.PointAfterTool},
+		HandleFunc: func(_ context.Context, ev *hook.Event) error {
+			switch {
+			case ev.Point == hook.PointBeforeTool && ev.ToolCall.Name == "delete":
+				return hook.Veto("deletes are disabled")
+			case ev.Point == hook.PointAfterTool:
+				ev.ToolOutput.Content = strings.ReplaceAll(ev.ToolOutput.Content, "abc", "***")
+			}
+			return nil
+		},
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(readTool), LoopConfig{})
+
+	resp, err := loop.Run(context.Background(), Request{
+		Messages: []provider.LLMMessage{userMsg("hi")},
+		Hooks:    newHookPipeline(t, h),
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(resp.ToolCalls) != 2 {
+		t.Fatalf("ToolCalls = %d, want 2", len(resp.ToolCalls))
+	}
+	if got := resp.ToolCalls[0].Output; got.Content != "token=***" || got.IsError {
+		t.Errorf("read output = %+v, want redacted content", got)
+	}
+	if got := resp.ToolCalls[1].Output; !got.IsError || !strings.Contains(got.Content, "deletes are disabled") {
+		t.Errorf("delete output = %+v, want veto reason as error", got)
+	}
+}
+
+func TestRunStream_AfterProviderHook(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{streams: [][]provider.StreamChunk{{{Content: "hello"}}}}
+	h := &hooktest.MockHook{
+		On: []hook.Point{hook.PointAfterProvider},
+		HandleFunc: func(_ context.Context, ev *hook.Event) error {
+			ev.Response.Content = strings.ToUpper(ev.Response.Content)
+			return nil
+		},
+	}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+
+	ch, err := loop.RunStream(context.Background(), Request{
+		Messages: []provider.LLMMessage{userMsg("hi")},
+		Hooks:    newHookPipeline(t, h),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var done *StreamEvent
+	for e := range ch {
+		if e.Type == StreamEventDone {
+			done = &e
+		}
+	}
+	if done == nil {
+		t.Fatal("expected StreamEventDone")
+	}
+	if got := done.Messages[len(done.Messages)-1].Content; got != "HELLO" {
+		t.Errorf("final message = %q, want HELLO", got)
+	}
+}
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/metrics"
+	"github.com/flemzord/sclaw/internal/tool"
+)
+
+// iterationBuckets are the bucket upper bounds of the iterations per run
+// histogram.
+var iterationBuckets = []float64{1, 2, 3, 4, 5, 6, 8, 10, 15, 20, 30, 50}
+
+// loopMetrics holds the metrics of agent runs. The zero value discards
+// observations.
+type loopMetrics struct {
+	runs       *metrics.Counter
+	iterations *metrics.Histogram
+}
+
+func newLoopMetrics(reg *metrics.Registry) loopMetrics {
+	return loopMetrics{
+		runs: reg.Counter("sclaw_agent_runs",
+			"Agent loop runs by stop reason.",
+			"stop_reason"),
+		iterations: reg.Histogram("sclaw_agent_iterations",
+			"Reason-act iterations per agent loop run, by stop reason.",
+			iterationBuckets, "stop_reason"),
+	}
+}
+
+// run records a run that stopped for reason after iterations.
+func (m loopMetrics) run(iterations int, reason StopReason) {
+	m.runs.Inc(string(reason))
+	m.iterations.Observe(float64(iterations), string(reason))
+}
+
+// stopReasonFor returns the stop reason of a run that ended with err.
+func stopReasonFor(err error) StopReason {
+	switch {
+	case err == nil:
+		return StopReasonComplete
+	case errors.Is(err, context.DeadlineExceeded):
+		return StopReasonTimeout
+	case errors.Is(err, ErrTokenBudgetExceeded):
+		return StopReasonTokenBudget
+	case errors.Is(err, ErrLoopDetected):
+		return StopReasonLoopDetected
+	case errors.Is(err, ErrMaxIterationsReached):
+		return StopReasonMaxIterations
+	default:
+		return StopReasonError
+	}
+}
+
+// Tool execution outcomes, the outcome label of sclaw_tool_executions.
+const (
+	toolOutcomeSuccess = "success"
+	toolOutcomeError   = "error"
+	toolOutcomeDenied  = "denied"
+	toolOutcomeTimeout = "approval_timeout"
+	toolOutcomePanic   = "panic"
+	toolOutcomeUnknown = "unknown_tool"
+)
+
+// toolMetrics holds the metrics of tool executions and approvals. The
+// zero value discards observations.
+type toolMetrics struct {
+	executions *metrics.Counter
+	duration   *metrics.Histogram
+	approvals  *metrics.Counter
+}
+
+func newToolMetrics(reg *metrics.Registry) toolMetrics {
+	return toolMetrics{
+		executions: reg.Counter("sclaw_tool_executions",
+			"Tool executions by tool and outcome. Calls to unknown tools have an empty tool label.",
+			"tool", "outcome"),
+		duration: reg.Histogram("sclaw_tool_duration_seconds",
+			"Duration of tool executions, approval included, by tool and outcome.",
+			metrics.DurationBuckets, "tool", "outcome"),
+		approvals: reg.Counter("sclaw_tool_approvals",
+			"Tool approval requests by tool and decision.",
+			"tool", "decision"),
+	}
+}
+
+// execution records a tool execution. The name of an unknown tool, chosen
+// by the model, is not used as a label value.
+func (m toolMetrics) execution(name, outcome string, d time.Duration) {
+	if outcome == toolOutcomeUnknown {
+		name = ""
+	}
+	m.executions.Inc(name, outcome)
+	m.duration.Observe(d.Seconds(), name, outcome)
+}
+
+// toolOutcome classifies the result of a tool execution.
+func toolOutcome(out tool.Output, err error) string {
+	switch {
+	case errors.Is(err, tool.ErrToolNotFound):
+		return toolOutcomeUnknown
+	case errors.Is(err, tool.ErrApprovalTimeout):
+		return toolOutcomeTimeout
+	case errors.Is(err, tool.ErrDenied):
+		return toolOutcomeDenied
+	case err != nil, out.IsError:
+		return toolOutcomeError
+	default:
+		return toolOutcomeSuccess
+	}
+}
+
+// Approval decisions, the decision label of sclaw_tool_approvals.
+const (
+	decisionApproved = "approved"
+	decisionDenied   = "denied"
+	decisionTimeout  = "timeout"
+	decisionCanceled = "canceled"
+	decisionError    = "error"
+)
+
+// approvalRecorder is an ApprovalRequester recording the decision of each
+// request it forwards.
+type approvalRecorder struct {
+	requester tool.ApprovalRequester
+	approvals *metrics.Counter
+}
+
+// RequestApproval implements tool.ApprovalRequester.
+func (a approvalRecorder) RequestApproval(ctx context.Context, req tool.ApprovalRequest) (tool.ApprovalResponse, error) {
+	resp, err := a.requester.RequestApproval(ctx, req)
+	a.approvals.Inc(req.ToolName, approvalDecision(ctx, resp, err))
+	return resp, err
+}
+
+// approvalDecision classifies the outcome of an approval request.
+func approvalDecision(ctx context.Context, resp tool.ApprovalResponse, err error) string {
+	switch {
+	case err == nil && resp.Approved:
+		return decisionApproved
+	case err == nil:
+		return decisionDenied
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return decisionTimeout
+	case errors.Is(ctx.Err(), context.Canceled):
+		return decisionCanceled
+	default:
+		return decisionError
+	}
+}
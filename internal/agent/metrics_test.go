@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/metrics"
+	"github.com/flemzord/sclaw/internal/metrics/metricstest"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/tool"
+)
+
+// requesterFunc adapts a function to tool.ApprovalRequester.
+type requesterFunc func(ctx context.Context, req tool.ApprovalRequest) (tool.ApprovalResponse, error)
+
+func (f requesterFunc) RequestApproval(ctx context.Context, req tool.ApprovalRequest) (tool.ApprovalResponse, error) {
+	return f(ctx, req)
+}
+
+func TestMetrics_Run(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	p := &mockProvider{
+		responses: []provider.CompletionResponse{
+			{ToolCalls: []provider.ToolCall{tc("1", "echo")}, FinishReason: provider.FinishReasonToolUse},
+			{Content: "done", FinishReason: provider.FinishReasonStop},
+		},
+	}
+	tools := tool.NewRegistry()
+	if err := tools.Register(&mockTool{name: "echo", output: tool.Output{Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	executor := NewToolExecutor(ToolExecutorConfig{
+		Registry:  tools,
+		PolicyCfg: tool.PolicyConfig{DM: tool.Policy{Default: tool.ApprovalAllow}},
+		PolicyCtx: tool.PolicyContextDM,
+		Metrics:   reg,
+	})
+	loop := NewLoop(p, executor, LoopConfig{Metrics: reg})
+
+	if _, err := loop.Run(context.Background(), Request{Messages: []provider.LLMMessage{userMsg("hi")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	metricstest.AssertLines(t, reg,
+		`sclaw_agent_runs_total{stop_reason="complete"} 1`,
+		`sclaw_agent_iterations_bucket{stop_reason="complete",le="1"} 0`,
+		`sclaw_agent_iterations_bucket{stop_reason="complete",le="2"} 1`,
+		`sclaw_agent_iterations_sum{stop_reason="complete"} 2`,
+		`sclaw_tool_executions_total{tool="echo",outcome="success"} 1`,
+		`sclaw_tool_duration_seconds_count{tool="echo",outcome="success"} 1`,
+	)
+}
+
+func TestMetrics_RunStream(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	call := func(i int) []provider.StreamChunk {
+		return []provider.StreamChunk{{ToolCalls: []provider.ToolCall{{
+			ID: fmt.Sprint(i), Name: "echo", Arguments: json.RawMessage(fmt.Sprintf(`{"i":%d}`, i)),
+		}}}}
+	}
+	p := &mockProvider{streams: [][]provider.StreamChunk{call(1), call(2)}}
+	loop := NewLoop(p, newLoopTestExecutor(&mockTool{name: "echo"}), LoopConfig{MaxIterations: 2, Metrics: reg})
+
+	events, err := loop.RunStream(context.Background(), Request{Messages: []provider.LLMMessage{userMsg("hi")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runErr error
+	for ev := range events {
+		if ev.Type == StreamEventError {
+			runErr = ev.Err
+		}
+	}
+	if !errors.Is(runErr, ErrMaxIterationsReached) {
+		t.Fatalf("error = %v, want ErrMaxIterationsReached", runErr)
+	}
+
+	metricstest.AssertLines(t, reg,
+		`sclaw_agent_runs_total{stop_reason="max_iterations"} 1`,
+		`sclaw_agent_iterations_sum{stop_reason="max_iterations"} 2`,
+	)
+}
+
+func TestMetrics_ToolOutcomes(t *testing.T) {
+	t.Parallel()
+
+	reg := tool.NewRegistry()
+	for _, mt := range []*mockTool{
+		{name: "ok"},
+		{name: "fails", err: errors.New("boom")},
+		{name: "crashes", panicMsg: "oops"},
+		{name: "forbidden"},
+		{name: "asks"},
+		{name: "refused"},
+	} {
+		if err := reg.Register(mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := metrics.NewRegistry()
+	executor := NewToolExecutor(ToolExecutorConfig{
+		Registry: reg,
+		PolicyCfg: tool.PolicyConfig{DM: tool.Policy{
+			Default: tool.ApprovalAllow,
+			Deny:    []string{"forbidden"},
+			Ask:     []string{"asks", "refused"},
+		}},
+		PolicyCtx: tool.PolicyContextDM,
+		Requester: requesterFunc(func(_ context.Context, req tool.ApprovalRequest) (tool.ApprovalResponse, error) {
+			return tool.ApprovalResponse{Approved: req.ToolName == "asks"}, nil
+		}),
+		ApprovalTimeout: time.Minute,
+		Metrics:         m,
+	})
+
+	executor.Execute(context.Background(), []provider.ToolCall{
+		tc("1", "ok"), tc("2", "fails"), tc("3", "crashes"), tc("4", "forbidden"),
+		tc("5", "asks"), tc("6", "refused"), tc("7", "made_up"),
+	})
+
+	metricstest.AssertLines(t, m,
+		`sclaw_tool_executions_total{tool="ok",outcome="success"} 1`,
+		`sclaw_tool_executions_total{tool="fails",outcome="error"} 1`,
+		`sclaw_tool_executions_total{tool="crashes",outcome="panic"} 1`,
+		`sclaw_tool_executions_total{tool="forbidden",outcome="denied"} 1`,
+		`sclaw_tool_executions_total{tool="asks",outcome="success"} 1`,
+		`sclaw_tool_executions_total{tool="refused",outcome="denied"} 1`,
+		`sclaw_tool_executions_total{tool="",outcome="unknown_tool"} 1`,
+		`sclaw_tool_approvals_total{tool="asks",decision="approved"} 1`,
+		`sclaw_tool_approvals_total{tool="refused",decision="denied"} 1`,
+	)
+}
+
+func TestApprovalDecision(t *testing.T) {
+	t.Parallel()
+
+	expired, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	canceled, cancel2 := context.WithCancel(context.Background())
+	cancel2()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		resp tool.ApprovalResponse
+		err  error
+		want string
+	}{
+		{"approved", context.Background(), tool.ApprovalResponse{Approved: true}, nil, decisionApproved},
+		{"denied", context.Background(), tool.ApprovalResponse{}, nil, decisionDenied},
+		{"timeout", expired, tool.ApprovalResponse{}, context.DeadlineExceeded, decisionTimeout},
+		{"canceled", canceled, tool.ApprovalResponse{}, context.Canceled, decisionCanceled},
+		{"error", context.Background(), tool.ApprovalResponse{}, errors.New("channel down"), decisionError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := approvalDecision(tt.ctx, tt.resp, tt.err); got != tt.want {
+				t.Errorf("approvalDecision() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopReasonFor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err  error
+		want StopReason
+	}{
+		{nil, StopReasonComplete},
+		{context.DeadlineExceeded, StopReasonTimeout},
+		{ErrTokenBudgetExceeded, StopReasonTokenBudget},
+		{ErrLoopDetected, StopReasonLoopDetected},
+		{ErrMaxIterationsReached, StopReasonMaxIterations},
+		{fmt.Errorf("calling provider: %w", provider.ErrProviderDown), StopReasonError},
+	}
+	for _, tt := range tests {
+		if got := stopReasonFor(tt.err); got != tt.want {
+			t.Errorf("stopReasonFor(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
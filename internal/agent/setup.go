@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/flemzord/sclaw/internal/prompt"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/skill"
+)
+
+// setupRequest prepares req for a run. It selects the skills activated by
+// the latest user message and, when they declare tools, restricts
+// req.Tools to those tools. The system prompt is then built by req.Prompt
+// when set, or extended with the skills' instructions otherwise. It
+// returns the names of the tools the run may execute, or nil when tools
+// are not restricted.
+func setupRequest(req Request) (Request, []string, error) {
+	var active []skill.Skill
+	if len(req.Skills) > 0 {
+		active = skill.Select(req.Skills, lastUserText(req.Messages))
+	}
+
+	allowed := skill.AllowedTools(active)
+	if allowed != nil {
+		tools := make([]provider.ToolDefinition, 0, len(allowed))
+		for _, def := range req.Tools {
+			if slices.Contains(allowed, def.Name) {
+				tools = append(tools, def)
+			}
+		}
+		req.Tools = tools
+	}
+
+	switch {
+	case req.Prompt != nil:
+		system, err := req.Prompt.Build(prompt.Input{
+			Persona:  req.SystemPrompt,
+			Inbound:  req.Inbound,
+			Tools:    req.Tools,
+			Skills:   active,
+			Memories: req.Memories,
+		})
+		if err != nil {
+			return req, nil, fmt.Errorf("agent: building system prompt: %w", err)
+		}
+		req.SystemPrompt = system
+	case len(active) > 0:
+		instructions := skill.Instructions(active)
+		if req.SystemPrompt == "" {
+			req.SystemPrompt = instructions
+		} else {
+			req.SystemPrompt += "\n\n" + instructions
+		}
+	}
+	return req, allowed, nil
+}
+
+// lastUserText returns the text of the last user message.
+func lastUserText(msgs []provider.LLMMessage) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == provider.MessageRoleUser {
+			return msgs[i].TextContent()
+		}
+	}
+	return ""
+}
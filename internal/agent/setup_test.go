@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/prompt"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/skill"
+	"github.com/flemzord/sclaw/internal/tool"
+)
+
+func TestRun_ActivatesSkills(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{
+		responses: []provider.CompletionResponse{
+			{ToolCalls: []provider.ToolCall{
+				{ID: "1", Name: "read", Arguments: json.RawMessage(`{}`)},
+				{ID: "2", Name: "write", Arguments: json.RawMessage(`{}`)},
+			}},
+			{Content: "done"},
+		},
+	}
+	executor := newLoopTestExecutor(
+		&mockTool{name: "read", output: tool.Output{Content: "file"}},
+		&mockTool{name: "write", output: tool.Output{Content: "written"}},
+	)
+	loop := newTestLoop(p, executor, LoopConfig{})
+
+	resp, err := loop.Run(context.Background(), Request{
+		Messages:     []provider.LLMMessage{userMsg("please review this")},
+		SystemPrompt: "You are helpful.",
+		Tools:        ToolDefinitions(executor.registry),
+		Skills: []skill.Skill{
+			{Name: "review", Triggers: []string{"review"}, Tools: []string{"read"}, Instructions: "Only read files."},
+			{Name: "deploy", Triggers: []string{"deploy"}, Instructions: "Ship it."},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	req := p.recorded()[0]
+	system := req.Messages[0].Content
+	if !strings.HasPrefix(system, "You are helpful.\n\n## Skill: review") || !strings.Contains(system, "Only read files.") {
+		t.Errorf("system prompt = %q, want base prompt then review skill", system)
+	}
+	if strings.Contains(system, "Ship it.") {
+		t.Error("inactive skill included in system prompt")
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Name != "read" {
+		t.Errorf("tools = %+v, want only read", req.Tools)
+	}
+
+	if out := resp.ToolCalls[0].Output; out.IsError {
+		t.Errorf("read output = %+v, want success", out)
+	}
+	if out := resp.ToolCalls[1].Output; !out.IsError || !strings.Contains(out.Content, "not available") {
+		t.Errorf("write output = %+v, want refusal", out)
+	}
+}
+
+func TestRun_NoActiveSkill(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{responses: []provider.CompletionResponse{{Content: "hi"}}}
+	executor := newLoopTestExecutor(&mockTool{name: "read"})
+	loop := newTestLoop(p, executor, LoopConfig{})
+
+	_, err := loop.Run(context.Background(), Request{
+		Messages: []provider.LLMMessage{userMsg("hello")},
+		Tools:    ToolDefinitions(executor.registry),
+		Skills:   []skill.Skill{{Name: "review", Triggers: []string{"review"}, Tools: []string{"grep"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := p.recorded()[0]
+	if len(req.Messages) != 1 || len(req.Tools) != 1 {
+		t.Errorf("request = %d messages, %d tools; want unchanged", len(req.Messages), len(req.Tools))
+	}
+}
+
+func TestRun_PromptEngine(t *testing.T) {
+	t.Parallel()
+
+	p := &mockProvider{responses: []provider.CompletionResponse{{Content: "hi"}}}
+	executor := newLoopTestExecutor(&mockTool{name: "read"}, &mockTool{name: "write"})
+	loop := newTestLoop(p, executor, LoopConfig{})
+
+	engine, err := prompt.NewEngine(
+		prompt.Part{Section: prompt.Persona(t.TempDir(), prompt.DefaultPersonaFile)},
+		prompt.Part{Section: prompt.Tools()},
+		prompt.Part{Section: prompt.Skills()},
+		prompt.Part{Section: prompt.Memories()},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = loop.Run(context.Background(), Request{
+		Messages:     []provider.LLMMessage{userMsg("/review main.go")},
+		SystemPrompt: "You are sclaw.",
+		Tools:        ToolDefinitions(executor.registry),
+		Skills:       []skill.Skill{{Name: "review", Tools: []string{"read"}, Instructions: "Read first."}},
+		Prompt:       engine,
+		Memories:     []string{"Prefers short answers"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	system := p.recorded()[0].Messages[0].Content
+	want := "You are sclaw.\n\nAvailable tools:\n- read: mock tool\n\n## Skill: review\n\nRead first.\n\n" +
+		"Relevant memories:\n- Prefers short answers"
+	if system != want {
+		t.Errorf("system prompt = %q, want %q", system, want)
+	}
+}
+
+func TestRun_PromptEngineError(t *testing.T) {
+	t.Parallel()
+
+	engine, err := prompt.NewEngine(prompt.Part{Section: prompt.Func("bad", func(*prompt.Input) (string, error) {
+		return "", errors.New("unreadable")
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{}
+	loop := newTestLoop(p, newLoopTestExecutor(), LoopConfig{})
+
+	resp, err := loop.Run(context.Background(), Request{Messages: []provider.LLMMessage{userMsg("hi")}, Prompt: engine})
+	if err == nil || resp.StopReason != StopReasonError {
+		t.Errorf("Run = %v (%s), want error", err, resp.StopReason)
+	}
+	if len(p.recorded()) != 0 {
+		t.Error("provider called despite prompt error")
+	}
+}
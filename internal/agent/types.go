@@ -7,8 +7,12 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/flemzord/sclaw/internal/hook"
+	"github.com/flemzord/sclaw/internal/prompt"
 	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/skill"
 	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/pkg/message"
 )
 
 // StopReason describes why the agent loop terminated.
@@ -45,6 +49,11 @@ const (
 	StreamEventDone      StreamEventType = "done"
 	StreamEventError     StreamEventType = "error"
 	StreamEventUsage     StreamEventType = "usage"
+
+	// StreamEventReset reports that the provider call restarted after a
+	// mid-stream failure: text received since the last tool event must be
+	// discarded.
+	StreamEventReset StreamEventType = "reset"
 )
 
 // StreamEvent is a single event emitted during a streaming agent loop.
@@ -54,14 +63,61 @@ type StreamEvent struct {
 	ToolCall *ToolCallRecord
 	Usage    *provider.TokenUsage
 	Err      error
+
+	// Messages is set on StreamEventDone and holds the messages produced
+	// by the run, in the same form as Response.Messages.
+	Messages []provider.LLMMessage
+
+	// History is set on StreamEventDone when the history was compacted,
+	// in the same form as Response.History.
+	History []provider.LLMMessage
 }
 
 // Request is the input to the agent loop.
+//
+// Messages may carry multimodal Parts. They are kept intact in the history
+// and only downgraded to text, per call, for providers that declare they
+// cannot accept them (see provider.ContentSupporter).
 type Request struct {
 	Messages     []provider.LLMMessage
 	SystemPrompt string
 	Tools        []provider.ToolDefinition
 	Config       LoopConfig
+
+	// Budgets are token budgets that outlive the run, such as a per-session
+	// or per-day allowance. Each call is refused or has its MaxTokens capped
+	// so that none of them is overrun, and usage is charged to all of them.
+	Budgets []Budget
+
+	// Hooks runs around provider calls and tool executions. Optional.
+	Hooks *hook.Pipeline
+
+	// Skills are the skills available to the run. Those activated by the
+	// latest user message add their instructions to the system prompt and
+	// may restrict Tools; see skill.Skill.
+	Skills []skill.Skill
+
+	// Prompt, when set, assembles the system prompt from SystemPrompt (as
+	// the fallback persona), Inbound, Tools, the active Skills and
+	// Memories.
+	Prompt *prompt.Engine
+
+	// Inbound is the message being answered, if any.
+	Inbound *message.InboundMessage
+
+	// Memories are retrieved memories relevant to the conversation, most
+	// relevant first. Only used by Prompt.
+	Memories []string
+}
+
+// Budget is a token allowance shared across loop runs.
+// Implementations must be safe for concurrent use.
+type Budget interface {
+	// Remaining returns the number of tokens still available.
+	Remaining() int
+
+	// Spend charges usage to the budget.
+	Spend(usage provider.TokenUsage)
 }
 
 // Response is the output of the agent loop.
@@ -71,4 +127,17 @@ type Response struct {
 	TotalUsage provider.TokenUsage
 	Iterations int
 	StopReason StopReason
+
+	// Messages holds the messages produced by the run, in order: assistant
+	// turns with their tool calls, tool results and, on completion, the
+	// final assistant reply. It never contains the request messages, so
+	// callers can append it to their stored history to continue the
+	// conversation.
+	Messages []provider.LLMMessage
+
+	// History is set when the run compacted the conversation to fit the
+	// context window. It holds the compacted request messages followed by
+	// Messages, without the system prompt, and should replace the caller's
+	// stored history so that the next turn starts from the compacted form.
+	History []provider.LLMMessage
 }
@@ -0,0 +1,169 @@
+// Package budget keeps token spending across agent runs and restarts. A
+// Ledger records how many tokens each key spent, in total or per day,
+// and hands out agent.Budget values that enforce a limit on a key.
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/agent"
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+// Period is the window over which spending is accumulated.
+type Period string
+
+// Period constants.
+const (
+	// PeriodTotal never resets.
+	PeriodTotal Period = "total"
+
+	// PeriodDay resets at local midnight.
+	PeriodDay Period = "day"
+)
+
+// dayLayout formats the current day of a PeriodDay counter.
+const dayLayout = "2006-01-02"
+
+// counter is the persisted spending of one key over one period.
+type counter struct {
+	// Window identifies the current window: the day for PeriodDay,
+	// empty for PeriodTotal.
+	Window string `json:"window,omitempty"`
+	Spent  int    `json:"spent"`
+}
+
+// Ledger records token spending per key and period in a JSON file.
+// It is safe for concurrent use.
+type Ledger struct {
+	path   string
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	counters map[string]*counter
+
+	// now is injectable for testing. Defaults to time.Now.
+	now func() time.Time
+}
+
+// Open loads the ledger stored at path, or starts an empty one if the
+// file does not exist yet. A nil logger discards logs.
+func Open(path string, logger *slog.Logger) (*Ledger, error) {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	l := &Ledger{
+		path:     path,
+		logger:   logger,
+		counters: make(map[string]*counter),
+		now:      time.Now,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("budget: reading ledger: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.counters); err != nil {
+		return nil, fmt.Errorf("budget: decoding ledger %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Spent returns the tokens spent by key in the current period.
+func (l *Ledger) Spent(key string, period Period) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.counters[counterKey(key, period)]
+	if !ok || c.Window != l.window(period) {
+		return 0
+	}
+	return c.Spent
+}
+
+// Add charges tokens to key in the current period and persists the
+// ledger. A failure to persist is logged: the in-memory count stays
+// correct for the running process.
+func (l *Ledger) Add(key string, period Period, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id := counterKey(key, period)
+	window := l.window(period)
+	c, ok := l.counters[id]
+	if !ok || c.Window != window {
+		c = &counter{Window: window}
+		l.counters[id] = c
+	}
+	c.Spent += tokens
+
+	if err := l.saveLocked(); err != nil {
+		l.logger.Error("persisting token ledger failed", "path", l.path, "error", err)
+	}
+}
+
+// Limit returns a budget of limit tokens per period for key.
+func (l *Ledger) Limit(key string, period Period, limit int) agent.Budget {
+	return &limitBudget{ledger: l, key: key, period: period, limit: limit}
+}
+
+// window returns the identifier of the current period window.
+// Must be called with l.mu held.
+func (l *Ledger) window(period Period) string {
+	if period == PeriodDay {
+		return l.now().Format(dayLayout)
+	}
+	return ""
+}
+
+// saveLocked writes the ledger atomically. Must be called with l.mu held.
+func (l *Ledger) saveLocked() error {
+	data, err := json.MarshalIndent(l.counters, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func counterKey(key string, period Period) string {
+	return string(period) + ":" + key
+}
+
+// limitBudget is an agent.Budget backed by a Ledger counter.
+type limitBudget struct {
+	ledger *Ledger
+	key    string
+	period Period
+	limit  int
+}
+
+// Remaining implements agent.Budget.
+func (b *limitBudget) Remaining() int {
+	return max(b.limit-b.ledger.Spent(b.key, b.period), 0)
+}
+
+// Spend implements agent.Budget.
+func (b *limitBudget) Spend(usage provider.TokenUsage) {
+	b.ledger.Add(b.key, b.period, usage.TotalTokens)
+}
+
+// Interface guard.
+var _ agent.Budget = (*limitBudget)(nil)
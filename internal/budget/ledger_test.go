@@ -0,0 +1,94 @@
+package budget
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+func openTestLedger(t *testing.T, path string, now *time.Time) *Ledger {
+	t.Helper()
+	l, err := Open(path, nil)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestLedger_LimitBudget(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	l := openTestLedger(t, filepath.Join(t.TempDir(), "budget.json"), &now)
+
+	b := l.Limit("session:a", PeriodTotal, 1000)
+	if got := b.Remaining(); got != 1000 {
+		t.Fatalf("Remaining = %d, want 1000", got)
+	}
+	b.Spend(provider.TokenUsage{PromptTokens: 500, CompletionTokens: 100, TotalTokens: 600})
+	b.Spend(provider.TokenUsage{TotalTokens: 600})
+	if got := b.Remaining(); got != 0 {
+		t.Errorf("Remaining = %d, want 0 once overspent", got)
+	}
+	if got := l.Spent("session:a", PeriodTotal); got != 1200 {
+		t.Errorf("Spent = %d, want 1200", got)
+	}
+	if got := l.Spent("session:b", PeriodTotal); got != 0 {
+		t.Errorf("other key Spent = %d, want 0", got)
+	}
+}
+
+func TestLedger_DailyReset(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.Local)
+	l := openTestLedger(t, filepath.Join(t.TempDir(), "budget.json"), &now)
+
+	daily := l.Limit("global", PeriodDay, 100)
+	total := l.Limit("global", PeriodTotal, 1000)
+	for _, b := range []interface{ Spend(provider.TokenUsage) }{daily, total} {
+		b.Spend(provider.TokenUsage{TotalTokens: 80})
+	}
+	if got := daily.Remaining(); got != 20 {
+		t.Errorf("daily Remaining = %d, want 20", got)
+	}
+
+	now = now.Add(2 * time.Hour) // next day
+	if got := daily.Remaining(); got != 100 {
+		t.Errorf("daily Remaining after midnight = %d, want 100", got)
+	}
+	if got := total.Remaining(); got != 920 {
+		t.Errorf("total Remaining = %d, want 920", got)
+	}
+}
+
+func TestLedger_PersistsAcrossOpen(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	path := filepath.Join(t.TempDir(), "nested", "budget.json")
+
+	first := openTestLedger(t, path, &now)
+	first.Add("session:a", PeriodDay, 42)
+
+	second := openTestLedger(t, path, &now)
+	if got := second.Spent("session:a", PeriodDay); got != 42 {
+		t.Errorf("Spent after reopen = %d, want 42", got)
+	}
+}
+
+func TestOpen_Corrupt(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "budget.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, nil); err == nil {
+		t.Error("expected error for corrupt ledger")
+	}
+}
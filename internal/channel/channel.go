@@ -0,0 +1,127 @@
+// Package channel defines the contract between messaging channel modules
+// (Telegram, Discord, a local terminal...) and the rest of sclaw. A channel
+// pushes the messages it receives into an Inbox and delivers the outbound
+// messages it is handed.
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// Sentinel errors for channel operations.
+var (
+	// ErrNoInbox is returned by Start when no inbox has been attached.
+	ErrNoInbox = errors.New("channel: no inbox attached")
+
+	// ErrUnknownChannel is returned when sending to a channel that is not
+	// registered with the dispatcher.
+	ErrUnknownChannel = errors.New("channel: unknown channel")
+
+	// ErrDuplicateChannel is returned when adding a channel whose ID is
+	// already registered with the dispatcher.
+	ErrDuplicateChannel = errors.New("channel: already registered")
+)
+
+// Inbox receives the messages a channel pulls from its platform.
+// Enqueue must not block on message processing; *router.Router
+// satisfies it.
+type Inbox interface {
+	Enqueue(msg message.InboundMessage) error
+}
+
+// InboxFunc adapts a function to the Inbox interface.
+type InboxFunc func(msg message.InboundMessage) error
+
+// Enqueue calls f(msg).
+func (f InboxFunc) Enqueue(msg message.InboundMessage) error {
+	return f(msg)
+}
+
+// Channel is the interface implemented by messaging channel modules.
+//
+// The lifecycle is: Attach → Start → (inbound via Inbox, outbound via
+// Send) → Stop. Inbound messages must have InboundMessage.Channel set to
+// the module ID so replies can be routed back.
+type Channel interface {
+	core.Module
+	core.Starter
+	core.Stopper
+
+	// Attach sets the inbox that receives inbound messages.
+	// It is called once, before Start.
+	Attach(inbox Inbox)
+
+	// Send delivers an outbound message to the platform.
+	Send(ctx context.Context, msg message.OutboundMessage) error
+}
+
+// Approver is an optional interface for channels that can ask users to
+// confirm tool executions in a chat (inline buttons, reactions...).
+type Approver interface {
+	ApprovalRequester(chat message.Chat) tool.ApprovalRequester
+}
+
+// Dispatcher routes outbound messages to the channel they belong to.
+// It satisfies router.Sender.
+type Dispatcher struct {
+	mu       sync.RWMutex
+	channels map[string]Channel
+}
+
+// NewDispatcher creates an empty dispatcher.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{channels: make(map[string]Channel)}
+}
+
+// Add registers a channel under its module ID.
+func (d *Dispatcher) Add(ch Channel) error {
+	id := string(ch.ModuleInfo().ID)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.channels[id]; exists {
+		return fmt.Errorf("%w: %s", ErrDuplicateChannel, id)
+	}
+	d.channels[id] = ch
+	return nil
+}
+
+// Get returns the channel registered under id.
+func (d *Dispatcher) Get(id string) (Channel, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	ch, ok := d.channels[id]
+	return ch, ok
+}
+
+// Send delivers msg through the channel registered under id.
+func (d *Dispatcher) Send(ctx context.Context, id string, msg message.OutboundMessage) error {
+	ch, ok := d.Get(id)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownChannel, id)
+	}
+	return ch.Send(ctx, msg)
+}
+
+// Approvals returns the approval requester of the channel that received
+// msg, or nil when that channel does not implement Approver. Its signature
+// matches router.Config.Approvals.
+func (d *Dispatcher) Approvals(msg *message.InboundMessage) tool.ApprovalRequester {
+	ch, ok := d.Get(msg.Channel)
+	if !ok {
+		return nil
+	}
+	a, ok := ch.(Approver)
+	if !ok {
+		return nil
+	}
+	return a.ApprovalRequester(msg.Chat)
+}
@@ -0,0 +1,117 @@
+package channel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/channel"
+	"github.com/flemzord/sclaw/internal/channel/loopback"
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/internal/tool/tooltest"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// approvingChannel is a loopback channel that also implements channel.Approver.
+type approvingChannel struct {
+	*loopback.Channel
+	requester tool.ApprovalRequester
+	gotChat   message.Chat
+}
+
+func (a *approvingChannel) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{ID: "channel.approving", New: func() core.Module { return a }}
+}
+
+func (a *approvingChannel) ApprovalRequester(chat message.Chat) tool.ApprovalRequester {
+	a.gotChat = chat
+	return a.requester
+}
+
+func TestDispatcher_Send(t *testing.T) {
+	t.Parallel()
+
+	ch := loopback.New()
+	d := channel.NewDispatcher()
+	if err := d.Add(ch); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	out := message.NewTextMessage(message.Chat{ID: "c1", Type: message.ChatDM}, "hi")
+	if err := d.Send(context.Background(), loopback.ModuleID, out); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := <-ch.Outbox()
+	if got.TextContent() != "hi" {
+		t.Errorf("delivered text = %q, want %q", got.TextContent(), "hi")
+	}
+}
+
+func TestDispatcher_UnknownChannel(t *testing.T) {
+	t.Parallel()
+
+	d := channel.NewDispatcher()
+	err := d.Send(context.Background(), "channel.nope", message.OutboundMessage{})
+	if !errors.Is(err, channel.ErrUnknownChannel) {
+		t.Errorf("err = %v, want ErrUnknownChannel", err)
+	}
+}
+
+func TestDispatcher_Duplicate(t *testing.T) {
+	t.Parallel()
+
+	d := channel.NewDispatcher()
+	if err := d.Add(loopback.New()); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Add(loopback.New()); !errors.Is(err, channel.ErrDuplicateChannel) {
+		t.Errorf("err = %v, want ErrDuplicateChannel", err)
+	}
+}
+
+func TestDispatcher_Approvals(t *testing.T) {
+	t.Parallel()
+
+	requester := &tooltest.MockApprovalRequester{}
+	approving := &approvingChannel{Channel: loopback.New(), requester: requester}
+
+	d := channel.NewDispatcher()
+	if err := d.Add(loopback.New()); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Add(approving); err != nil {
+		t.Fatal(err)
+	}
+
+	chat := message.Chat{ID: "c1", Type: message.ChatGroup}
+	if got := d.Approvals(&message.InboundMessage{Channel: "channel.approving", Chat: chat}); got != requester {
+		t.Errorf("Approvals() = %v, want channel requester", got)
+	}
+	if approving.gotChat != chat {
+		t.Errorf("requester bound to chat %+v, want %+v", approving.gotChat, chat)
+	}
+	if got := d.Approvals(&message.InboundMessage{Channel: loopback.ModuleID}); got != nil {
+		t.Errorf("Approvals() for non-approver = %v, want nil", got)
+	}
+	if got := d.Approvals(&message.InboundMessage{Channel: "channel.nope"}); got != nil {
+		t.Errorf("Approvals() for unknown channel = %v, want nil", got)
+	}
+}
+
+func TestInboxFunc(t *testing.T) {
+	t.Parallel()
+
+	var got message.InboundMessage
+	inbox := channel.InboxFunc(func(msg message.InboundMessage) error {
+		got = msg
+		return nil
+	})
+	if err := inbox.Enqueue(message.InboundMessage{ID: "m1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "m1" {
+		t.Errorf("got ID %q, want m1", got.ID)
+	}
+}
@@ -0,0 +1,118 @@
+// Package loopback provides an in-process channel. Inbound messages are
+// injected programmatically and outbound messages are read back from a Go
+// channel, which makes it suitable for tests and embedding.
+package loopback
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/flemzord/sclaw/internal/channel"
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+func init() {
+	core.RegisterModule(&Channel{})
+}
+
+// ModuleID is the identifier of the loopback channel module.
+const ModuleID = "channel.loopback"
+
+// outboxSize is the number of outbound messages buffered before Send blocks.
+const outboxSize = 64
+
+// ErrNotStarted is returned by Inject when the channel is not running.
+var ErrNotStarted = errors.New("loopback: channel not started")
+
+// Channel is an in-process channel.Channel implementation.
+type Channel struct {
+	mu      sync.Mutex
+	inbox   channel.Inbox
+	started bool
+
+	outOnce sync.Once
+	out     chan message.OutboundMessage
+}
+
+// New creates a ready-to-attach loopback channel.
+func New() *Channel {
+	return &Channel{}
+}
+
+// ModuleInfo implements core.Module.
+func (*Channel) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{
+		ID:  ModuleID,
+		New: func() core.Module { return New() },
+	}
+}
+
+// Attach implements channel.Channel.
+func (c *Channel) Attach(inbox channel.Inbox) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inbox = inbox
+}
+
+// Start implements core.Starter.
+func (c *Channel) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inbox == nil {
+		return channel.ErrNoInbox
+	}
+	c.started = true
+	return nil
+}
+
+// Stop implements core.Stopper.
+func (c *Channel) Stop(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started = false
+	return nil
+}
+
+// Inject pushes msg into the attached inbox as if it had been received
+// from a platform. An empty msg.Channel is set to ModuleID.
+func (c *Channel) Inject(msg message.InboundMessage) error {
+	c.mu.Lock()
+	inbox, started := c.inbox, c.started
+	c.mu.Unlock()
+
+	if !started {
+		return ErrNotStarted
+	}
+	if msg.Channel == "" {
+		msg.Channel = ModuleID
+	}
+	return inbox.Enqueue(msg)
+}
+
+// Send implements channel.Channel. It blocks when the outbox is full
+// until a message is read or ctx is done.
+func (c *Channel) Send(ctx context.Context, msg message.OutboundMessage) error {
+	select {
+	case c.outbox() <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Outbox returns the channel on which sent messages are delivered.
+func (c *Channel) Outbox() <-chan message.OutboundMessage {
+	return c.outbox()
+}
+
+func (c *Channel) outbox() chan message.OutboundMessage {
+	c.outOnce.Do(func() {
+		c.out = make(chan message.OutboundMessage, outboxSize)
+	})
+	return c.out
+}
+
+// Interface guard.
+var _ channel.Channel = (*Channel)(nil)
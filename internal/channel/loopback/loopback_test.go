@@ -0,0 +1,139 @@
+package loopback
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/channel"
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+	"github.com/flemzord/sclaw/internal/router"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+func TestModuleRegistered(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := core.GetModule(ModuleID); !ok {
+		t.Fatalf("module %s not registered", ModuleID)
+	}
+}
+
+func TestStart_RequiresInbox(t *testing.T) {
+	t.Parallel()
+
+	if err := New().Start(); !errors.Is(err, channel.ErrNoInbox) {
+		t.Errorf("Start() = %v, want ErrNoInbox", err)
+	}
+}
+
+func TestInject_NotStarted(t *testing.T) {
+	t.Parallel()
+
+	c := New()
+	c.Attach(channel.InboxFunc(func(message.InboundMessage) error { return nil }))
+	if err := c.Inject(message.InboundMessage{}); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("Inject() = %v, want ErrNotStarted", err)
+	}
+}
+
+func TestInject_SetsChannel(t *testing.T) {
+	t.Parallel()
+
+	var got message.InboundMessage
+	c := New()
+	c.Attach(channel.InboxFunc(func(msg message.InboundMessage) error {
+		got = msg
+		return nil
+	}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Inject(message.InboundMessage{ID: "m1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Channel != ModuleID {
+		t.Errorf("Channel = %q, want %q", got.Channel, ModuleID)
+	}
+
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Inject(message.InboundMessage{}); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("Inject() after Stop = %v, want ErrNotStarted", err)
+	}
+}
+
+func TestSend_ContextCancelledWhenFull(t *testing.T) {
+	t.Parallel()
+
+	c := New()
+	for i := 0; i < outboxSize; i++ {
+		if err := c.Send(context.Background(), message.OutboundMessage{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Send(ctx, message.OutboundMessage{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Send() on full outbox = %v, want context.Canceled", err)
+	}
+}
+
+// TestRoundTrip_WithRouter wires the loopback channel to a router and
+// checks that an injected message produces a threaded reply.
+func TestRoundTrip_WithRouter(t *testing.T) {
+	t.Parallel()
+
+	c := New()
+	d := channel.NewDispatcher()
+	if err := d.Add(c); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &providertest.MockProvider{
+		ContextWindowSizeFunc: func() int { return 4096 },
+		CompleteFunc: func(_ context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+			return provider.CompletionResponse{Content: "pong: " + req.Messages[len(req.Messages)-1].Content}, nil
+		},
+	}
+	r, err := router.New(router.Config{
+		Resolver:  router.StaticResolver(&router.Agent{ID: "main", Provider: p}),
+		Sender:    d,
+		Approvals: d.Approvals,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close(context.Background()) })
+
+	c.Attach(r)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Inject(message.InboundMessage{
+		ID:       "m1",
+		Chat:     message.Chat{ID: "c1", Type: message.ChatDM},
+		ThreadID: "t1",
+		Blocks:   []message.ContentBlock{message.NewTextBlock("ping")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case out := <-c.Outbox():
+		if out.TextContent() != "pong: ping" {
+			t.Errorf("reply = %q, want %q", out.TextContent(), "pong: ping")
+		}
+		if out.ReplyToID != "m1" || out.ThreadID != "t1" {
+			t.Errorf("reply routing = %+v", out)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reply received")
+	}
+}
@@ -0,0 +1,74 @@
+package stdio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/flemzord/sclaw/internal/tool"
+)
+
+// ReadLines reads r line by line in the background and delivers each line,
+// without its trailing newline, on the returned channel. The channel is
+// closed at EOF or on read error.
+func ReadLines(r io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+// Approver is a tool.ApprovalRequester that asks the local user to confirm
+// tool executions. The question is written to Out and the answer is the
+// next line received from Answers; "y" or "yes" approves, anything else
+// denies. Concurrent requests are asked one at a time.
+type Approver struct {
+	Out     io.Writer
+	Answers <-chan string
+
+	mu sync.Mutex
+}
+
+// RequestApproval implements tool.ApprovalRequester.
+func (a *Approver) RequestApproval(ctx context.Context, req tool.ApprovalRequest) (tool.ApprovalResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	fmt.Fprintf(a.Out, "\nTool %q requests approval", req.ToolName)
+	if req.Description != "" {
+		fmt.Fprintf(a.Out, " (%s)", req.Description)
+	}
+	fmt.Fprintln(a.Out)
+	if len(req.Arguments) > 0 {
+		fmt.Fprintf(a.Out, "  arguments: %s\n", req.Arguments)
+	}
+	fmt.Fprint(a.Out, "Allow? [y/N] ")
+
+	select {
+	case line, ok := <-a.Answers:
+		if !ok {
+			fmt.Fprintln(a.Out)
+			return tool.ApprovalResponse{Approved: false, Reason: "input closed"}, nil
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return tool.ApprovalResponse{Approved: true}, nil
+		default:
+			return tool.ApprovalResponse{Approved: false, Reason: "denied by user"}, nil
+		}
+	case <-ctx.Done():
+		fmt.Fprintln(a.Out)
+		return tool.ApprovalResponse{}, ctx.Err()
+	}
+}
+
+// Interface guard.
+var _ tool.ApprovalRequester = (*Approver)(nil)
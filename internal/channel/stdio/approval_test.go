@@ -0,0 +1,95 @@
+package stdio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/tool"
+)
+
+func TestReadLines(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	for line := range ReadLines(strings.NewReader("a\nb\n\nc")) {
+		got = append(got, line)
+	}
+	want := []string{"a", "b", "", "c"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
+func TestApprover_Answers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"y", true},
+		{" YES ", true},
+		{"n", false},
+		{"", false},
+		{"sure", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			t.Parallel()
+
+			answers := make(chan string, 1)
+			answers <- tt.answer
+			var out bytes.Buffer
+			a := &Approver{Out: &out, Answers: answers}
+
+			resp, err := a.RequestApproval(context.Background(), tool.ApprovalRequest{
+				ToolName:    "shell",
+				Description: "run a command",
+				Arguments:   json.RawMessage(`{"cmd":"ls"}`),
+			})
+			if err != nil {
+				t.Fatalf("RequestApproval: %v", err)
+			}
+			if resp.Approved != tt.want {
+				t.Errorf("Approved = %v, want %v", resp.Approved, tt.want)
+			}
+			for _, want := range []string{`"shell"`, "run a command", `{"cmd":"ls"}`, "[y/N]"} {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("prompt %q missing %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestApprover_ClosedInputDenies(t *testing.T) {
+	t.Parallel()
+
+	answers := make(chan string)
+	close(answers)
+	a := &Approver{Out: &bytes.Buffer{}, Answers: answers}
+
+	resp, err := a.RequestApproval(context.Background(), tool.ApprovalRequest{ToolName: "shell"})
+	if err != nil {
+		t.Fatalf("RequestApproval: %v", err)
+	}
+	if resp.Approved {
+		t.Error("expected denial on closed input")
+	}
+}
+
+func TestApprover_ContextCancelled(t *testing.T) {
+	t.Parallel()
+
+	a := &Approver{Out: &bytes.Buffer{}, Answers: make(chan string)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := a.RequestApproval(ctx, tool.ApprovalRequest{ToolName: "shell"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
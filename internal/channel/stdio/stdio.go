@@ -0,0 +1,203 @@
+// Package stdio provides a channel bound to the process's standard input
+// and output. Each input line becomes a direct message from the local user
+// and replies are printed as they arrive, making it a minimal way to talk
+// to an agent from a terminal.
+package stdio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/channel"
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+func init() {
+	core.RegisterModule(&Channel{})
+}
+
+// ModuleID is the identifier of the stdio channel module.
+const ModuleID = "channel.stdio"
+
+// ChatID is the identifier of the single conversation held over stdio.
+const ChatID = "stdio"
+
+// Channel reads inbound lines from In and writes outbound messages to Out.
+type Channel struct {
+	// In is read line by line. Default: os.Stdin.
+	In io.Reader
+
+	// Out receives outbound messages. Default: os.Stdout.
+	Out io.Writer
+
+	// SenderID identifies the local user. Default: $USER, or "local".
+	SenderID string
+
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	inbox   channel.Inbox
+	stopped bool
+	seq     int
+
+	writeMu sync.Mutex
+
+	// done is closed when the reader goroutine exits.
+	done chan struct{}
+
+	// now is injectable for testing. Defaults to time.Now.
+	now func() time.Time
+}
+
+// ModuleInfo implements core.Module.
+func (*Channel) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{
+		ID:  ModuleID,
+		New: func() core.Module { return &Channel{} },
+	}
+}
+
+// Provision implements core.Provisioner.
+func (c *Channel) Provision(ctx *core.AppContext) error {
+	c.logger = ctx.Logger
+	return nil
+}
+
+// Attach implements channel.Channel.
+func (c *Channel) Attach(inbox channel.Inbox) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inbox = inbox
+}
+
+// defaults fills zero-value fields with sensible defaults.
+func (c *Channel) defaults() {
+	if c.In == nil {
+		c.In = os.Stdin
+	}
+	if c.Out == nil {
+		c.Out = os.Stdout
+	}
+	if c.SenderID == "" {
+		c.SenderID = os.Getenv("USER")
+	}
+	if c.SenderID == "" {
+		c.SenderID = "local"
+	}
+	if c.logger == nil {
+		c.logger = slog.New(slog.DiscardHandler)
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+}
+
+// Start implements core.Starter. It begins reading input in the background.
+func (c *Channel) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inbox == nil {
+		return channel.ErrNoInbox
+	}
+	c.defaults()
+	c.stopped = false
+	c.done = make(chan struct{})
+
+	go c.readLoop(c.In, c.done)
+	return nil
+}
+
+// Stop implements core.Stopper. Reads from In cannot be interrupted, so
+// Stop does not wait for the reader: lines read afterwards are dropped.
+func (c *Channel) Stop(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	return nil
+}
+
+// Done returns a channel closed once input reaches EOF or fails.
+// It is nil before Start.
+func (c *Channel) Done() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done
+}
+
+func (c *Channel) readLoop(in io.Reader, done chan struct{}) {
+	defer close(done)
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		c.mu.Lock()
+		if c.stopped {
+			c.mu.Unlock()
+			return
+		}
+		c.seq++
+		msg := message.InboundMessage{
+			ID:        fmt.Sprintf("stdio-%d", c.seq),
+			Timestamp: c.now(),
+			Channel:   ModuleID,
+			Sender:    message.Sender{ID: c.SenderID, Username: c.SenderID},
+			Chat:      message.Chat{ID: ChatID, Type: message.ChatDM},
+			Blocks:    []message.ContentBlock{message.NewTextBlock(text)},
+		}
+		inbox := c.inbox
+		c.mu.Unlock()
+
+		if err := inbox.Enqueue(msg); err != nil {
+			c.logger.Error("dropping inbound line", "error", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		c.logger.Error("reading input failed", "error", err)
+	}
+}
+
+// Send implements channel.Channel. Text blocks are written as-is; media
+// blocks are written as a one-line reference.
+func (c *Channel) Send(_ context.Context, msg message.OutboundMessage) error {
+	var b strings.Builder
+	for _, block := range msg.Blocks {
+		switch block.Type {
+		case message.BlockText:
+			b.WriteString(block.Text)
+		case message.BlockImage, message.BlockAudio, message.BlockFile:
+			fmt.Fprintf(&b, "[%s: %s]", block.Type, block.URL)
+		default:
+			continue
+		}
+		b.WriteByte('\n')
+	}
+
+	c.mu.Lock()
+	c.defaults()
+	out := c.Out
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// Interface guards.
+var (
+	_ channel.Channel  = (*Channel)(nil)
+	_ core.Provisioner = (*Channel)(nil)
+)
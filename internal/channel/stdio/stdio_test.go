@@ -0,0 +1,98 @@
+package stdio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/channel"
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+func TestModuleRegistered(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := core.GetModule(ModuleID); !ok {
+		t.Fatalf("module %s not registered", ModuleID)
+	}
+}
+
+func TestStart_RequiresInbox(t *testing.T) {
+	t.Parallel()
+
+	c := &Channel{In: strings.NewReader("")}
+	if err := c.Start(); !errors.Is(err, channel.ErrNoInbox) {
+		t.Errorf("Start() = %v, want ErrNoInbox", err)
+	}
+}
+
+func TestReadLoop_EnqueuesLines(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var got []message.InboundMessage
+
+	ts := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := &Channel{
+		In:       strings.NewReader("hello\n\n  world  \n"),
+		SenderID: "alice",
+		now:      func() time.Time { return ts },
+	}
+	c.Attach(channel.InboxFunc(func(msg message.InboundMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, msg)
+		return nil
+	}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader did not finish")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 {
+		t.Fatalf("got %d messages, want 2 (blank lines skipped)", len(got))
+	}
+	first := got[0]
+	if first.ID != "stdio-1" || first.Channel != ModuleID || first.Sender.ID != "alice" {
+		t.Errorf("unexpected message metadata: %+v", first)
+	}
+	if first.Chat.ID != ChatID || !first.IsDirectMessage() {
+		t.Errorf("Chat = %+v, want DM %q", first.Chat, ChatID)
+	}
+	if !first.Timestamp.Equal(ts) {
+		t.Errorf("Timestamp = %v, want %v", first.Timestamp, ts)
+	}
+	if got[1].TextContent() != "world" {
+		t.Errorf("second text = %q, want %q", got[1].TextContent(), "world")
+	}
+}
+
+func TestSend_WritesText(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	c := &Channel{Out: &out}
+
+	msg := message.NewTextMessage(message.Chat{ID: ChatID, Type: message.ChatDM}, "hi there")
+	msg.Blocks = append(msg.Blocks, message.NewImageBlock("https://example.com/a.png", "image/png"))
+	if err := c.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "hi there\n[image: https://example.com/a.png]\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
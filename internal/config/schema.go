@@ -2,7 +2,11 @@
 // expansion, and structural validation for sclaw.
 package config
 
-import "gopkg.in/yaml.v3"
+import (
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
 
 // Config is the top-level configuration structure.
 type Config struct {
@@ -12,4 +16,57 @@ type Config struct {
 	// Modules maps module IDs to their raw YAML configuration.
 	// Keys must match registered module IDs (e.g. "channel.telegram").
 	Modules map[string]yaml.Node `yaml:"modules"`
+
+	// Hooks configures the hook pipeline.
+	Hooks HooksConfig `yaml:"hooks"`
+
+	// Providers configures the provider chain.
+	Providers ProvidersConfig `yaml:"providers"`
+
+	// Budgets configures the token budgets kept across agent runs.
+	Budgets BudgetsConfig `yaml:"budgets"`
+
+	// Admin configures the admin HTTP listener.
+	Admin AdminConfig `yaml:"admin"`
+}
+
+// AdminConfig configures the admin HTTP listener, which exposes runtime
+// metrics in the OpenMetrics format on /metrics.
+type AdminConfig struct {
+	// Addr is the host:port to listen on, such as "127.0.0.1:9090". Empty
+	// disables the listener.
+	Addr string `yaml:"addr"`
+}
+
+// BudgetsConfig caps the tokens the agent spends across runs and
+// restarts. Spending is recorded in the data directory. Zero disables a
+// cap.
+type BudgetsConfig struct {
+	// Session caps the tokens spent in one conversation.
+	Session int `yaml:"session"`
+
+	// Day caps the tokens spent per day across all conversations. It
+	// resets at local midnight.
+	Day int `yaml:"day"`
+}
+
+// ProvidersConfig configures the provider chain.
+type ProvidersConfig struct {
+	// Strategies maps a role to the load balancing strategy across its
+	// providers: ordered, round_robin, weighted, least_latency or
+	// least_cost. Roles not listed use ordered.
+	Strategies map[string]string `yaml:"strategies"`
+
+	// Hedging maps a role to the delay after which a request still
+	// unanswered, or a stream without its first chunk, is also sent to
+	// the next provider of the role, such as "2s". Roles not listed are
+	// not hedged.
+	Hedging map[string]time.Duration `yaml:"hedging"`
+}
+
+// HooksConfig configures the hook pipeline.
+type HooksConfig struct {
+	// Order lists hook module IDs in the order they run. Hook modules
+	// not listed run after them, sorted by ID.
+	Order []string `yaml:"order"`
 }
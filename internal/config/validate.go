@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
 
 	"github.com/flemzord/sclaw/internal/core"
 )
@@ -10,7 +11,9 @@ import (
 // Validate checks the structural validity of a Config.
 // It verifies the version field, ensures modules are present,
 // and checks that all referenced module IDs exist in the registry.
-// It also enforces that Configurable modules have a config entry.
+// It also enforces that Configurable modules not marked Optional have a
+// config entry, that hooks.order only names configured modules, that
+// budgets are not negative and that admin.addr is a host:port.
 func Validate(cfg *Config) error {
 	var errs []error
 
@@ -30,8 +33,37 @@ func Validate(cfg *Config) error {
 		}
 	}
 
-	// Strict check: registered Configurable modules must have a config entry.
+	seen := make(map[string]bool, len(cfg.Hooks.Order))
+	for _, id := range cfg.Hooks.Order {
+		if seen[id] {
+			errs = append(errs, fmt.Errorf("config: hook %q listed twice in hooks.order", id))
+			continue
+		}
+		seen[id] = true
+		if _, ok := cfg.Modules[id]; !ok {
+			errs = append(errs, fmt.Errorf("config: hooks.order references %q, which is not a configured module", id))
+		}
+	}
+
+	if cfg.Budgets.Session < 0 {
+		errs = append(errs, errors.New("config: budgets.session must not be negative"))
+	}
+	if cfg.Budgets.Day < 0 {
+		errs = append(errs, errors.New("config: budgets.day must not be negative"))
+	}
+
+	if cfg.Admin.Addr != "" {
+		if _, _, err := net.SplitHostPort(cfg.Admin.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("config: admin.addr: %w", err))
+		}
+	}
+
+	// Strict check: registered Configurable modules must have a config
+	// entry, unless optional.
 	for _, info := range core.GetModules() {
+		if info.Optional {
+			continue
+		}
 		mod := info.New()
 		if _, ok := mod.(core.Configurable); ok {
 			if _, exists := cfg.Modules[string(info.ID)]; !exists {
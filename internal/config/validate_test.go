@@ -34,6 +34,17 @@ func (m *configurableModule) ModuleInfo() core.ModuleInfo {
 
 func (m *configurableModule) Configure(_ *yaml.Node) error { return nil }
 
+// optionalModule is a Configurable module marked Optional.
+type optionalModule struct {
+	configurableModule
+}
+
+func (m *optionalModule) ModuleInfo() core.ModuleInfo {
+	info := m.configurableModule.ModuleInfo()
+	info.Optional = true
+	return info
+}
+
 func registerStub(t *testing.T, id string) {
 	t.Helper()
 	core.RegisterModule(&stubModule{id: id})
@@ -132,6 +143,78 @@ func TestValidate_MultipleUnknown(t *testing.T) {
 	}
 }
 
+func TestValidate_HooksOrder(t *testing.T) {
+	id := t.Name() + ".hook"
+	registerStub(t, id)
+
+	cfg := &Config{
+		Version: "1",
+		Modules: map[string]yaml.Node{id: {}},
+		Hooks:   HooksConfig{Order: []string{id}},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.Hooks.Order = []string{id, id, "hook.missing"}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected error for invalid hooks.order")
+	}
+	if !strings.Contains(err.Error(), "listed twice") {
+		t.Errorf("error should mention duplicate: %v", err)
+	}
+	if !strings.Contains(err.Error(), "hook.missing") {
+		t.Errorf("error should mention hook.missing: %v", err)
+	}
+}
+
+func TestValidate_AdminAddr(t *testing.T) {
+	id := t.Name() + ".mod"
+	registerStub(t, id)
+
+	cfg := &Config{
+		Version: "1",
+		Modules: map[string]yaml.Node{id: {}},
+		Admin:   AdminConfig{Addr: "127.0.0.1:9090"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.Admin.Addr = "9090"
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected error for admin.addr without port")
+	}
+	if !strings.Contains(err.Error(), "admin.addr") {
+		t.Errorf("error should mention admin.addr: %v", err)
+	}
+}
+
+func TestValidate_Budgets(t *testing.T) {
+	id := t.Name() + ".mod"
+	registerStub(t, id)
+
+	cfg := &Config{
+		Version: "1",
+		Modules: map[string]yaml.Node{id: {}},
+		Budgets: BudgetsConfig{Session: 1000, Day: 5000},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.Budgets.Day = -1
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected error for a negative budget")
+	}
+	if !strings.Contains(err.Error(), "budgets.day") {
+		t.Errorf("error should mention budgets.day: %v", err)
+	}
+}
+
 func TestValidate_ConfigurableModuleMissingConfig(t *testing.T) {
 	id := t.Name() + ".config"
 	registerConfigurable(t, id)
@@ -165,3 +248,17 @@ func TestValidate_ConfigurableModuleNoEntry(t *testing.T) {
 		t.Errorf("error should mention requires configuration: %v", err)
 	}
 }
+
+func TestValidate_OptionalModuleNoEntry(t *testing.T) {
+	optID := t.Name() + ".optional"
+	stubID := t.Name() + ".other"
+	core.RegisterModule(&optionalModule{configurableModule{stubModule{id: optID}}})
+	registerStub(t, stubID)
+	cfg := &Config{
+		Version: "1",
+		Modules: map[string]yaml.Node{stubID: {}},
+	}
+	if err := Validate(cfg); err != nil && strings.Contains(err.Error(), optID) {
+		t.Errorf("optional module reported without config entry: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// adminReadHeaderTimeout bounds how long the admin listener waits for the
+// headers of a request.
+const adminReadHeaderTimeout = 10 * time.Second
+
+// SetAdminAddr sets the address of the admin HTTP listener started by
+// Start, such as "127.0.0.1:9090". It serves the metrics of
+// AppContext.Metrics on /metrics. The listener is disabled by default
+// and when addr is empty.
+func (a *App) SetAdminAddr(addr string) {
+	a.adminAddr = addr
+}
+
+// AdminAddr returns the address the admin listener is bound to, or the
+// empty string when it is not running.
+func (a *App) AdminAddr() string {
+	if a.admin == nil {
+		return ""
+	}
+	return a.adminListener.Addr().String()
+}
+
+// StartAdmin starts the admin listener, if configured and not already
+// running. Start calls it; commands that do not start the modules, like
+// an interactive chat, may call it alone. Stop shuts the listener down.
+func (a *App) StartAdmin() error {
+	if a.adminAddr == "" || a.admin != nil {
+		return nil
+	}
+	var lc net.ListenConfig
+	ln, err := lc.Listen(context.Background(), "tcp", a.adminAddr)
+	if err != nil {
+		return fmt.Errorf("starting admin listener: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", a.ctx.Metrics.Handler())
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: adminReadHeaderTimeout,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.logger.Error("admin listener failed", "error", err)
+		}
+	}()
+
+	a.admin = srv
+	a.adminListener = ln
+	a.logger.Info("admin listener started", "addr", ln.Addr().String())
+	return nil
+}
+
+// stopAdmin shuts the admin listener down, if running.
+func (a *App) stopAdmin() {
+	if a.admin == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := a.admin.Shutdown(ctx); err != nil {
+		a.logger.Error("admin listener stop error", "error", err)
+	}
+	a.admin = nil
+	a.adminListener = nil
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/metrics"
+)
+
+func TestApp_AdminListener(t *testing.T) {
+	ctx := newTestCtx()
+	ctx.Metrics.Counter("test_requests", "Test requests.").Inc()
+
+	app := NewApp(ctx)
+	app.SetAdminAddr("127.0.0.1:0")
+	if err := app.Start(); err != nil {
+		t.Fatalf("start error: %v", err)
+	}
+
+	addr := app.AdminAddr()
+	if addr == "" {
+		t.Fatal("AdminAddr() empty after Start")
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://"+addr+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, metrics.ContentType)
+	}
+	if !strings.Contains(string(body), "test_requests_total 1\n") {
+		t.Errorf("body = %q, want test_requests_total", body)
+	}
+
+	app.Stop()
+	if app.AdminAddr() != "" {
+		t.Error("AdminAddr() not empty after Stop")
+	}
+	var d net.Dialer
+	if conn, err := d.DialContext(context.Background(), "tcp", addr); err == nil {
+		_ = conn.Close()
+		t.Error("admin listener still accepting after Stop")
+	}
+}
+
+func TestApp_AdminListenerDisabled(t *testing.T) {
+	app := NewApp(newTestCtx())
+	if err := app.Start(); err != nil {
+		t.Fatalf("start error: %v", err)
+	}
+	defer app.Stop()
+
+	if addr := app.AdminAddr(); addr != "" {
+		t.Errorf("AdminAddr() = %q, want empty without address", addr)
+	}
+}
+
+func TestApp_AdminListenerError(t *testing.T) {
+	t.Cleanup(resetRegistry)
+
+	var lc net.ListenConfig
+	ln, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	startLog := &[]ModuleID{}
+	RegisterModule(&lifecycleMod{id: "test.admin", startLog: startLog})
+
+	app := NewApp(newTestCtx())
+	if err := app.LoadModules([]string{"test.admin"}); err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	app.SetAdminAddr(ln.Addr().String())
+	if err := app.Start(); err == nil {
+		t.Fatal("expected error for address in use")
+	}
+	if len(*startLog) != 0 {
+		t.Errorf("modules started despite admin listener failure: %v", *startLog)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/flemzord/sclaw/internal/metrics"
 	"gopkg.in/yaml.v3"
 )
 
@@ -20,6 +21,10 @@ type AppContext struct {
 	// Workspace is the working directory for the current agent/session.
 	Workspace string
 
+	// Metrics is the registry modules register their metrics with. It is
+	// shared by all modules and served by the admin listener.
+	Metrics *metrics.Registry
+
 	parentLogger  *slog.Logger
 	moduleConfigs map[string]yaml.Node
 }
@@ -33,6 +38,7 @@ func NewAppContext(logger *slog.Logger, dataDir, workspace string) *AppContext {
 		Logger:       logger,
 		DataDir:      dataDir,
 		Workspace:    workspace,
+		Metrics:      metrics.NewRegistry(),
 		parentLogger: logger,
 	}
 }
@@ -52,6 +58,7 @@ func (ctx *AppContext) ForModule(id ModuleID) *AppContext {
 		Logger:        ctx.parentLogger.With("module", string(id)),
 		DataDir:       ctx.DataDir,
 		Workspace:     ctx.Workspace,
+		Metrics:       ctx.Metrics,
 		parentLogger:  ctx.parentLogger,
 		moduleConfigs: ctx.moduleConfigs,
 	}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 )
@@ -14,9 +17,14 @@ const shutdownTimeout = 30 * time.Second
 
 // App manages the lifecycle of a set of modules.
 type App struct {
-	ctx     *AppContext
-	modules []moduleInstance
-	logger  *slog.Logger
+	ctx       *AppContext
+	modules   []moduleInstance
+	scheduler *scheduler
+	logger    *slog.Logger
+
+	adminAddr     string
+	admin         *http.Server
+	adminListener net.Listener
 }
 
 type moduleInstance struct {
@@ -52,9 +60,23 @@ func (a *App) LoadModules(ids []string) error {
 	return nil
 }
 
-// Start starts all loaded modules that implement Starter, in order.
-// If any Start() fails, already-started modules are stopped in reverse order.
+// Modules returns the loaded module instances in load order.
+func (a *App) Modules() []Module {
+	mods := make([]Module, len(a.modules))
+	for i, mi := range a.modules {
+		mods[i] = mi.module
+	}
+	return mods
+}
+
+// Start starts the admin listener when configured, then all loaded
+// modules that implement Starter, in order, then schedules the jobs of
+// modules that implement CronJob. If any step fails, already-started
+// modules are stopped in reverse order.
 func (a *App) Start() error {
+	if err := a.StartAdmin(); err != nil {
+		return err
+	}
 	for i := range a.modules {
 		mi := &a.modules[i]
 		s, ok := mi.module.(Starter)
@@ -65,17 +87,64 @@ func (a *App) Start() error {
 		if err := s.Start(); err != nil {
 			a.logger.Error("module start failed", "module", string(mi.id), "error", err)
 			a.stopModules(i - 1)
+			a.stopAdmin()
 			return fmt.Errorf("starting module %s: %w", mi.id, err)
 		}
 		mi.started = true
 	}
 	a.logger.Info("all modules started")
+
+	if err := a.startScheduler(); err != nil {
+		a.stopModules(len(a.modules) - 1)
+		a.stopAdmin()
+		return err
+	}
 	return nil
 }
 
-// Stop stops all started modules in reverse order with a timeout.
+// startScheduler schedules the jobs of CronJob modules. Job state is kept
+// under the data directory so that missed runs are caught up on restart.
+func (a *App) startScheduler() error {
+	var s *scheduler
+	for _, mi := range a.modules {
+		cj, ok := mi.module.(CronJob)
+		if !ok {
+			continue
+		}
+		if s == nil {
+			var err error
+			path := filepath.Join(a.ctx.DataDir, schedulerStateFile)
+			s, err = newScheduler(path, a.ctx.Logger.With("component", "scheduler"))
+			if err != nil {
+				return fmt.Errorf("starting scheduler: %w", err)
+			}
+		}
+		if err := s.add(mi.id, cj.Jobs()); err != nil {
+			return fmt.Errorf("scheduling module %s: %w", mi.id, err)
+		}
+	}
+	if s == nil {
+		return nil
+	}
+	s.start()
+	a.scheduler = s
+	a.logger.Info("scheduler started", "jobs", len(s.jobs))
+	return nil
+}
+
+// Stop stops the scheduler, then all started modules in reverse order,
+// then the admin listener, with a timeout.
 func (a *App) Stop() {
+	if a.scheduler != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := a.scheduler.stop(ctx); err != nil {
+			a.logger.Error("scheduler stop error", "error", err)
+		}
+		cancel()
+		a.scheduler = nil
+	}
 	a.stopModules(len(a.modules) - 1)
+	a.stopAdmin()
 }
 
 func (a *App) stopModules(fromIndex int) {
@@ -107,6 +107,26 @@ func TestApp_LoadModules_FailureRollback(t *testing.T) {
 	}
 }
 
+func TestApp_Modules_LoadOrder(t *testing.T) {
+	t.Cleanup(resetRegistry)
+
+	RegisterModule(&lifecycleMod{id: "test.m2"})
+	RegisterModule(&lifecycleMod{id: "test.m1"})
+
+	app := NewApp(newTestCtx())
+	if err := app.LoadModules([]string{"test.m2", "test.m1"}); err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+
+	mods := app.Modules()
+	if len(mods) != 2 {
+		t.Fatalf("got %d modules, want 2", len(mods))
+	}
+	if mods[0].ModuleInfo().ID != "test.m2" || mods[1].ModuleInfo().ID != "test.m1" {
+		t.Errorf("modules not in load order: %s, %s", mods[0].ModuleInfo().ID, mods[1].ModuleInfo().ID)
+	}
+}
+
 type provisionFailMod struct{ id ModuleID }
 
 func (m *provisionFailMod) ModuleInfo() ModuleInfo {
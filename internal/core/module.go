@@ -35,6 +35,11 @@ type ModuleInfo struct {
 	// New returns a new, empty instance of the module's type.
 	// The returned value should be a pointer.
 	New func() Module
+
+	// Optional marks a Configurable module that is only loaded when the
+	// configuration lists it. Configuration validation requires an entry
+	// for every other Configurable module compiled into the binary.
+	Optional bool
 }
 
 // Module is the base interface that all sclaw modules must implement.
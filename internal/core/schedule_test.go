@@ -0,0 +1,71 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule_Next(t *testing.T) {
+	t.Parallel()
+
+	// Wednesday.
+	base := time.Date(2026, 3, 18, 10, 17, 42, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 18, 10, 18, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2026, 3, 19, 9, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 18, 10, 30, 0, 0, time.UTC)},
+		{"0 8-10/2 * * *", time.Date(2026, 3, 19, 8, 0, 0, 0, time.UTC)},
+		{"0 12 * * mon,fri", time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 22, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches.
+		{"0 0 1 * sat", time.Date(2026, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 18, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 19, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", base.Add(90 * time.Minute)},
+		// Never matches.
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule: %v", err)
+			}
+			if got := s.Next(base); !got.Equal(tt.want) {
+				t.Errorf("Next = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@every",
+		"@every -1m",
+		"@sometimes",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want error", spec)
+		}
+	}
+}
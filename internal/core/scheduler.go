@@ -0,0 +1,265 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CronJob is implemented by modules that run scheduled jobs. Jobs is
+// called once when the App starts, after every module has started.
+type CronJob interface {
+	Jobs() []Job
+}
+
+// Job is a task run on a schedule.
+type Job struct {
+	// Name identifies the job within its module. It must be unique per
+	// module and stable across restarts: persisted state is keyed by it.
+	Name string
+
+	// Schedule is a specification accepted by ParseSchedule.
+	Schedule string
+
+	// Run executes the job. Its context is canceled when the App stops.
+	Run func(ctx context.Context) error
+}
+
+// jobState is the persisted state of a scheduled job.
+type jobState struct {
+	// Schedule is the specification the state was computed with. When it
+	// changes, the next run is recomputed instead of caught up.
+	Schedule string    `json:"schedule"`
+	LastRun  time.Time `json:"last_run,omitzero"`
+	NextRun  time.Time `json:"next_run,omitzero"`
+}
+
+// schedulerStateFile is the state file, relative to AppContext.DataDir.
+const schedulerStateFile = "cron/state.json"
+
+// scheduledJob is a job registered with the scheduler.
+type scheduledJob struct {
+	key      string
+	job      Job
+	schedule Schedule
+	next     time.Time
+	running  bool
+}
+
+// scheduler runs the jobs of CronJob modules and persists their state so
+// that runs missed while the process was down are caught up on start:
+// each overdue job runs once, however many activations it missed.
+type scheduler struct {
+	path   string
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	jobs  []*scheduledJob
+	state map[string]jobState
+
+	cancel context.CancelFunc
+	wake   chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	// now is injectable for testing. Defaults to time.Now.
+	now func() time.Time
+}
+
+// newScheduler creates a scheduler persisting its state at path.
+func newScheduler(path string, logger *slog.Logger) (*scheduler, error) {
+	s := &scheduler{
+		path:   path,
+		logger: logger,
+		state:  make(map[string]jobState),
+		wake:   make(chan struct{}, 1),
+		now:    time.Now,
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading scheduler state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("decoding scheduler state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// add registers the jobs of module id. A job whose persisted next run is
+// already past is due immediately.
+func (s *scheduler) add(id ModuleID, jobs []Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, job := range jobs {
+		key := string(id) + "/" + job.Name
+		if job.Name == "" || job.Run == nil {
+			return fmt.Errorf("job %q: name and run function are required", key)
+		}
+		for _, sj := range s.jobs {
+			if sj.key == key {
+				return fmt.Errorf("job %q: duplicate name", key)
+			}
+		}
+		sched, err := ParseSchedule(job.Schedule)
+		if err != nil {
+			return fmt.Errorf("job %q: %w", key, err)
+		}
+
+		sj := &scheduledJob{key: key, job: job, schedule: sched}
+		st, ok := s.state[key]
+		if ok && st.Schedule == job.Schedule && !st.NextRun.IsZero() {
+			sj.next = st.NextRun
+		} else {
+			sj.next = sched.Next(now)
+			s.state[key] = jobState{Schedule: job.Schedule, LastRun: st.LastRun, NextRun: sj.next}
+		}
+		s.jobs = append(s.jobs, sj)
+	}
+	return nil
+}
+
+// start persists the initial state and launches the scheduling loop.
+func (s *scheduler) start() {
+	s.mu.Lock()
+	if err := s.saveLocked(); err != nil {
+		s.logger.Error("persisting scheduler state failed", "path", s.path, "error", err)
+	}
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.loop(ctx)
+}
+
+// stop cancels running jobs and waits for them until ctx expires.
+func (s *scheduler) stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	<-s.done
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for scheduled jobs: %w", ctx.Err())
+	}
+}
+
+// loop sleeps until the earliest next run and dispatches due jobs.
+func (s *scheduler) loop(ctx context.Context) {
+	defer close(s.done)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-s.wake:
+		}
+
+		next := s.dispatch(ctx)
+		if next.IsZero() {
+			timer.Stop()
+			continue
+		}
+		timer.Reset(max(next.Sub(s.now()), 0))
+	}
+}
+
+// dispatch starts every due job that is not already running and returns
+// the earliest next run, or the zero time when nothing is scheduled.
+func (s *scheduler) dispatch(ctx context.Context) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var earliest time.Time
+	for _, sj := range s.jobs {
+		if !sj.running && !sj.next.IsZero() && !sj.next.After(now) {
+			sj.running = true
+			s.wg.Add(1)
+			go s.run(ctx, sj)
+		}
+		if sj.running || sj.next.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || sj.next.Before(earliest) {
+			earliest = sj.next
+		}
+	}
+	return earliest
+}
+
+// run executes one job and schedules its next run from the time it ended,
+// so that a job never overlaps itself.
+func (s *scheduler) run(ctx context.Context, sj *scheduledJob) {
+	defer s.wg.Done()
+
+	started := s.now()
+	s.logger.Info("running scheduled job", "job", sj.key)
+	if err := s.call(ctx, sj.job); err != nil {
+		s.logger.Error("scheduled job failed", "job", sj.key, "error", err)
+	}
+
+	s.mu.Lock()
+	sj.running = false
+	sj.next = sj.schedule.Next(s.now())
+	s.state[sj.key] = jobState{Schedule: sj.job.Schedule, LastRun: started, NextRun: sj.next}
+	if err := s.saveLocked(); err != nil {
+		s.logger.Error("persisting scheduler state failed", "path", s.path, "error", err)
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// call runs job, converting a panic into an error.
+func (s *scheduler) call(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// saveLocked writes the state atomically. Must be called with s.mu held.
+func (s *scheduler) saveLocked() error {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// cronMod is a module exposing scheduled jobs.
+type cronMod struct {
+	id   ModuleID
+	jobs []Job
+}
+
+func (m *cronMod) ModuleInfo() ModuleInfo {
+	return ModuleInfo{ID: m.id, New: func() Module { return m }}
+}
+
+func (m *cronMod) Jobs() []Job { return m.jobs }
+
+func newSchedulerCtx(t *testing.T) *AppContext {
+	t.Helper()
+	return NewAppContext(slog.New(slog.DiscardHandler), t.TempDir(), t.TempDir())
+}
+
+func readSchedulerState(t *testing.T, dataDir string) map[string]jobState {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dataDir, schedulerStateFile))
+	if err != nil {
+		t.Fatalf("reading state: %v", err)
+	}
+	var state map[string]jobState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("decoding state: %v", err)
+	}
+	return state
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestApp_SchedulerRunsIntervalJobs(t *testing.T) {
+	t.Cleanup(resetRegistry)
+
+	var runs atomic.Int32
+	RegisterModule(&cronMod{id: "test.cron", jobs: []Job{{
+		Name:     "tick",
+		Schedule: "@every 10ms",
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}}})
+
+	ctx := newSchedulerCtx(t)
+	app := NewApp(ctx)
+	if err := app.LoadModules([]string{"test.cron"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitFor(t, func() bool { return runs.Load() >= 3 })
+	app.Stop()
+
+	st := readSchedulerState(t, ctx.DataDir)["test.cron/tick"]
+	if st.LastRun.IsZero() || !st.NextRun.After(st.LastRun) {
+		t.Errorf("state = %+v, want last run before next run", st)
+	}
+	if st.Schedule != "@every 10ms" {
+		t.Errorf("state schedule = %q", st.Schedule)
+	}
+}
+
+func TestApp_SchedulerCatchesUpMissedRun(t *testing.T) {
+	t.Cleanup(resetRegistry)
+
+	ctx := newSchedulerCtx(t)
+	missed := time.Now().Add(-72 * time.Hour)
+	state := map[string]jobState{
+		"test.cron/report": {Schedule: "@daily", NextRun: missed},
+	}
+	data, _ := json.Marshal(state)
+	path := filepath.Join(ctx.DataDir, schedulerStateFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var runs atomic.Int32
+	RegisterModule(&cronMod{id: "test.cron", jobs: []Job{{
+		Name:     "report",
+		Schedule: "@daily",
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return errors.New("job errors are logged, not fatal")
+		},
+	}}})
+
+	app := NewApp(ctx)
+	if err := app.LoadModules([]string{"test.cron"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitFor(t, func() bool {
+		return !readSchedulerState(t, ctx.DataDir)["test.cron/report"].LastRun.IsZero()
+	})
+	app.Stop()
+
+	// Three missed days are caught up with a single run.
+	if got := runs.Load(); got != 1 {
+		t.Errorf("runs = %d, want 1", got)
+	}
+	st := readSchedulerState(t, ctx.DataDir)["test.cron/report"]
+	if !st.NextRun.After(time.Now()) {
+		t.Errorf("NextRun = %v, want a future run", st.NextRun)
+	}
+}
+
+func TestApp_SchedulerNewJobWaitsForSchedule(t *testing.T) {
+	t.Cleanup(resetRegistry)
+
+	var runs atomic.Int32
+	RegisterModule(&cronMod{id: "test.cron", jobs: []Job{{
+		Name:     "report",
+		Schedule: "@yearly",
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}}})
+
+	ctx := newSchedulerCtx(t)
+	app := NewApp(ctx)
+	if err := app.LoadModules([]string{"test.cron"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	app.Stop()
+
+	if got := runs.Load(); got != 0 {
+		t.Errorf("runs = %d, want 0", got)
+	}
+	// The next run is persisted immediately, so a restart after it is due
+	// catches it up.
+	if st := readSchedulerState(t, ctx.DataDir)["test.cron/report"]; st.NextRun.IsZero() {
+		t.Error("next run not persisted on start")
+	}
+}
+
+func TestApp_SchedulerInvalidJob(t *testing.T) {
+	t.Cleanup(resetRegistry)
+
+	stopLog := &[]ModuleID{}
+	RegisterModule(&lifecycleMod{id: "test.s1", stopLog: stopLog})
+	RegisterModule(&cronMod{id: "test.cron", jobs: []Job{{
+		Name:     "bad",
+		Schedule: "every day",
+		Run:      func(context.Context) error { return nil },
+	}}})
+
+	app := NewApp(newSchedulerCtx(t))
+	if err := app.LoadModules([]string{"test.s1", "test.cron"}); err != nil {
+		t.Fatal(err)
+	}
+	err := app.Start()
+	if err == nil || !strings.Contains(err.Error(), "test.cron/bad") {
+		t.Fatalf("Start = %v, want error naming the job", err)
+	}
+	if len(*stopLog) != 1 {
+		t.Errorf("expected rollback to stop test.s1, got: %v", *stopLog)
+	}
+}
+
+func TestApp_StopCancelsRunningJobs(t *testing.T) {
+	t.Cleanup(resetRegistry)
+
+	started := make(chan struct{})
+	var canceled atomic.Bool
+	RegisterModule(&cronMod{id: "test.cron", jobs: []Job{{
+		Name:     "long",
+		Schedule: "@every 1ms",
+		Run: func(ctx context.Context) error {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-ctx.Done()
+			canceled.Store(true)
+			return ctx.Err()
+		},
+	}}})
+
+	app := NewApp(newSchedulerCtx(t))
+	if err := app.LoadModules([]string{"test.cron"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	app.Stop()
+
+	if !canceled.Load() {
+		t.Error("Stop returned before the running job was canceled")
+	}
+}
@@ -0,0 +1,179 @@
+// Package cron provides scheduled job modules. Jobs are run by the
+// scheduler of core.App; see core.CronJob.
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/channel"
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/pkg/message"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	core.RegisterModule(&AgentTurns{})
+}
+
+// AgentTurnsModuleID is the identifier of the scheduled agent turns module.
+const AgentTurnsModuleID = "cron.agent_turn"
+
+// SenderID identifies the scheduler as the sender of the messages it
+// enqueues.
+const SenderID = "cron"
+
+// AgentTurn is a prompt sent to the agent on a schedule. The agent runs in
+// the session of the target chat and its reply is posted there.
+type AgentTurn struct {
+	// Name identifies the job. It must be unique and stable: the
+	// scheduler persists its last and next runs under it.
+	Name string `yaml:"name"`
+
+	// Schedule is a cron expression or interval accepted by
+	// core.ParseSchedule.
+	Schedule string `yaml:"schedule"`
+
+	// Prompt is the message sent to the agent.
+	Prompt string `yaml:"prompt"`
+
+	// Channel is the module ID of the channel to post to.
+	Channel string `yaml:"channel"`
+
+	// ChatID is the chat to post to.
+	ChatID string `yaml:"chat_id"`
+
+	// ChatType is the kind of chat. Default: dm.
+	ChatType message.ChatType `yaml:"chat_type"`
+
+	// ThreadID optionally targets a thread within the chat.
+	ThreadID string `yaml:"thread_id"`
+}
+
+// AgentTurns is a module that triggers agent turns on a schedule. Each
+// run enqueues the prompt as an inbound message from SenderID in the
+// configured chat, so the turn goes through the same routing, session
+// history, hooks and reply path as a user message.
+type AgentTurns struct {
+	Turns []AgentTurn `yaml:"jobs"`
+
+	mu    sync.Mutex
+	inbox channel.Inbox
+
+	// now is injectable for testing. Defaults to time.Now.
+	now func() time.Time
+}
+
+// ModuleInfo implements core.Module.
+func (*AgentTurns) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{
+		ID:       AgentTurnsModuleID,
+		New:      func() core.Module { return &AgentTurns{} },
+		Optional: true,
+	}
+}
+
+// Configure implements core.Configurable.
+func (a *AgentTurns) Configure(node *yaml.Node) error {
+	return node.Decode(a)
+}
+
+// Provision implements core.Provisioner.
+func (a *AgentTurns) Provision(_ *core.AppContext) error {
+	for i := range a.Turns {
+		if a.Turns[i].ChatType == "" {
+			a.Turns[i].ChatType = message.ChatDM
+		}
+	}
+	if a.now == nil {
+		a.now = time.Now
+	}
+	return nil
+}
+
+// Validate implements core.Validator.
+func (a *AgentTurns) Validate() error {
+	seen := make(map[string]bool, len(a.Turns))
+	var errs []error
+	for i, t := range a.Turns {
+		if t.Name == "" {
+			errs = append(errs, fmt.Errorf("cron: jobs[%d]: name is required", i))
+			continue
+		}
+		if seen[t.Name] {
+			errs = append(errs, fmt.Errorf("cron: job %q defined twice", t.Name))
+		}
+		seen[t.Name] = true
+		if _, err := core.ParseSchedule(t.Schedule); err != nil {
+			errs = append(errs, fmt.Errorf("cron: job %q: %w", t.Name, err))
+		}
+		if t.Prompt == "" {
+			errs = append(errs, fmt.Errorf("cron: job %q: prompt is required", t.Name))
+		}
+		if t.Channel == "" || t.ChatID == "" {
+			errs = append(errs, fmt.Errorf("cron: job %q: channel and chat_id are required", t.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Attach sets the inbox that receives the scheduled prompts, typically the
+// router. It must be called before the App starts the scheduler.
+func (a *AgentTurns) Attach(inbox channel.Inbox) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.inbox = inbox
+}
+
+// Jobs implements core.CronJob.
+func (a *AgentTurns) Jobs() []core.Job {
+	jobs := make([]core.Job, 0, len(a.Turns))
+	for _, t := range a.Turns {
+		jobs = append(jobs, core.Job{
+			Name:     t.Name,
+			Schedule: t.Schedule,
+			Run: func(context.Context) error {
+				return a.trigger(t)
+			},
+		})
+	}
+	return jobs
+}
+
+// trigger enqueues the prompt of t.
+func (a *AgentTurns) trigger(t AgentTurn) error {
+	a.mu.Lock()
+	inbox := a.inbox
+	a.mu.Unlock()
+	if inbox == nil {
+		return channel.ErrNoInbox
+	}
+
+	now := a.now()
+	msg := message.InboundMessage{
+		ID:        SenderID + "-" + t.Name + "-" + strconv.FormatInt(now.UnixNano(), 10),
+		Timestamp: now,
+		Channel:   t.Channel,
+		Sender:    message.Sender{ID: SenderID, DisplayName: "Scheduler"},
+		Chat:      message.Chat{ID: t.ChatID, Type: t.ChatType},
+		ThreadID:  t.ThreadID,
+		Blocks:    []message.ContentBlock{message.NewTextBlock(t.Prompt)},
+		Synthetic: true,
+	}
+	if err := inbox.Enqueue(msg); err != nil {
+		return fmt.Errorf("cron: enqueuing job %q: %w", t.Name, err)
+	}
+	return nil
+}
+
+// Interface guards.
+var (
+	_ core.CronJob      = (*AgentTurns)(nil)
+	_ core.Configurable = (*AgentTurns)(nil)
+	_ core.Provisioner  = (*AgentTurns)(nil)
+	_ core.Validator    = (*AgentTurns)(nil)
+)
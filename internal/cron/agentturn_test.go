@@ -0,0 +1,154 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/channel"
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+	"github.com/flemzord/sclaw/internal/router"
+	"github.com/flemzord/sclaw/pkg/message"
+	"gopkg.in/yaml.v3"
+)
+
+func TestModuleRegistered(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := core.GetModule(AgentTurnsModuleID); !ok {
+		t.Fatalf("module %s not registered", AgentTurnsModuleID)
+	}
+}
+
+func configured(t *testing.T, src string) *AgentTurns {
+	t.Helper()
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &node); err != nil {
+		t.Fatal(err)
+	}
+	a := &AgentTurns{}
+	if err := a.Configure(node.Content[0]); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if err := a.Provision(nil); err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	return a
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	a := configured(t, `
+jobs:
+  - name: brief
+    schedule: "0 8 * * mon-fri"
+    prompt: Summarize my agenda
+    channel: channel.telegram
+    chat_id: "42"
+`)
+	if err := a.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if a.Turns[0].ChatType != message.ChatDM {
+		t.Errorf("ChatType = %q, want dm by default", a.Turns[0].ChatType)
+	}
+
+	bad := configured(t, `
+jobs:
+  - name: brief
+    schedule: "every morning"
+    channel: channel.telegram
+  - name: brief
+    schedule: "@daily"
+    prompt: hi
+    channel: channel.telegram
+    chat_id: "42"
+`)
+	err := bad.Validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"schedule", "prompt is required", "chat_id", "defined twice"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q: %v", want, err)
+		}
+	}
+}
+
+func TestJobs_RequireInbox(t *testing.T) {
+	t.Parallel()
+
+	a := &AgentTurns{Turns: []AgentTurn{{Name: "brief", Schedule: "@daily", Prompt: "hi"}}}
+	if err := a.Provision(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Jobs()[0].Run(context.Background()); !errors.Is(err, channel.ErrNoInbox) {
+		t.Errorf("Run = %v, want ErrNoInbox", err)
+	}
+}
+
+func TestJobs_PostAgentReplyToChat(t *testing.T) {
+	t.Parallel()
+
+	p := &providertest.MockProvider{
+		CompleteFunc: func(_ context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+			return provider.CompletionResponse{Content: "report: " + req.Messages[len(req.Messages)-1].Content}, nil
+		},
+		ContextWindowSizeFunc: func() int { return 4096 },
+	}
+	replies := make(chan message.OutboundMessage, 1)
+	sent := make(chan string, 1)
+	r, err := router.New(router.Config{
+		Resolver: router.StaticResolver(&router.Agent{ID: "main", Provider: p}),
+		Sender: router.SenderFunc(func(_ context.Context, ch string, msg message.OutboundMessage) error {
+			sent <- ch
+			replies <- msg
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close(context.Background()) })
+
+	a := &AgentTurns{Turns: []AgentTurn{{
+		Name:     "brief",
+		Schedule: "@daily",
+		Prompt:   "daily report",
+		Channel:  "channel.test",
+		ChatID:   "42",
+		ThreadID: "t1",
+	}}}
+	if err := a.Provision(nil); err != nil {
+		t.Fatal(err)
+	}
+	a.Attach(r)
+
+	jobs := a.Jobs()
+	if len(jobs) != 1 || jobs[0].Name != "brief" || jobs[0].Schedule != "@daily" {
+		t.Fatalf("Jobs = %+v", jobs)
+	}
+	if err := jobs[0].Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	select {
+	case reply := <-replies:
+		if ch := <-sent; ch != "channel.test" {
+			t.Errorf("channel = %q, want channel.test", ch)
+		}
+		if reply.Chat.ID != "42" || reply.ThreadID != "t1" {
+			t.Errorf("reply routed to %+v/%q, want chat 42 thread t1", reply.Chat, reply.ThreadID)
+		}
+		if reply.TextContent() != "report: daily report" {
+			t.Errorf("reply = %q", reply.TextContent())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reply posted")
+	}
+}
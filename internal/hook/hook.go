@@ -0,0 +1,135 @@
+// Package hook defines the hook pipeline: modules that observe, mutate or
+// veto messages, provider calls and tool executions at fixed points of
+// message processing.
+package hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// Point identifies where in message processing a hook runs.
+type Point string
+
+// Point constants, in the order they occur during a turn.
+const (
+	// PointInbound runs when a message is received, before any agent work.
+	// Event.Inbound may be mutated; a veto drops the message.
+	PointInbound Point = "inbound"
+
+	// PointBeforeProvider runs before each provider call. Event.Request
+	// may be mutated; a veto fails the agent run.
+	PointBeforeProvider Point = "before_provider"
+
+	// PointAfterProvider runs after each provider response. Event.Response
+	// may be mutated; a veto fails the agent run. For streamed calls it
+	// runs once the stream is complete, so text already delivered to the
+	// caller cannot be changed.
+	PointAfterProvider Point = "after_provider"
+
+	// PointBeforeTool runs before each tool execution. Event.ToolCall may
+	// be mutated; a veto skips the call and reports the reason to the
+	// model as a failed tool result.
+	PointBeforeTool Point = "before_tool"
+
+	// PointAfterTool runs after each tool execution, with Event.ToolCall
+	// describing the executed call. Event.ToolOutput may be mutated; a
+	// veto replaces the output with the reason.
+	PointAfterTool Point = "after_tool"
+
+	// PointOutbound runs before a reply is sent. Event.Outbound may be
+	// mutated; a veto suppresses the reply.
+	PointOutbound Point = "outbound"
+)
+
+// Points lists every hook point in processing order.
+var Points = []Point{
+	PointInbound,
+	PointBeforeProvider,
+	PointAfterProvider,
+	PointBeforeTool,
+	PointAfterTool,
+	PointOutbound,
+}
+
+// ErrVetoed is matched by errors.Is for any hook veto.
+var ErrVetoed = errors.New("hook: vetoed")
+
+// Event carries the data of one hook point. Only the fields relevant to
+// Point are set, except Inbound which is set whenever the event belongs
+// to the processing of an inbound message.
+type Event struct {
+	Point Point
+
+	Inbound    *message.InboundMessage
+	Request    *provider.CompletionRequest
+	Response   *provider.CompletionResponse
+	ToolCall   *provider.ToolCall
+	ToolOutput *tool.Output
+	Outbound   *message.OutboundMessage
+}
+
+// Hook is implemented by hook modules. Handle is called for every event
+// at the points the hook declares, in pipeline order. Returning an error
+// vetoes the event; Veto builds one with a readable reason.
+type Hook interface {
+	core.Module
+
+	// Points returns the points the hook handles.
+	Points() []Point
+
+	// Handle observes or mutates ev.
+	Handle(ctx context.Context, ev *Event) error
+}
+
+// VetoError reports that a hook vetoed an event.
+type VetoError struct {
+	// Hook is the module ID of the hook that vetoed.
+	Hook string
+
+	// Reason explains the veto.
+	Reason string
+
+	// Err is the error returned by the hook, if it was not a plain Veto.
+	Err error
+}
+
+// Veto returns an error that vetoes the current event for reason.
+func Veto(reason string) error {
+	return &VetoError{Reason: reason}
+}
+
+// Error implements error.
+func (e *VetoError) Error() string {
+	if e.Hook == "" {
+		return "hook: vetoed: " + e.Reason
+	}
+	return fmt.Sprintf("hook %s: vetoed: %s", e.Hook, e.Reason)
+}
+
+// Unwrap returns the hook's own error, if any.
+func (e *VetoError) Unwrap() error { return e.Err }
+
+// Is reports whether target is ErrVetoed.
+func (e *VetoError) Is(target error) bool { return target == ErrVetoed }
+
+// inboundKey is the context key for the inbound message being processed.
+type inboundKey struct{}
+
+// WithInbound returns a context carrying msg. Events run with this
+// context get msg as their Inbound field when it is not set.
+func WithInbound(ctx context.Context, msg *message.InboundMessage) context.Context {
+	return context.WithValue(ctx, inboundKey{}, msg)
+}
+
+// InboundFrom returns the inbound message carried by ctx, or nil.
+func InboundFrom(ctx context.Context) *message.InboundMessage {
+	msg, _ := ctx.Value(inboundKey{}).(*message.InboundMessage)
+	return msg
+}
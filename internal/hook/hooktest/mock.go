@@ -0,0 +1,54 @@
+// Package hooktest provides test helpers and mocks for the hook package.
+package hooktest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/hook"
+)
+
+// MockHook is a configurable mock implementation of hook.Hook.
+type MockHook struct {
+	ID         core.ModuleID
+	On         []hook.Point
+	HandleFunc func(ctx context.Context, ev *hook.Event) error
+
+	mu     sync.Mutex
+	Events []hook.Point
+}
+
+// ModuleInfo implements core.Module.
+func (m *MockHook) ModuleInfo() core.ModuleInfo {
+	id := m.ID
+	if id == "" {
+		id = "hook.mock"
+	}
+	return core.ModuleInfo{ID: id, New: func() core.Module { return &MockHook{} }}
+}
+
+// Points implements hook.Hook.
+func (m *MockHook) Points() []hook.Point { return m.On }
+
+// Handle implements hook.Hook. It records the event point, then calls
+// HandleFunc when set.
+func (m *MockHook) Handle(ctx context.Context, ev *hook.Event) error {
+	m.mu.Lock()
+	m.Events = append(m.Events, ev.Point)
+	m.mu.Unlock()
+	if m.HandleFunc != nil {
+		return m.HandleFunc(ctx, ev)
+	}
+	return nil
+}
+
+// Seen returns a copy of the points handled so far.
+func (m *MockHook) Seen() []hook.Point {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]hook.Point(nil), m.Events...)
+}
+
+// Interface guard.
+var _ hook.Hook = (*MockHook)(nil)
@@ -0,0 +1,121 @@
+package hook
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/flemzord/sclaw/internal/core"
+)
+
+// Pipeline runs hooks in a fixed order. A nil *Pipeline runs nothing.
+type Pipeline struct {
+	byPoint map[Point][]Hook
+}
+
+// NewPipeline creates a pipeline for hooks. Hooks whose module ID appears
+// in order run first, in that order; the others follow sorted by ID. An
+// order entry that names none of the hooks is an error.
+func NewPipeline(hooks []Hook, order []string) (*Pipeline, error) {
+	rank := make(map[string]int, len(order))
+	for i, id := range order {
+		if _, dup := rank[id]; dup {
+			return nil, fmt.Errorf("hook: %q listed twice in order", id)
+		}
+		rank[id] = i
+	}
+
+	sorted := slices.Clone(hooks)
+	seen := make(map[string]bool, len(hooks))
+	for _, h := range sorted {
+		id := hookID(h)
+		if seen[id] {
+			return nil, fmt.Errorf("hook: duplicate hook %q", id)
+		}
+		seen[id] = true
+	}
+	for _, id := range order {
+		if !seen[id] {
+			return nil, fmt.Errorf("hook: %q in order is not a loaded hook module", id)
+		}
+	}
+
+	slices.SortStableFunc(sorted, func(a, b Hook) int {
+		ra, aok := rank[hookID(a)]
+		rb, bok := rank[hookID(b)]
+		switch {
+		case aok && bok:
+			return cmp.Compare(ra, rb)
+		case aok:
+			return -1
+		case bok:
+			return 1
+		default:
+			return strings.Compare(hookID(a), hookID(b))
+		}
+	})
+
+	p := &Pipeline{byPoint: make(map[Point][]Hook)}
+	for _, h := range sorted {
+		for _, pt := range h.Points() {
+			if !slices.Contains(Points, pt) {
+				return nil, fmt.Errorf("hook: %s declares unknown point %q", hookID(h), pt)
+			}
+			p.byPoint[pt] = append(p.byPoint[pt], h)
+		}
+	}
+	return p, nil
+}
+
+// FromModules creates a pipeline from the modules implementing Hook.
+func FromModules(mods []core.Module, order []string) (*Pipeline, error) {
+	var hooks []Hook
+	for _, mod := range mods {
+		if h, ok := mod.(Hook); ok {
+			hooks = append(hooks, h)
+		}
+	}
+	return NewPipeline(hooks, order)
+}
+
+// Has reports whether any hook handles point.
+func (p *Pipeline) Has(point Point) bool {
+	return p != nil && len(p.byPoint[point]) > 0
+}
+
+// Run passes ev to the hooks handling ev.Point, in order, and stops at the
+// first veto. Any error returned by a hook is reported as a *VetoError.
+// When ev.Inbound is nil, it is filled from ctx (see WithInbound).
+func (p *Pipeline) Run(ctx context.Context, ev *Event) error {
+	if !p.Has(ev.Point) {
+		return nil
+	}
+	if ev.Inbound == nil {
+		ev.Inbound = InboundFrom(ctx)
+	}
+
+	for _, h := range p.byPoint[ev.Point] {
+		err := h.Handle(ctx, ev)
+		if err == nil {
+			continue
+		}
+
+		var veto *VetoError
+		if errors.As(err, &veto) {
+			v := *veto
+			if v.Hook == "" {
+				v.Hook = hookID(h)
+			}
+			return &v
+		}
+		return &VetoError{Hook: hookID(h), Reason: err.Error(), Err: err}
+	}
+	return nil
+}
+
+func hookID(h Hook) string {
+	return string(h.ModuleInfo().ID)
+}
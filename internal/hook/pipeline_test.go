@@ -0,0 +1,200 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// testHook appends its ID to a shared trace and returns err.
+type testHook struct {
+	id     string
+	points []Point
+	trace  *[]string
+	err    error
+}
+
+func (h *testHook) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{ID: core.ModuleID(h.id), New: func() core.Module { return &testHook{} }}
+}
+
+func (h *testHook) Points() []Point { return h.points }
+
+func (h *testHook) Handle(_ context.Context, _ *Event) error {
+	if h.trace != nil {
+		*h.trace = append(*h.trace, h.id)
+	}
+	return h.err
+}
+
+func TestNewPipeline_Order(t *testing.T) {
+	t.Parallel()
+
+	var trace []string
+	hooks := []Hook{
+		&testHook{id: "hook.c", points: []Point{PointInbound}, trace: &trace},
+		&testHook{id: "hook.a", points: []Point{PointInbound}, trace: &trace},
+		&testHook{id: "hook.b", points: []Point{PointInbound}, trace: &trace},
+		&testHook{id: "hook.d", points: []Point{PointOutbound}, trace: &trace},
+	}
+	p, err := NewPipeline(hooks, []string{"hook.b"})
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+
+	if err := p.Run(context.Background(), &Event{Point: PointInbound}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []string{"hook.b", "hook.a", "hook.c"}
+	if !slices.Equal(trace, want) {
+		t.Errorf("order = %v, want %v", trace, want)
+	}
+	if p.Has(PointBeforeTool) {
+		t.Error("Has(before_tool) = true, want false")
+	}
+}
+
+func TestNewPipeline_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		hooks []Hook
+		order []string
+		want  string
+	}{
+		{
+			name:  "duplicate order entry",
+			hooks: []Hook{&testHook{id: "hook.a"}},
+			order: []string{"hook.a", "hook.a"},
+			want:  "listed twice",
+		},
+		{
+			name:  "unknown order entry",
+			hooks: []Hook{&testHook{id: "hook.a"}},
+			order: []string{"hook.x"},
+			want:  "hook.x",
+		},
+		{
+			name:  "duplicate hook",
+			hooks: []Hook{&testHook{id: "hook.a"}, &testHook{id: "hook.a"}},
+			want:  "duplicate hook",
+		},
+		{
+			name:  "unknown point",
+			hooks: []Hook{&testHook{id: "hook.a", points: []Point{"nowhere"}}},
+			want:  "unknown point",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewPipeline(tt.hooks, tt.order)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_VetoStopsPipeline(t *testing.T) {
+	t.Parallel()
+
+	var trace []string
+	p, err := NewPipeline([]Hook{
+		&testHook{id: "hook.a", points: []Point{PointOutbound}, trace: &trace, err: Veto("spam")},
+		&testHook{id: "hook.b", points: []Point{PointOutbound}, trace: &trace},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.Run(context.Background(), &Event{Point: PointOutbound})
+	var veto *VetoError
+	if !errors.As(err, &veto) {
+		t.Fatalf("err = %v, want *VetoError", err)
+	}
+	if veto.Hook != "hook.a" || veto.Reason != "spam" {
+		t.Errorf("veto = %+v, want hook.a: spam", veto)
+	}
+	if !errors.Is(err, ErrVetoed) {
+		t.Error("errors.Is(err, ErrVetoed) = false")
+	}
+	if !slices.Equal(trace, []string{"hook.a"}) {
+		t.Errorf("trace = %v, want only hook.a", trace)
+	}
+}
+
+func TestRun_ErrorIsVeto(t *testing.T) {
+	t.Parallel()
+
+	cause := errors.New("backend down")
+	p, err := NewPipeline([]Hook{
+		&testHook{id: "hook.a", points: []Point{PointBeforeTool}, err: cause},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.Run(context.Background(), &Event{Point: PointBeforeTool})
+	if !errors.Is(err, ErrVetoed) || !errors.Is(err, cause) {
+		t.Errorf("err = %v, want a veto wrapping the hook error", err)
+	}
+	if !strings.Contains(err.Error(), "hook hook.a") {
+		t.Errorf("err = %q, want the hook ID", err)
+	}
+}
+
+func TestRun_FillsInboundFromContext(t *testing.T) {
+	t.Parallel()
+
+	var got *message.InboundMessage
+	h := &inboundHook{got: &got}
+	p, err := NewPipeline([]Hook{h}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &message.InboundMessage{ID: "m1"}
+	ctx := WithInbound(context.Background(), msg)
+	if err := p.Run(ctx, &Event{Point: PointBeforeProvider}); err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("Inbound = %v, want the context message", got)
+	}
+}
+
+// inboundHook records the Inbound field of the events it handles.
+type inboundHook struct {
+	got **message.InboundMessage
+}
+
+func (h *inboundHook) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{ID: "hook.inbound", New: func() core.Module { return &inboundHook{} }}
+}
+
+func (h *inboundHook) Points() []Point { return []Point{PointBeforeProvider} }
+
+func (h *inboundHook) Handle(_ context.Context, ev *Event) error {
+	*h.got = ev.Inbound
+	return nil
+}
+
+func TestRun_NilPipeline(t *testing.T) {
+	t.Parallel()
+
+	var p *Pipeline
+	if p.Has(PointInbound) {
+		t.Error("nil pipeline Has = true")
+	}
+	if err := p.Run(context.Background(), &Event{Point: PointInbound}); err != nil {
+		t.Errorf("nil pipeline Run = %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	core.RegisterModule(&FileStore{})
+}
+
+// FileModuleID is the identifier of the file-backed session store module.
+const FileModuleID = "memory.file"
+
+// sessionExt is the extension of session history files.
+const sessionExt = ".jsonl"
+
+// FileStore is a SessionStore that keeps one JSON Lines file per session.
+// Each appended message is written as one line, so appends never rewrite
+// existing history. Lines that cannot be decoded, such as a partial line
+// left by an interrupted write, are skipped on load with a warning.
+type FileStore struct {
+	// Dir is the directory holding session files.
+	// Default: "sessions" under the application data directory.
+	Dir string `yaml:"dir"`
+
+	logger *slog.Logger
+
+	mu sync.Mutex
+}
+
+// NewFileStore creates a file store rooted at dir.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+// ModuleInfo implements core.Module.
+func (*FileStore) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{
+		ID:       FileModuleID,
+		New:      func() core.Module { return &FileStore{} },
+		Optional: true,
+	}
+}
+
+// Configure implements core.Configurable.
+func (s *FileStore) Configure(node *yaml.Node) error {
+	return node.Decode(s)
+}
+
+// Provision implements core.Provisioner.
+func (s *FileStore) Provision(ctx *core.AppContext) error {
+	s.logger = ctx.Logger
+	if s.Dir == "" {
+		s.Dir = filepath.Join(ctx.DataDir, "sessions")
+	}
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return fmt.Errorf("memory: creating session directory: %w", err)
+	}
+	return nil
+}
+
+// Validate implements core.Validator.
+func (s *FileStore) Validate() error {
+	if s.Dir == "" {
+		return errors.New("memory: dir is required")
+	}
+	return nil
+}
+
+// Append implements SessionStore.
+func (s *FileStore) Append(_ context.Context, key string, msgs ...provider.LLMMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, msg := range msgs {
+		if err := enc.Encode(msg); err != nil {
+			return fmt.Errorf("memory: encoding message: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return fmt.Errorf("memory: creating session directory: %w", err)
+	}
+	f, err := os.OpenFile(s.path(key), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("memory: opening session %q: %w", key, err)
+	}
+	data := buf.Bytes()
+	if !endsWithNewline(f) {
+		// Start on a fresh line after an interrupted write.
+		data = append([]byte{'\n'}, data...)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("memory: appending to session %q: %w", key, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("memory: closing session %q: %w", key, err)
+	}
+	return nil
+}
+
+// Load implements SessionStore.
+func (s *FileStore) Load(_ context.Context, key string) ([]provider.LLMMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("memory: opening session %q: %w", key, err)
+	}
+	defer f.Close()
+
+	var msgs []provider.LLMMessage
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("memory: reading session %q: %w", key, err)
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			var msg provider.LLMMessage
+			if jsonErr := json.Unmarshal(line, &msg); jsonErr != nil {
+				s.log().Warn("skipping unreadable history line",
+					"session", key, "line", n, "error", jsonErr)
+			} else {
+				msgs = append(msgs, msg)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return msgs, nil
+		}
+	}
+}
+
+// List implements SessionStore.
+func (s *FileStore) List(_ context.Context) ([]SessionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("memory: listing sessions: %w", err)
+	}
+
+	var infos []SessionInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, sessionExt) {
+			continue
+		}
+		key, err := url.PathUnescape(strings.TrimSuffix(name, sessionExt))
+		if err != nil {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("memory: listing sessions: %w", err)
+		}
+		count, err := countLines(filepath.Join(s.Dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("memory: listing sessions: %w", err)
+		}
+		infos = append(infos, SessionInfo{
+			Key:       key,
+			Messages:  count,
+			UpdatedAt: fi.ModTime(),
+		})
+	}
+
+	slices.SortFunc(infos, func(a, b SessionInfo) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return infos, nil
+}
+
+// Delete implements SessionStore.
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("memory: deleting session %q: %w", key, err)
+	}
+	return nil
+}
+
+// path returns the file holding the session. The key is escaped so that
+// separators and other special characters cannot leave Dir.
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.Dir, url.PathEscape(key)+sessionExt)
+}
+
+func (s *FileStore) log() *slog.Logger {
+	if s.logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return s.logger
+}
+
+// endsWithNewline reports whether f is empty or ends with a newline.
+func endsWithNewline(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 {
+		return true
+	}
+	var last [1]byte
+	if _, err := f.ReadAt(last[:], fi.Size()-1); err != nil {
+		return true
+	}
+	return last[0] == '\n'
+}
+
+// countLines returns the number of complete, non-empty lines in the file.
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var n int
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			n++
+		}
+	}
+}
+
+// Interface guards.
+var (
+	_ SessionStore      = (*FileStore)(nil)
+	_ core.Configurable = (*FileStore)(nil)
+	_ core.Provisioner  = (*FileStore)(nil)
+	_ core.Validator    = (*FileStore)(nil)
+)
@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+func TestFileStore_AppendLoad(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := NewFileStore(t.TempDir())
+
+	first := []provider.LLMMessage{
+		{Role: provider.MessageRoleUser, Content: "hi"},
+		{Role: provider.MessageRoleAssistant, ToolCalls: []provider.ToolCall{{ID: "c1", Name: "read", Arguments: json.RawMessage(`{"path":"a"}`)}}},
+	}
+	second := provider.LLMMessage{Role: provider.MessageRoleTool, ToolID: "c1", Content: "ok"}
+
+	if err := s.Append(ctx, "telegram/42", first...); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := s.Append(ctx, "telegram/42", second); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	got, err := s.Load(ctx, "telegram/42")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := append(first, second)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load = %+v, want %+v", got, want)
+	}
+}
+
+func TestFileStore_LoadUnknown(t *testing.T) {
+	t.Parallel()
+
+	got, err := NewFileStore(t.TempDir()).Load(context.Background(), "nope")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got != nil {
+		t.Errorf("Load = %v, want nil", got)
+	}
+}
+
+func TestFileStore_PartialLineRecovery(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := NewFileStore(t.TempDir())
+
+	if err := s.Append(ctx, "k", provider.LLMMessage{Role: provider.MessageRoleUser, Content: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a write interrupted mid-line.
+	f, err := os.OpenFile(s.path("k"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"role":"assis`); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if err := s.Append(ctx, "k", provider.LLMMessage{Role: provider.MessageRoleUser, Content: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Load(ctx, "k")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got) != 2 || got[0].Content != "a" || got[1].Content != "b" {
+		t.Errorf("Load = %+v, want messages a and b", got)
+	}
+}
+
+func TestFileStore_ListDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := NewFileStore(dir)
+
+	msg := provider.LLMMessage{Role: provider.MessageRoleUser, Content: "x"}
+	if err := s.Append(ctx, "slack/b/thread", msg, msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(ctx, "slack/a", msg); err != nil {
+		t.Fatal(err)
+	}
+	// Unrelated files are ignored.
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("List returned %d sessions, want 2", len(infos))
+	}
+	if infos[0].Key != "slack/a" || infos[0].Messages != 1 {
+		t.Errorf("infos[0] = %+v, want slack/a with 1 message", infos[0])
+	}
+	if infos[1].Key != "slack/b/thread" || infos[1].Messages != 2 {
+		t.Errorf("infos[1] = %+v, want slack/b/thread with 2 messages", infos[1])
+	}
+	if infos[0].UpdatedAt.IsZero() {
+		t.Error("UpdatedAt is zero")
+	}
+
+	if err := s.Delete(ctx, "slack/a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(ctx, "slack/a"); err != nil {
+		t.Errorf("second Delete: %v", err)
+	}
+	infos, err = s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Key != "slack/b/thread" {
+		t.Errorf("List after delete = %+v", infos)
+	}
+}
+
+func TestFileStore_KeysStayInDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s := NewFileStore(dir)
+	for _, key := range []string{"../escape", "a/../../b", "/abs"} {
+		if got := filepath.Dir(s.path(key)); got != dir {
+			t.Errorf("path(%q) dir = %q, want %q", key, got, dir)
+		}
+	}
+}
+
+func TestFileStore_ProvisionDefaultDir(t *testing.T) {
+	t.Parallel()
+
+	dataDir := t.TempDir()
+	s := &FileStore{}
+	if err := s.Provision(core.NewAppContext(nil, dataDir, "")); err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	want := filepath.Join(dataDir, "sessions")
+	if s.Dir != want {
+		t.Errorf("Dir = %q, want %q", s.Dir, want)
+	}
+	if fi, err := os.Stat(want); err != nil || !fi.IsDir() {
+		t.Errorf("session directory not created: %v", err)
+	}
+}
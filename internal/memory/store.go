@@ -0,0 +1,39 @@
+// Package memory defines how conversation history is persisted. A
+// SessionStore keeps the messages of each session so that conversations
+// survive restarts; the file store in this package is the reference
+// implementation.
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+// SessionStore persists conversation history per session. Keys are opaque
+// strings chosen by the caller, such as router.SessionKey.String().
+// Implementations must be safe for concurrent use.
+type SessionStore interface {
+	// Append adds messages to the end of the session history, creating
+	// the session if it does not exist.
+	Append(ctx context.Context, key string, msgs ...provider.LLMMessage) error
+
+	// Load returns the full history of the session in order. An unknown
+	// session has an empty history and is not an error.
+	Load(ctx context.Context, key string) ([]provider.LLMMessage, error)
+
+	// List returns all stored sessions, sorted by key.
+	List(ctx context.Context) ([]SessionInfo, error)
+
+	// Delete removes the session and its history. Deleting an unknown
+	// session is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// SessionInfo describes a stored session.
+type SessionInfo struct {
+	Key       string
+	Messages  int
+	UpdatedAt time.Time
+}
@@ -0,0 +1,297 @@
+// Package metrics implements labelled counters and histograms exposed in
+// the OpenMetrics text format, without external dependencies.
+//
+// A nil *Registry is valid: it hands out nil metrics, which discard
+// observations at no cost, so instrumented code needs no special case
+// when metrics are disabled.
+package metrics
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the OpenMetrics text exposition.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// DurationBuckets are histogram bucket upper bounds, in seconds, suited
+// to calls lasting from milliseconds to minutes.
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// metricType is the OpenMetrics type of a metric family.
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeHistogram metricType = "histogram"
+)
+
+// Registry holds metric families and writes them out. It is safe for
+// concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Counter returns the counter family name, creating it on first use.
+// name excludes the _total suffix added on exposition. Asking again for
+// the same name returns the same counter; it panics if the name is taken
+// by a metric of another type or label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	if r == nil {
+		return nil
+	}
+	return &Counter{fam: r.family(name, help, typeCounter, nil, labels)}
+}
+
+// Histogram returns the histogram family name with the given bucket
+// upper bounds, in increasing order, creating it on first use. The +Inf
+// bucket is implicit. Like Counter, it returns the existing histogram for
+// a name already registered and panics on a conflicting registration.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if r == nil {
+		return nil
+	}
+	if !slices.IsSorted(buckets) {
+		panic(fmt.Sprintf("metrics: histogram %s: buckets not in increasing order", name))
+	}
+	return &Histogram{fam: r.family(name, help, typeHistogram, buckets, labels)}
+}
+
+// family returns the family name, creating it if needed.
+func (r *Registry) family(name, help string, typ metricType, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.typ != typ || !slices.Equal(f.labels, labels) || !slices.Equal(f.buckets, buckets) {
+			panic(fmt.Sprintf("metrics: %s already registered with another type, labels or buckets", name))
+		}
+		return f
+	}
+	f := &family{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  slices.Clone(labels),
+		buckets: slices.Clone(buckets),
+		series:  make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// WriteTo writes every metric in the OpenMetrics text format, families
+// sorted by name and series by label values. It implements io.WriterTo.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	slices.SortFunc(families, func(a, b *family) int { return cmp.Compare(a.name, b.name) })
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		f.write(bw)
+	}
+	bw.WriteString("# EOF\n")
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler returns an HTTP handler serving the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// Counter is a family of monotonically increasing values, one per
+// combination of label values.
+type Counter struct {
+	fam *family
+}
+
+// Inc adds one to the counter with the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter with the given
+// label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if c == nil || v < 0 {
+		return
+	}
+	c.fam.with(labelValues, func(s *series) { s.sum += v })
+}
+
+// Histogram is a family of distributions of observed values, one per
+// combination of label values.
+type Histogram struct {
+	fam *family
+}
+
+// Observe adds v to the histogram with the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+	h.fam.with(labelValues, func(s *series) {
+		for i, ub := range h.fam.buckets {
+			if v <= ub {
+				s.buckets[i]++
+			}
+		}
+		s.count++
+		s.sum += v
+	})
+}
+
+// family is a named metric and its series.
+type family struct {
+	name    string
+	help    string
+	typ     metricType
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series // by joined label values
+}
+
+// series is the state of one combination of label values. Counters only
+// use sum.
+type series struct {
+	labelValues []string
+	sum         float64
+	count       uint64
+	buckets     []uint64 // cumulative counts
+}
+
+// with calls update on the series of labelValues under the family lock,
+// creating the series if needed. It panics on a wrong number of values.
+func (f *family) with(labelValues []string, update func(*series)) {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s: got %d label values, want %d", f.name, len(labelValues), len(f.labels)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		if f.typ == typeHistogram {
+			s.buckets = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	update(s)
+}
+
+// write writes the family in the OpenMetrics text format.
+func (f *family) write(w *bufio.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+	if f.help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, escape(f.help))
+	}
+
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+	slices.SortFunc(all, func(a, b *series) int { return slices.Compare(a.labelValues, b.labelValues) })
+
+	for _, s := range all {
+		switch f.typ {
+		case typeCounter:
+			writeSample(w, f.name+"_total", f.labels, s.labelValues, "", "", formatFloat(s.sum))
+		case typeHistogram:
+			for i, ub := range f.buckets {
+				writeSample(w, f.name+"_bucket", f.labels, s.labelValues, "le", formatFloat(ub), strconv.FormatUint(s.buckets[i], 10))
+			}
+			writeSample(w, f.name+"_bucket", f.labels, s.labelValues, "le", "+Inf", strconv.FormatUint(s.count, 10))
+			writeSample(w, f.name+"_sum", f.labels, s.labelValues, "", "", formatFloat(s.sum))
+			writeSample(w, f.name+"_count", f.labels, s.labelValues, "", "", strconv.FormatUint(s.count, 10))
+		}
+	}
+}
+
+// writeSample writes one sample line. extraName and extraValue add a
+// trailing label, such as a histogram bucket's le, when extraName is set.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue, value string) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escape(values[i]))
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(value)
+	w.WriteByte('\n')
+}
+
+// escaper escapes label values and help text.
+var escaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// formatFloat formats v as an OpenMetrics number.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
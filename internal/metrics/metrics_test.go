@@ -0,0 +1,157 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/metrics"
+	"github.com/flemzord/sclaw/internal/metrics/metricstest"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	calls := reg.Counter("test_calls", "Calls by outcome.", "outcome")
+	calls.Inc("success")
+	calls.Inc("success")
+	calls.Add(0.5, "error")
+	calls.Add(-1, "error") // ignored: counters only increase
+
+	latency := reg.Histogram("test_latency_seconds", "Latency.", []float64{0.1, 1})
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(3)
+
+	want := `# TYPE test_calls counter
+# HELP test_calls Calls by outcome.
+test_calls_total{outcome="error"} 0.5
+test_calls_total{outcome="success"} 2
+# TYPE test_latency_seconds histogram
+# HELP test_latency_seconds Latency.
+test_latency_seconds_bucket{le="0.1"} 1
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 3.55
+test_latency_seconds_count 3
+# EOF
+`
+	if got := metricstest.Exposition(t, reg); got != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegistry_LabelledHistogram(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	reg.Histogram("test_seconds", "", []float64{1}, "tool").Observe(2, "echo")
+
+	got := metricstest.Exposition(t, reg)
+	for _, line := range []string{
+		`test_seconds_bucket{tool="echo",le="1"} 0`,
+		`test_seconds_bucket{tool="echo",le="+Inf"} 1`,
+		`test_seconds_count{tool="echo"} 1`,
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("WriteTo() missing %q in\n%s", line, got)
+		}
+	}
+	if strings.Contains(got, "# HELP") {
+		t.Errorf("WriteTo() = %q, want no HELP without help text", got)
+	}
+}
+
+func TestRegistry_Escaping(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	reg.Counter("test", "Help with \\ and\nnewline.", "v").Inc("a\"b\\c\nd")
+
+	got := metricstest.Exposition(t, reg)
+	if !strings.Contains(got, `# HELP test Help with \\ and\nnewline.`) {
+		t.Errorf("help not escaped:\n%s", got)
+	}
+	if !strings.Contains(got, `test_total{v="a\"b\\c\nd"} 1`) {
+		t.Errorf("label value not escaped:\n%s", got)
+	}
+}
+
+func TestRegistry_SameName(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	reg.Counter("test", "", "a").Inc("x")
+	reg.Counter("test", "", "a").Inc("x")
+
+	if got := metricstest.Exposition(t, reg); !strings.Contains(got, `test_total{a="x"} 2`) {
+		t.Errorf("WriteTo() = %q, want both counters to share a series", got)
+	}
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		fn   func(reg *metrics.Registry)
+	}{
+		{"other type", func(reg *metrics.Registry) {
+			reg.Counter("test", "")
+			reg.Histogram("test", "", []float64{1})
+		}},
+		{"other labels", func(reg *metrics.Registry) {
+			reg.Counter("test", "", "a")
+			reg.Counter("test", "", "b")
+		}},
+		{"unsorted buckets", func(reg *metrics.Registry) {
+			reg.Histogram("test", "", []float64{2, 1})
+		}},
+		{"label values", func(reg *metrics.Registry) {
+			reg.Counter("test", "", "a", "b").Inc("x")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			tt.fn(metrics.NewRegistry())
+		})
+	}
+}
+
+func TestRegistry_Nil(t *testing.T) {
+	t.Parallel()
+
+	var reg *metrics.Registry
+	c := reg.Counter("test", "", "a")
+	h := reg.Histogram("test_seconds", "", metrics.DurationBuckets)
+	if c != nil || h != nil {
+		t.Fatal("nil registry returned non-nil metrics")
+	}
+	c.Inc("x")
+	h.Observe(1)
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	reg.Counter("test", "").Inc()
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, metrics.ContentType)
+	}
+	if body := rec.Body.String(); !strings.HasSuffix(body, "test_total 1\n# EOF\n") {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,32 @@
+// Package metricstest provides test helpers for the metrics package.
+package metricstest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/metrics"
+)
+
+// Exposition returns the exposition of reg in the OpenMetrics text
+// format.
+func Exposition(t testing.TB, reg *metrics.Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+// AssertLines reports an error on t unless the exposition of reg contains
+// every line.
+func AssertLines(t testing.TB, reg *metrics.Registry, lines ...string) {
+	t.Helper()
+	got := Exposition(t, reg)
+	for _, line := range lines {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("exposition missing %q in\n%s", line, got)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package prompt assembles system prompts from ordered sections: the
+// agent persona, the available tools, the active skills, the sender and
+// chat, retrieved memories and the current date and time. Sections are
+// rendered in a fixed order and render the same input to the same text,
+// so that unchanged prompt prefixes can be cached by providers.
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/skill"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// truncatedMarker ends a section cut to its token cap.
+const truncatedMarker = "\n[truncated]"
+
+// sectionSeparator separates rendered sections.
+const sectionSeparator = "\n\n"
+
+// Input is the data a prompt is built from.
+type Input struct {
+	// Persona is the fallback persona text, used when the workspace has
+	// no persona file. Typically the agent's configured system prompt.
+	Persona string
+
+	// Now is the time the prompt is built for. Default: time.Now().
+	Now time.Time
+
+	// Inbound is the message being answered, if any.
+	Inbound *message.InboundMessage
+
+	// Tools are the tools available to the run.
+	Tools []provider.ToolDefinition
+
+	// Skills are the skills active for the run.
+	Skills []skill.Skill
+
+	// Memories are retrieved memories relevant to the conversation, most
+	// relevant first.
+	Memories []string
+}
+
+// Section renders one part of the system prompt. Render returns the empty
+// string when the section has nothing to say for in, and must render equal
+// inputs to equal text.
+type Section interface {
+	Name() string
+	Render(in *Input) (string, error)
+}
+
+// Part places a section in a prompt.
+type Part struct {
+	Section Section
+
+	// MaxTokens caps the estimated size of the rendered section; longer
+	// text is cut. Zero means no cap.
+	MaxTokens int
+}
+
+// Engine builds system prompts from an ordered list of parts.
+// It is safe for concurrent use.
+type Engine struct {
+	parts []Part
+}
+
+// NewEngine creates an engine rendering parts in order. Section names must
+// be unique.
+func NewEngine(parts ...Part) (*Engine, error) {
+	seen := make(map[string]bool, len(parts))
+	for _, p := range parts {
+		if p.Section == nil {
+			return nil, errors.New("prompt: nil section")
+		}
+		name := p.Section.Name()
+		if seen[name] {
+			return nil, fmt.Errorf("prompt: duplicate section %q", name)
+		}
+		seen[name] = true
+		if p.MaxTokens < 0 {
+			return nil, fmt.Errorf("prompt: section %q: negative token cap", name)
+		}
+	}
+	return &Engine{parts: parts}, nil
+}
+
+// Default token caps of the sections of DefaultEngine.
+const (
+	DefaultPersonaTokens      = 2000
+	DefaultConversationTokens = 200
+	DefaultToolsTokens        = 1000
+	DefaultSkillsTokens       = 2000
+	DefaultMemoriesTokens     = 1000
+)
+
+// DefaultEngine returns an engine with the standard sections: the persona
+// file of workspace, the tools summary, the active skills, the sender and
+// chat, the memories and the date and time in loc. Sections that change
+// least come first, so that the prompt prefix stays cacheable across
+// turns.
+func DefaultEngine(workspace string, loc *time.Location) *Engine {
+	e, _ := NewEngine(
+		Part{Section: Persona(workspace, DefaultPersonaFile), MaxTokens: DefaultPersonaTokens},
+		Part{Section: Tools(), MaxTokens: DefaultToolsTokens},
+		Part{Section: Skills(), MaxTokens: DefaultSkillsTokens},
+		Part{Section: Conversation(), MaxTokens: DefaultConversationTokens},
+		Part{Section: Memories(), MaxTokens: DefaultMemoriesTokens},
+		Part{Section: DateTime(loc)},
+	)
+	return e
+}
+
+// Build renders the sections in order, skipping empty ones, and joins
+// them.
+func (e *Engine) Build(in Input) (string, error) {
+	if in.Now.IsZero() {
+		in.Now = time.Now()
+	}
+
+	var rendered []string
+	for _, p := range e.parts {
+		text, err := p.Section.Render(&in)
+		if err != nil {
+			return "", fmt.Errorf("prompt: section %s: %w", p.Section.Name(), err)
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		rendered = append(rendered, truncate(text, p.MaxTokens))
+	}
+	return strings.Join(rendered, sectionSeparator), nil
+}
+
+// truncate cuts text to about maxTokens estimated tokens, on a rune
+// boundary, and marks the cut.
+func truncate(text string, maxTokens int) string {
+	n := provider.EstimateTextTokens(text)
+	if maxTokens <= 0 || n <= maxTokens {
+		return text
+	}
+	keep := len(text) * maxTokens / n
+	keep -= len(truncatedMarker)
+	for keep > 0 && !utf8.RuneStart(text[keep]) {
+		keep--
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	return strings.TrimRight(text[:keep], " \n") + truncatedMarker
+}
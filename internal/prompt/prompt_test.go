@@ -0,0 +1,144 @@
+package prompt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+func TestBuild_OrderAndEmptySections(t *testing.T) {
+	t.Parallel()
+
+	e, err := NewEngine(
+		Part{Section: Text("a", "first")},
+		Part{Section: Text("empty", "  \n")},
+		Part{Section: Text("b", "second\n")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := e.Build(Input{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "first\n\nsecond" {
+		t.Errorf("Build = %q, want %q", got, "first\n\nsecond")
+	}
+}
+
+func TestBuild_Deterministic(t *testing.T) {
+	t.Parallel()
+
+	e := DefaultEngine(t.TempDir(), time.UTC)
+	in := Input{
+		Persona: "You are sclaw.",
+		Now:     time.Date(2026, 5, 4, 9, 30, 15, 0, time.UTC),
+	}
+	first, err := e.Build(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.Now = in.Now.Add(20 * time.Second)
+	second, err := e.Build(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("builds within the same minute differ:\n%q\n%q", first, second)
+	}
+}
+
+func TestDefaultEngine_StablePrefix(t *testing.T) {
+	t.Parallel()
+
+	e := DefaultEngine(t.TempDir(), time.UTC)
+	in := Input{
+		Persona: "You are sclaw.",
+		Now:     time.Date(2026, 5, 4, 9, 30, 0, 0, time.UTC),
+		Tools:   []provider.ToolDefinition{{Name: "read_file", Description: "Read a file."}},
+	}
+	first, err := e.Build(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.Now = in.Now.Add(time.Hour)
+	second, err := e.Build(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for n < len(first) && n < len(second) && first[n] == second[n] {
+		n++
+	}
+	if prefix := first[:n]; !strings.Contains(prefix, "You are sclaw.") || !strings.Contains(prefix, "read_file") {
+		t.Errorf("persona and tools not in the prefix shared across turns: %q", prefix)
+	}
+}
+
+func TestBuild_TokenCap(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("é", 2000)
+	e, err := NewEngine(
+		Part{Section: Text("long", long), MaxTokens: 100},
+		Part{Section: Text("next", "after")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := e.Build(Input{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, rest, _ := strings.Cut(got, sectionSeparator)
+	if !strings.HasSuffix(section, truncatedMarker) {
+		t.Errorf("capped section does not end with the marker: %q", section[len(section)-20:])
+	}
+	if len(section) > 100*4 {
+		t.Errorf("capped section is %d bytes, want <= 400", len(section))
+	}
+	if !utf8.ValidString(section) {
+		t.Error("truncation split a rune")
+	}
+	if rest != "after" {
+		t.Errorf("following section = %q, want after", rest)
+	}
+}
+
+func TestBuild_SectionError(t *testing.T) {
+	t.Parallel()
+
+	boom := errors.New("boom")
+	e, err := NewEngine(Part{Section: Func("broken", func(*Input) (string, error) { return "", boom })})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Build(Input{}); !errors.Is(err, boom) || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Build = %v, want error naming the section", err)
+	}
+}
+
+func TestNewEngine_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		parts []Part
+	}{
+		{"nil section", []Part{{}}},
+		{"duplicate", []Part{{Section: Text("a", "")}, {Section: Text("a", "")}}},
+		{"negative cap", []Part{{Section: Text("a", ""), MaxTokens: -1}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewEngine(tt.parts...); err == nil {
+			t.Errorf("%s: NewEngine succeeded, want error", tt.name)
+		}
+	}
+}
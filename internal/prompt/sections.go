@@ -0,0 +1,154 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/skill"
+)
+
+// DefaultPersonaFile is the persona file read from the agent workspace.
+const DefaultPersonaFile = "PERSONA.md"
+
+// dateTimeLayout renders the current time to the minute, so that the
+// prompt only changes once a minute.
+const dateTimeLayout = "Monday, 2 January 2006 15:04 MST (-07:00)"
+
+// sectionFunc adapts a render function to the Section interface.
+type sectionFunc struct {
+	name   string
+	render func(in *Input) (string, error)
+}
+
+func (s sectionFunc) Name() string                     { return s.name }
+func (s sectionFunc) Render(in *Input) (string, error) { return s.render(in) }
+
+// Func returns a section named name rendered by render.
+func Func(name string, render func(in *Input) (string, error)) Section {
+	return sectionFunc{name: name, render: render}
+}
+
+// Text returns a section that always renders text.
+func Text(name, text string) Section {
+	return Func(name, func(*Input) (string, error) { return text, nil })
+}
+
+// Persona renders the persona file at file, relative to workspace, or
+// Input.Persona when the file does not exist. The file is read on every
+// build, so edits apply to the next turn.
+func Persona(workspace, file string) Section {
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workspace, file)
+	}
+	return Func("persona", func(in *Input) (string, error) {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return in.Persona, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading persona: %w", err)
+		}
+		return string(data), nil
+	})
+}
+
+// DateTime renders the current date, time and time zone in loc.
+// A nil loc means time.Local.
+func DateTime(loc *time.Location) Section {
+	if loc == nil {
+		loc = time.Local
+	}
+	return Func("datetime", func(in *Input) (string, error) {
+		now := in.Now.In(loc)
+		return fmt.Sprintf("Current date and time: %s, time zone %s.",
+			now.Format(dateTimeLayout), loc), nil
+	})
+}
+
+// Conversation renders the sender and chat of the inbound message.
+func Conversation() Section {
+	return Func("conversation", func(in *Input) (string, error) {
+		msg := in.Inbound
+		if msg == nil {
+			return "", nil
+		}
+
+		var b strings.Builder
+		b.WriteString("Conversation:\n")
+		fmt.Fprintf(&b, "- Channel: %s\n", msg.Channel)
+
+		chat := string(msg.Chat.Type)
+		if msg.Chat.Title != "" {
+			chat += fmt.Sprintf(" %q", msg.Chat.Title)
+		}
+		fmt.Fprintf(&b, "- Chat: %s (id %s)\n", chat, msg.Chat.ID)
+		if msg.ThreadID != "" {
+			fmt.Fprintf(&b, "- Thread: %s\n", msg.ThreadID)
+		}
+
+		sender := msg.Sender.DisplayName
+		if msg.Sender.Username != "" {
+			if sender != "" {
+				sender += " "
+			}
+			sender += "@" + msg.Sender.Username
+		}
+		if sender == "" {
+			sender = "unknown"
+		}
+		fmt.Fprintf(&b, "- Sender: %s (id %s)\n", sender, msg.Sender.ID)
+		return b.String(), nil
+	})
+}
+
+// Tools renders the names and descriptions of the available tools, sorted
+// by name.
+func Tools() Section {
+	return Func("tools", func(in *Input) (string, error) {
+		if len(in.Tools) == 0 {
+			return "", nil
+		}
+		tools := slices.Clone(in.Tools)
+		slices.SortFunc(tools, func(a, b provider.ToolDefinition) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		var b strings.Builder
+		b.WriteString("Available tools:\n")
+		for _, t := range tools {
+			desc, _, _ := strings.Cut(strings.TrimSpace(t.Description), "\n")
+			fmt.Fprintf(&b, "- %s: %s\n", t.Name, desc)
+		}
+		return b.String(), nil
+	})
+}
+
+// Skills renders the instructions of the active skills.
+func Skills() Section {
+	return Func("skills", func(in *Input) (string, error) {
+		return skill.Instructions(in.Skills), nil
+	})
+}
+
+// Memories renders the retrieved memories, in order.
+func Memories() Section {
+	return Func("memories", func(in *Input) (string, error) {
+		if len(in.Memories) == 0 {
+			return "", nil
+		}
+		var b strings.Builder
+		b.WriteString("Relevant memories:\n")
+		for _, m := range in.Memories {
+			fmt.Fprintf(&b, "- %s\n", strings.TrimSpace(m))
+		}
+		return b.String(), nil
+	})
+}
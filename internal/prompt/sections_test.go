@@ -0,0 +1,107 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/skill"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+func render(t *testing.T, s Section, in Input) string {
+	t.Helper()
+	got, err := s.Render(&in)
+	if err != nil {
+		t.Fatalf("%s: %v", s.Name(), err)
+	}
+	return got
+}
+
+func TestPersona(t *testing.T) {
+	t.Parallel()
+
+	ws := t.TempDir()
+	s := Persona(ws, DefaultPersonaFile)
+	if got := render(t, s, Input{Persona: "fallback"}); got != "fallback" {
+		t.Errorf("without file = %q, want fallback", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(ws, DefaultPersonaFile), []byte("You are Ada."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := render(t, s, Input{Persona: "fallback"}); got != "You are Ada." {
+		t.Errorf("with file = %q, want file content", got)
+	}
+}
+
+func TestDateTime(t *testing.T) {
+	t.Parallel()
+
+	loc := time.FixedZone("CEST", 2*3600)
+	now := time.Date(2026, 7, 14, 8, 5, 59, 0, time.UTC)
+	got := render(t, DateTime(loc), Input{Now: now})
+	want := "Current date and time: Tuesday, 14 July 2026 10:05 CEST (+02:00), time zone CEST."
+	if got != want {
+		t.Errorf("DateTime = %q, want %q", got, want)
+	}
+}
+
+func TestConversation(t *testing.T) {
+	t.Parallel()
+
+	if got := render(t, Conversation(), Input{}); got != "" {
+		t.Errorf("without inbound = %q, want empty", got)
+	}
+
+	got := render(t, Conversation(), Input{Inbound: &message.InboundMessage{
+		Channel:  "channel.telegram",
+		Chat:     message.Chat{ID: "-100", Type: message.ChatGroup, Title: "Family"},
+		ThreadID: "7",
+		Sender:   message.Sender{ID: "42", Username: "ada", DisplayName: "Ada"},
+	}})
+	for _, want := range []string{
+		"- Channel: channel.telegram",
+		`- Chat: group "Family" (id -100)`,
+		"- Thread: 7",
+		"- Sender: Ada @ada (id 42)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Conversation missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestTools(t *testing.T) {
+	t.Parallel()
+
+	got := render(t, Tools(), Input{Tools: []provider.ToolDefinition{
+		{Name: "write", Description: "Write a file.\nDetails..."},
+		{Name: "read", Description: "Read a file."},
+	}})
+	want := "Available tools:\n- read: Read a file.\n- write: Write a file.\n"
+	if got != want {
+		t.Errorf("Tools = %q, want %q", got, want)
+	}
+}
+
+func TestSkillsAndMemories(t *testing.T) {
+	t.Parallel()
+
+	in := Input{
+		Skills:   []skill.Skill{{Name: "review", Instructions: "Be thorough."}},
+		Memories: []string{"Likes tea. ", "Lives in Lyon"},
+	}
+	if got := render(t, Skills(), in); !strings.Contains(got, "## Skill: review") {
+		t.Errorf("Skills = %q", got)
+	}
+	if got := render(t, Memories(), in); got != "Relevant memories:\n- Likes tea.\n- Lives in Lyon\n" {
+		t.Errorf("Memories = %q", got)
+	}
+	if got := render(t, Memories(), Input{}); got != "" {
+		t.Errorf("Memories without memories = %q, want empty", got)
+	}
+}
@@ -0,0 +1,388 @@
+// Package anthropic provides a provider module for the Anthropic Messages
+// API.
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/sse"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	core.RegisterModule(&Provider{})
+}
+
+// ModuleID is the identifier of the Anthropic provider module.
+const ModuleID = "provider.anthropic"
+
+// Defaults applied by Provision.
+const (
+	DefaultBaseURL       = "https://api.anthropic.com"
+	DefaultContextWindow = 200000
+	DefaultMaxTokens     = 4096
+	DefaultTimeout       = 5 * time.Minute
+)
+
+// apiVersion is the value of the anthropic-version header.
+const apiVersion = "2023-06-01"
+
+// Provider is a provider.Provider speaking the Anthropic Messages API.
+type Provider struct {
+	// BaseURL is the API root, without the /v1 suffix.
+	// Default: DefaultBaseURL.
+	BaseURL string `yaml:"base_url"`
+
+	// Model is the model requested from the API.
+	Model string `yaml:"model"`
+
+	// APIKeys are the keys sent in the x-api-key header. When several are
+	// set, the chain rotates to the next one on rate limits.
+	APIKeys []string `yaml:"api_keys"`
+
+	// MaxTokens is the output limit sent when a request sets none; the
+	// API requires one. Default: DefaultMaxTokens.
+	MaxTokens int `yaml:"max_tokens"`
+
+	// ContextWindow is the context window of the model in tokens.
+	// Default: DefaultContextWindow.
+	ContextWindow int `yaml:"context_window"`
+
+	// Timeout bounds a whole request, including reading a streamed
+	// response. Default: DefaultTimeout.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Role is the role of the provider in the chain. Default: primary.
+	Role provider.Role `yaml:"role"`
+
+	// FallbackFor restricts a fallback provider to the listed roles.
+	// Empty means all roles.
+	FallbackFor []provider.Role `yaml:"fallback_for"`
+
+	// Weight is the share of traffic of the provider among those of its
+	// role under the weighted strategy. Default: 1.
+	Weight int `yaml:"weight"`
+
+	// Cost is the relative cost of the provider, such as its price per
+	// million tokens, for the least_cost strategy.
+	Cost float64 `yaml:"cost"`
+
+	auth   *provider.AuthProfile
+	client *http.Client
+}
+
+// New creates a provider for model served at baseURL, authenticated with
+// the keys of auth. It is ready to use without the module lifecycle.
+func New(baseURL, model string, auth *provider.AuthProfile) *Provider {
+	p := &Provider{BaseURL: baseURL, Model: model, auth: auth}
+	p.defaults()
+	return p
+}
+
+// ModuleInfo implements core.Module.
+func (*Provider) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{
+		ID:       ModuleID,
+		New:      func() core.Module { return &Provider{} },
+		Optional: true,
+	}
+}
+
+// Configure implements core.Configurable.
+func (p *Provider) Configure(node *yaml.Node) error {
+	return node.Decode(p)
+}
+
+// Provision implements core.Provisioner.
+func (p *Provider) Provision(_ *core.AppContext) error {
+	if len(p.APIKeys) > 0 {
+		auth, err := provider.NewAuthProfile(p.APIKeys...)
+		if err != nil {
+			return fmt.Errorf("anthropic: %w", err)
+		}
+		p.auth = auth
+	}
+	p.defaults()
+	return nil
+}
+
+func (p *Provider) defaults() {
+	if p.BaseURL == "" {
+		p.BaseURL = DefaultBaseURL
+	}
+	p.BaseURL = strings.TrimSuffix(p.BaseURL, "/")
+	if p.MaxTokens <= 0 {
+		p.MaxTokens = DefaultMaxTokens
+	}
+	if p.ContextWindow <= 0 {
+		p.ContextWindow = DefaultContextWindow
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = DefaultTimeout
+	}
+	if p.client == nil {
+		p.client = &http.Client{}
+	}
+}
+
+// Validate implements core.Validator.
+func (p *Provider) Validate() error {
+	if p.Model == "" {
+		return errors.New("anthropic: model is required")
+	}
+	if len(p.APIKeys) == 0 {
+		return errors.New("anthropic: api_keys is required")
+	}
+	for _, k := range p.APIKeys {
+		if k == "" {
+			return errors.New("anthropic: api_keys must not contain empty keys")
+		}
+	}
+	if p.Weight < 0 {
+		return errors.New("anthropic: weight must not be negative")
+	}
+	if p.Cost < 0 {
+		return errors.New("anthropic: cost must not be negative")
+	}
+	return nil
+}
+
+// ChainEntry implements provider.ChainMember.
+func (p *Provider) ChainEntry() provider.ChainEntry {
+	return provider.ChainEntry{
+		Role:        p.Role,
+		Auth:        p.auth,
+		FallbackFor: p.FallbackFor,
+		Weight:      p.Weight,
+		Cost:        p.Cost,
+	}
+}
+
+// ContextWindowSize implements provider.Provider.
+func (p *Provider) ContextWindowSize() int {
+	return p.ContextWindow
+}
+
+// ModelName implements provider.Provider.
+func (p *Provider) ModelName() string {
+	return p.Model
+}
+
+// SupportsContent implements provider.ContentSupporter. Images are sent
+// as image blocks; other media are replaced by text placeholders.
+func (p *Provider) SupportsContent(t provider.ContentPartType) bool {
+	return t == provider.ContentPartText || t == provider.ContentPartImage
+}
+
+// Complete implements provider.Provider.
+func (p *Provider) Complete(ctx context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+	ctx, cancel := provider.WithRequestTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	resp, err := p.post(ctx, p.newRequest(req, false))
+	if err != nil {
+		return provider.CompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var body messageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return provider.CompletionResponse{}, provider.TransportError(ctx, "anthropic", fmt.Errorf("decoding response: %w", err))
+	}
+
+	out := provider.CompletionResponse{
+		FinishReason: finishReason(body.StopReason),
+		Usage:        body.Usage.tokenUsage(),
+	}
+	var text strings.Builder
+	for _, b := range body.Content {
+		switch b.Type {
+		case "text":
+			text.WriteString(b.Text)
+		case "tool_use":
+			out.ToolCalls = append(out.ToolCalls, provider.ToolCall{
+				ID:        b.ID,
+				Name:      b.Name,
+				Arguments: arguments(b.Input),
+			})
+		}
+	}
+	out.Content = text.String()
+	return out, nil
+}
+
+// Stream implements provider.Provider. Tool calls are assembled from their
+// input_json_delta events and delivered whole when their block ends.
+func (p *Provider) Stream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+	ctx, cancel := provider.WithRequestTimeout(ctx, p.Timeout)
+
+	resp, err := p.post(ctx, p.newRequest(req, true))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	ch := make(chan provider.StreamChunk, 16)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		defer resp.Body.Close()
+		p.readStream(ctx, resp.Body, ch)
+	}()
+	return ch, nil
+}
+
+// readStream decodes the events of a streamed response into ch.
+func (p *Provider) readStream(ctx context.Context, body io.Reader, ch chan<- provider.StreamChunk) {
+	send := func(c provider.StreamChunk) bool {
+		select {
+		case ch <- c:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var (
+		usage  wireUsage
+		blocks = make(map[int]*toolUseBlock)
+	)
+	events := sse.NewReader(body)
+	for {
+		ev, err := events.Next()
+		if errors.Is(err, io.EOF) {
+			send(provider.StreamChunk{Err: fmt.Errorf("%w: anthropic: stream ended before message_stop", provider.ErrProviderDown)})
+			return
+		}
+		if err != nil {
+			send(provider.StreamChunk{Err: provider.TransportError(ctx, "anthropic", fmt.Errorf("reading stream: %w", err))})
+			return
+		}
+
+		var data streamEvent
+		if err := json.Unmarshal([]byte(ev.Data), &data); err != nil {
+			send(provider.StreamChunk{Err: fmt.Errorf("%w: anthropic: decoding %s event: %v", provider.ErrProviderDown, ev.Type, err)})
+			return
+		}
+
+		var out provider.StreamChunk
+		switch data.Type {
+		case "message_start":
+			usage = data.Message.Usage
+		case "content_block_start":
+			if data.ContentBlock.Type == "tool_use" {
+				blocks[data.Index] = &toolUseBlock{id: data.ContentBlock.ID, name: data.ContentBlock.Name}
+			}
+		case "content_block_delta":
+			switch data.Delta.Type {
+			case "text_delta":
+				out.Content = data.Delta.Text
+			case "input_json_delta":
+				if b := blocks[data.Index]; b != nil {
+					b.input.WriteString(data.Delta.PartialJSON)
+				}
+			}
+		case "content_block_stop":
+			if b := blocks[data.Index]; b != nil {
+				delete(blocks, data.Index)
+				out.ToolCalls = []provider.ToolCall{b.toolCall()}
+			}
+		case "message_delta":
+			usage.OutputTokens = data.Usage.OutputTokens
+			u := usage.tokenUsage()
+			out.FinishReason = finishReason(data.Delta.StopReason)
+			out.Usage = &u
+		case "message_stop":
+			return
+		case "error":
+			send(provider.StreamChunk{Err: classify(0, data.Error)})
+			return
+		default: // ping and future event types.
+			continue
+		}
+
+		if out.Content == "" && out.ToolCalls == nil && out.FinishReason == "" {
+			continue
+		}
+		if !send(out) {
+			return
+		}
+	}
+}
+
+// HealthCheck implements provider.HealthChecker by listing the models of
+// the API, which costs no tokens.
+func (p *Provider) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/v1/models", nil)
+	if err != nil {
+		return fmt.Errorf("anthropic: %w", err)
+	}
+	p.setHeaders(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return provider.TransportError(ctx, "anthropic", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return responseError(resp)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// post sends a Messages request and returns the response when its status
+// is successful.
+func (p *Provider) post(ctx context.Context, body messageRequest) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: encoding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/v1/messages", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if body.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	p.setHeaders(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, provider.TransportError(ctx, "anthropic", err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+func (p *Provider) setHeaders(req *http.Request) {
+	req.Header.Set("anthropic-version", apiVersion)
+	if key := provider.APIKey(req.Context(), p.auth); key != "" {
+		req.Header.Set("x-api-key", key)
+	}
+}
+
+// Interface guards.
+var (
+	_ provider.Provider         = (*Provider)(nil)
+	_ provider.HealthChecker    = (*Provider)(nil)
+	_ provider.ChainMember      = (*Provider)(nil)
+	_ provider.ContentSupporter = (*Provider)(nil)
+	_ core.Configurable         = (*Provider)(nil)
+	_ core.Provisioner          = (*Provider)(nil)
+	_ core.Validator            = (*Provider)(nil)
+)
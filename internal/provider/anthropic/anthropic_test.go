@@ -0,0 +1,413 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/anthropic"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+	"gopkg.in/yaml.v3"
+)
+
+// newServer starts a server answering every request with handler and
+// returns a provider pointed at it.
+func newServer(t *testing.T, handler http.HandlerFunc) *anthropic.Provider {
+	t.Helper()
+	auth, err := provider.NewAuthProfile("key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return anthropic.New(providertest.NewServer(t, handler), "claude-test", auth)
+}
+
+func TestComplete_Request(t *testing.T) {
+	t.Parallel()
+
+	var body []byte
+	var header http.Header
+	var path string
+	p := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, `{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+	})
+
+	_, err := p.Complete(context.Background(), provider.CompletionRequest{
+		Messages: []provider.LLMMessage{
+			{Role: provider.MessageRoleSystem, Content: "You are helpful."},
+			{Role: provider.MessageRoleSystem, Content: "Summary of earlier conversation."},
+			{Role: provider.MessageRoleUser, Content: "look", Parts: []provider.ContentPart{
+				provider.TextPart("look"),
+				provider.ImagePart("data:image/png;base64,AAAA", "image/png"),
+				provider.ImagePart("https://example.com/a.png", "image/png"),
+			}},
+			{Role: provider.MessageRoleAssistant, Content: "Reading.", ToolCalls: []provider.ToolCall{
+				{ID: "tu_1", Name: "read", Arguments: json.RawMessage(`{"path":"a"}`)},
+				{ID: "tu_2", Name: "list"},
+			}},
+			{Role: provider.MessageRoleTool, Name: "read", ToolID: "tu_1", Content: "data"},
+			{Role: provider.MessageRoleTool, Name: "list", ToolID: "tu_2", Content: "boom", IsError: true},
+			{Role: provider.MessageRoleUser, Content: "thanks"},
+		},
+		Tools: []provider.ToolDefinition{
+			{Name: "read", Description: "Read a file", Parameters: json.RawMessage(`{"type":"object","properties":{}}`)},
+			{Name: "list"},
+		},
+		Stop: []string{"END"},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if path != "/v1/messages" {
+		t.Errorf("path = %q, want /v1/messages", path)
+	}
+	if got := header.Get("x-api-key"); got != "key-1" {
+		t.Errorf("x-api-key = %q, want key-1", got)
+	}
+	if got := header.Get("anthropic-version"); got == "" {
+		t.Error("anthropic-version header missing")
+	}
+
+	providertest.JSONEqual(t, body, `{
+		"model": "claude-test",
+		"max_tokens": 4096,
+		"system": "You are helpful.\n\nSummary of earlier conversation.",
+		"stop_sequences": ["END"],
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "text", "text": "look"},
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "AAAA"}},
+				{"type": "image", "source": {"type": "url", "url": "https://example.com/a.png"}}
+			]},
+			{"role": "assistant", "content": [
+				{"type": "text", "text": "Reading."},
+				{"type": "tool_use", "id": "tu_1", "name": "read", "input": {"path": "a"}},
+				{"type": "tool_use", "id": "tu_2", "name": "list", "input": {}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "tu_1", "content": "data"},
+				{"type": "tool_result", "tool_use_id": "tu_2", "content": "boom", "is_error": true},
+				{"type": "text", "text": "thanks"}
+			]}
+		],
+		"tools": [
+			{"name": "read", "description": "Read a file", "input_schema": {"type": "object", "properties": {}}},
+			{"name": "list", "input_schema": {"type": "object"}}
+		]
+	}`)
+}
+
+func TestComplete_Response(t *testing.T) {
+	t.Parallel()
+
+	p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `{
+			"content": [
+				{"type": "text", "text": "Let me check."},
+				{"type": "tool_use", "id": "tu_1", "name": "read", "input": {"path": "a"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 10, "cache_read_input_tokens": 90, "output_tokens": 5}
+		}`)
+	})
+
+	resp, err := p.Complete(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Content != "Let me check." {
+		t.Errorf("Content = %q, want %q", resp.Content, "Let me check.")
+	}
+	if resp.FinishReason != provider.FinishReasonToolUse {
+		t.Errorf("FinishReason = %q, want tool_use", resp.FinishReason)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "tu_1" || string(resp.ToolCalls[0].Arguments) != `{"path": "a"}` {
+		t.Errorf("ToolCalls = %+v", resp.ToolCalls)
+	}
+	want := provider.TokenUsage{PromptTokens: 100, CompletionTokens: 5, TotalTokens: 105}
+	if resp.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", resp.Usage, want)
+	}
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		providertest.WriteSSEEvents(w,
+			"message_start", `{"type":"message_start","message":{"content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`,
+			"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			"ping", `{"type":"ping"}`,
+			"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+			"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+			"content_block_stop", `{"type":"content_block_stop","index":0}`,
+			"content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"tu_1","name":"read","input":{}}}`,
+			"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":""}}`,
+			"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+			"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"a\"}"}}`,
+			"content_block_stop", `{"type":"content_block_stop","index":1}`,
+			"content_block_start", `{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"tu_2","name":"list","input":{}}}`,
+			"content_block_stop", `{"type":"content_block_stop","index":2}`,
+			"message_delta", `{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}`,
+			"message_stop", `{"type":"message_stop"}`,
+		)
+	})
+
+	ch, err := p.Stream(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	content, calls, finish, usage, err := providertest.Collect(ch)
+	if err != nil {
+		t.Fatalf("stream error = %v", err)
+	}
+	if content != "Hello" {
+		t.Errorf("content = %q, want Hello", content)
+	}
+	if finish != provider.FinishReasonToolUse {
+		t.Errorf("finish = %q, want tool_use", finish)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("len(calls) = %d, want 2", len(calls))
+	}
+	if c := calls[0]; c.ID != "tu_1" || c.Name != "read" || string(c.Arguments) != `{"path":"a"}` {
+		t.Errorf("calls[0] = %+v", c)
+	}
+	if c := calls[1]; c.ID != "tu_2" || string(c.Arguments) != "{}" {
+		t.Errorf("calls[1] = %+v", c)
+	}
+	want := provider.TokenUsage{PromptTokens: 12, CompletionTokens: 30, TotalTokens: 42}
+	if usage == nil || *usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
+
+func TestStream_ErrorEvent(t *testing.T) {
+	t.Parallel()
+
+	p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		providertest.WriteSSEEvents(w,
+			"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+			"error", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+		)
+	})
+
+	ch, err := p.Stream(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	content, _, _, _, err := providertest.Collect(ch)
+	if content != "Hel" {
+		t.Errorf("content = %q, want Hel", content)
+	}
+	if !errors.Is(err, provider.ErrProviderDown) {
+		t.Errorf("stream error = %v, want ErrProviderDown", err)
+	}
+}
+
+func TestStream_Truncated(t *testing.T) {
+	t.Parallel()
+
+	p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		providertest.WriteSSEEvents(w, "message_start", `{"type":"message_start","message":{"usage":{}}}`)
+	})
+
+	ch, err := p.Stream(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if _, _, _, _, err := providertest.Collect(ch); !errors.Is(err, provider.ErrProviderDown) {
+		t.Errorf("stream error = %v, want ErrProviderDown", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{
+			name:   "rate limit",
+			status: http.StatusTooManyRequests,
+			body:   `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`,
+			want:   provider.ErrRateLimit,
+		},
+		{
+			name:   "overloaded",
+			status: 529,
+			body:   `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			want:   provider.ErrProviderDown,
+		},
+		{
+			name:   "internal error",
+			status: http.StatusInternalServerError,
+			body:   `{"type":"error","error":{"type":"api_error","message":"oops"}}`,
+			want:   provider.ErrProviderDown,
+		},
+		{
+			name:   "prompt too long",
+			status: http.StatusBadRequest,
+			body:   `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			want:   provider.ErrContextLength,
+		},
+		{
+			name:   "invalid request",
+			status: http.StatusBadRequest,
+			body:   `{"type":"error","error":{"type":"invalid_request_error","message":"messages: field required"}}`,
+			want:   nil,
+		},
+		{
+			name:   "authentication",
+			status: http.StatusUnauthorized,
+			body:   `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`,
+			want:   nil,
+		},
+	}
+
+	sentinels := []error{provider.ErrRateLimit, provider.ErrProviderDown, provider.ErrContextLength}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			})
+
+			_, err := p.Complete(context.Background(), provider.CompletionRequest{})
+			var apiErr *anthropic.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Fatalf("error = %v, want APIError with status %d", err, tt.status)
+			}
+			for _, s := range sentinels {
+				if got := errors.Is(err, s); got != (s == tt.want) {
+					t.Errorf("errors.Is(%v, %v) = %v", err, s, got)
+				}
+			}
+		})
+	}
+}
+
+func TestErrors_RateLimit(t *testing.T) {
+	t.Parallel()
+
+	var gotKey string
+	p := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("x-api-key")
+		w.Header().Set("Retry-After", "30")
+		w.Header().Set("anthropic-ratelimit-requests-remaining", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error","message":"This request would exceed the rate limit for your organization"}}`)
+	})
+
+	ctx := provider.WithCredential(context.Background(), "key-2")
+	_, err := p.Complete(ctx, provider.CompletionRequest{})
+	if gotKey != "key-2" {
+		t.Errorf("x-api-key = %q, want the chain credential key-2", gotKey)
+	}
+	var rl *provider.RateLimitError
+	if !errors.As(err, &rl) {
+		t.Fatalf("error = %v, want RateLimitError", err)
+	}
+	want := provider.RateLimitError{RetryAfter: 30 * time.Second, Remaining: 0, Scope: provider.RateLimitScopeOrg}
+	if *rl != want {
+		t.Errorf("RateLimitError = %+v, want %+v", *rl, want)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	status := http.StatusOK
+	var path string
+	p := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, `{"data":[]}`)
+	})
+
+	if err := p.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() error = %v", err)
+	}
+	if path != "/v1/models" {
+		t.Errorf("path = %q, want /v1/models", path)
+	}
+
+	status = 529
+	if err := p.HealthCheck(context.Background()); !errors.Is(err, provider.ErrProviderDown) {
+		t.Errorf("HealthCheck() error = %v, want ErrProviderDown", err)
+	}
+}
+
+func TestModule(t *testing.T) {
+	t.Parallel()
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(`
+model: claude-sonnet-4-5
+api_keys: [k1, k2]
+max_tokens: 1024
+`), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &anthropic.Provider{}
+	if err := p.Configure(node.Content[0]); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	if err := p.Provision(&core.AppContext{}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if p.BaseURL != anthropic.DefaultBaseURL {
+		t.Errorf("BaseURL = %q, want %q", p.BaseURL, anthropic.DefaultBaseURL)
+	}
+	if p.ContextWindowSize() != anthropic.DefaultContextWindow {
+		t.Errorf("ContextWindowSize() = %d, want %d", p.ContextWindowSize(), anthropic.DefaultContextWindow)
+	}
+	e := provider.EntryFor(anthropic.ModuleID, p)
+	if e.Role != provider.RolePrimary {
+		t.Errorf("Role = %q, want primary", e.Role)
+	}
+	if e.Auth == nil || e.Auth.CurrentKey() != "k1" {
+		t.Error("entry auth missing or wrong key")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		p       anthropic.Provider
+		wantErr bool
+	}{
+		{name: "valid", p: anthropic.Provider{Model: "claude", APIKeys: []string{"k"}}},
+		{name: "missing model", p: anthropic.Provider{APIKeys: []string{"k"}}, wantErr: true},
+		{name: "missing keys", p: anthropic.Provider{Model: "claude"}, wantErr: true},
+		{name: "empty key", p: anthropic.Provider{Model: "claude", APIKeys: []string{""}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+// messageRequest is the body of a Messages request.
+type messageRequest struct {
+	Model         string        `json:"model"`
+	System        string        `json:"system,omitempty"`
+	Messages      []wireMessage `json:"messages"`
+	Tools         []wireTool    `json:"tools,omitempty"`
+	MaxTokens     int           `json:"max_tokens"`
+	Temperature   *float64      `json:"temperature,omitempty"`
+	TopP          *float64      `json:"top_p,omitempty"`
+	StopSequences []string      `json:"stop_sequences,omitempty"`
+	Stream        bool          `json:"stream,omitempty"`
+}
+
+type wireMessage struct {
+	Role    string      `json:"role"`
+	Content []wireBlock `json:"content"`
+}
+
+// wireBlock is a content block of a message: text, image, tool_use or
+// tool_result.
+type wireBlock struct {
+	Type string `json:"type"`
+
+	Text string `json:"text,omitempty"`
+
+	Source *imageSource `json:"source,omitempty"`
+
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+type imageSource struct {
+	Type      string `json:"type"`
+	URL       string `json:"url,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+}
+
+type wireTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// messageResponse is the body of a Messages response.
+type messageResponse struct {
+	Content    []wireBlock `json:"content"`
+	StopReason string      `json:"stop_reason"`
+	Usage      wireUsage   `json:"usage"`
+}
+
+type wireUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// tokenUsage counts cached prompt tokens as prompt tokens: they occupy the
+// context window all the same.
+func (u wireUsage) tokenUsage() provider.TokenUsage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return provider.TokenUsage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+	}
+}
+
+// streamEvent is the data of a streamed event. Which fields are set
+// depends on Type.
+type streamEvent struct {
+	Type         string          `json:"type"`
+	Index        int             `json:"index"`
+	Message      messageResponse `json:"message"`
+	ContentBlock wireBlock       `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage wireUsage `json:"usage"`
+	Error apiError  `json:"error"`
+}
+
+// toolUseBlock is a tool_use block being streamed.
+type toolUseBlock struct {
+	id, name string
+	input    strings.Builder
+}
+
+func (b *toolUseBlock) toolCall() provider.ToolCall {
+	return provider.ToolCall{ID: b.id, Name: b.name, Arguments: arguments(json.RawMessage(b.input.String()))}
+}
+
+func arguments(raw json.RawMessage) json.RawMessage {
+	if len(strings.TrimSpace(string(raw))) == 0 {
+		return json.RawMessage("{}")
+	}
+	return raw
+}
+
+// newRequest converts a completion request to the wire format. System
+// messages, wherever they appear in the history, are merged into the
+// system parameter in order. Tool results become tool_result blocks of a
+// user message, and consecutive messages of the same role are merged, as
+// the API requires alternating roles.
+func (p *Provider) newRequest(req provider.CompletionRequest, stream bool) messageRequest {
+	out := messageRequest{
+		Model:         p.Model,
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: req.Stop,
+		Stream:        stream,
+	}
+	if out.MaxTokens <= 0 {
+		out.MaxTokens = p.MaxTokens
+	}
+
+	var system []string
+	for _, m := range req.Messages {
+		if m.Role == provider.MessageRoleSystem {
+			if text := strings.TrimSpace(m.TextContent()); text != "" {
+				system = append(system, text)
+			}
+			continue
+		}
+
+		role, blocks := toWireBlocks(m)
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == role {
+			out.Messages[n-1].Content = append(out.Messages[n-1].Content, blocks...)
+			continue
+		}
+		out.Messages = append(out.Messages, wireMessage{Role: role, Content: blocks})
+	}
+	out.System = strings.Join(system, "\n\n")
+
+	for _, t := range req.Tools {
+		schema := t.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		out.Tools = append(out.Tools, wireTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: schema,
+		})
+	}
+	return out
+}
+
+// toWireBlocks returns the API role and content blocks of a non-system
+// message.
+func toWireBlocks(m provider.LLMMessage) (string, []wireBlock) {
+	switch m.Role {
+	case provider.MessageRoleTool:
+		return "user", []wireBlock{{
+			Type:      "tool_result",
+			ToolUseID: m.ToolID,
+			Content:   m.TextContent(),
+			IsError:   m.IsError,
+		}}
+	case provider.MessageRoleAssistant:
+		blocks := contentBlocks(m)
+		for _, tc := range m.ToolCalls {
+			blocks = append(blocks, wireBlock{
+				Type:  "tool_use",
+				ID:    tc.ID,
+				Name:  tc.Name,
+				Input: arguments(tc.Arguments),
+			})
+		}
+		return "assistant", blocks
+	default:
+		return "user", contentBlocks(m)
+	}
+}
+
+// contentBlocks returns the text and image blocks of m. Empty text is
+// dropped, as the API rejects empty text blocks.
+func contentBlocks(m provider.LLMMessage) []wireBlock {
+	if !m.HasMedia() {
+		if text := m.TextContent(); strings.TrimSpace(text) != "" {
+			return []wireBlock{{Type: "text", Text: text}}
+		}
+		return nil
+	}
+
+	var blocks []wireBlock
+	for _, part := range m.Parts {
+		switch part.Type {
+		case provider.ContentPartText:
+			if strings.TrimSpace(part.Text) != "" {
+				blocks = append(blocks, wireBlock{Type: "text", Text: part.Text})
+			}
+		case provider.ContentPartImage:
+			blocks = append(blocks, wireBlock{Type: "image", Source: newImageSource(part)})
+		default:
+			return []wireBlock{{Type: "text", Text: m.TextContent()}}
+		}
+	}
+	return blocks
+}
+
+// newImageSource references an image by URL, or inlines it when the URL
+// is a base64 data URL.
+func newImageSource(part provider.ContentPart) *imageSource {
+	if rest, ok := strings.CutPrefix(part.URL, "data:"); ok {
+		meta, data, found := strings.Cut(rest, ",")
+		if mediaType, isBase64 := strings.CutSuffix(meta, ";base64"); found && isBase64 {
+			return &imageSource{Type: "base64", MediaType: mediaType, Data: data}
+		}
+	}
+	return &imageSource{Type: "url", URL: part.URL}
+}
+
+// finishReason maps an API stop reason to a provider.FinishReason.
+func finishReason(s string) provider.FinishReason {
+	switch s {
+	case "max_tokens", "model_context_window_exceeded":
+		return provider.FinishReasonLength
+	case "tool_use":
+		return provider.FinishReasonToolUse
+	case "refusal":
+		return provider.FinishReasonFiltering
+	default:
+		return provider.FinishReasonStop
+	}
+}
+
+// APIError is an error response of the API. It wraps the provider
+// sentinel error matching its cause, if any.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Message    string
+
+	err error
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	b.WriteString("anthropic: ")
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, "HTTP %d: ", e.StatusCode)
+	}
+	if e.Type != "" {
+		fmt.Fprintf(&b, "%s: ", e.Type)
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// Unwrap returns the provider error of e, if any: a
+// *provider.RateLimitError for rate limits, else a sentinel error.
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// apiError is the error object of an error response or stream event.
+type apiError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// responseError builds the error of an unsuccessful response.
+func responseError(resp *http.Response) error {
+	data, text := provider.ReadErrorBody(resp)
+	var body struct {
+		Error apiError `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Error.Message == "" {
+		body.Error.Message = text
+	}
+	e := classify(resp.StatusCode, body.Error)
+	provider.RateLimitHeaders(e, resp.Header, "anthropic-ratelimit-requests-remaining")
+	return e
+}
+
+// classify maps an API error to the provider errors: rate limits
+// to a RateLimitError, overload and server failures to ErrProviderDown, and
+// prompts too long for the model to ErrContextLength.
+func classify(status int, e apiError) *APIError {
+	out := &APIError{StatusCode: status, Type: e.Type, Message: e.Message}
+
+	msg := strings.ToLower(e.Message)
+	switch {
+	case status == http.StatusTooManyRequests || e.Type == "rate_limit_error":
+		// Limits are set per organization; the message says so.
+		scope := provider.RateLimitScopeUnknown
+		if strings.Contains(msg, "organization") {
+			scope = provider.RateLimitScopeOrg
+		}
+		out.err = &provider.RateLimitError{Remaining: -1, Scope: scope}
+	case status == http.StatusRequestEntityTooLarge || e.Type == "request_too_large" ||
+		strings.Contains(msg, "prompt is too long") || strings.Contains(msg, "context window"):
+		out.err = provider.ErrContextLength
+	case status >= http.StatusInternalServerError || status == http.StatusRequestTimeout ||
+		e.Type == "overloaded_error" || e.Type == "api_error":
+		out.err = provider.ErrProviderDown
+	}
+	return out
+}
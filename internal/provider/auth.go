@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Key cooldown bounds, used when a rate limit carries no Retry-After.
+const (
+	keyInitialBackoff = time.Second
+	keyMaxBackoff     = 60 * time.Second
+)
+
+// AuthProfile manages a set of API keys for a single provider,
+// supporting rotation on rate limit errors.
+//
+// A key that hits a rate limit cools down for the delay the provider
+// asked for, or an exponential backoff when it gave none, and rotation
+// skips it until the cooldown expires.
+type AuthProfile struct {
+	mu   sync.Mutex
+	keys []authKey
+	idx  int
+
+	// now is injectable for testing. Defaults to time.Now.
+	now func() time.Time
+}
+
+// authKey is a key and its rate limit state.
+type authKey struct {
+	key           string
+	failures      int
+	cooldownUntil time.Time
+}
+
+// KeyStatus describes the health of one key of an AuthProfile.
+type KeyStatus struct {
+	// Index is the position of the key in the profile.
+	Index int
+
+	// Hint identifies the key without revealing it: its last four
+	// characters.
+	Hint string
+
+	// Active reports whether the key is the one currently in use.
+	Active bool
+
+	// Failures is the number of consecutive rate limits of the key.
+	Failures int
+
+	// CooldownUntil is when the key becomes usable again. Zero or past
+	// means the key is available.
+	CooldownUntil time.Time
+}
+
+// ErrNoKeys is returned when NewAuthProfile is called without any keys.
+var ErrNoKeys = errors.New("AuthProfile requires at least one key")
+
+// NewAuthProfile creates an AuthProfile with the given keys.
+// At least one key is required.
+func NewAuthProfile(keys ...string) (*AuthProfile, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	a := &AuthProfile{keys: make([]authKey, len(keys)), now: time.Now}
+	for i, k := range keys {
+		a.keys[i].key = k
+	}
+	return a, nil
+}
+
+// CurrentKey returns the currently active API key.
+func (a *AuthProfile) CurrentKey() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.keys[a.idx].key
+}
+
+// Rotate advances to the next key in the list that is not cooling down,
+// wrapping around. When every other key is cooling down, it advances to
+// the one whose cooldown ends first. Returns true if rotation happened
+// (i.e. more than one key exists).
+func (a *AuthProfile) Rotate() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rotate()
+}
+
+// rotate implements Rotate. The caller must hold a.mu.
+func (a *AuthProfile) rotate() bool {
+	if len(a.keys) <= 1 {
+		return false
+	}
+	now := a.now()
+	next := -1
+	for i := 1; i < len(a.keys); i++ {
+		j := (a.idx + i) % len(a.keys)
+		if !a.keys[j].cooldownUntil.After(now) {
+			next = j
+			break
+		}
+		if next < 0 || a.keys[j].cooldownUntil.Before(a.keys[next].cooldownUntil) {
+			next = j
+		}
+	}
+	a.idx = next
+	return true
+}
+
+// CurrentIndex returns the zero-based index of the active key.
+func (a *AuthProfile) CurrentIndex() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.idx
+}
+
+// Available reports whether at least one key is not cooling down.
+func (a *AuthProfile) Available() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	for _, k := range a.keys {
+		if !k.cooldownUntil.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire returns the key to use for the next request and its index: the
+// active key, or the next available one if the active key is cooling
+// down. ok is false when every key is cooling down.
+func (a *AuthProfile) acquire() (key string, index int, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	if a.keys[a.idx].cooldownUntil.After(now) {
+		prev := a.idx
+		a.rotate()
+		if a.keys[a.idx].cooldownUntil.After(now) {
+			a.idx = prev
+			return "", 0, false
+		}
+	}
+	return a.keys[a.idx].key, a.idx, true
+}
+
+// RecordRateLimit puts the key at index in cooldown for retryAfter, or
+// for an exponential backoff from 1s to 60s when retryAfter is zero. If
+// the key is the active one, the profile rotates to the next available
+// key. Returns true if the profile still has an available key.
+func (a *AuthProfile) RecordRateLimit(index int, retryAfter time.Duration) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if index < 0 || index >= len(a.keys) {
+		return false
+	}
+
+	k := &a.keys[index]
+	k.failures++
+	d := retryAfter
+	if d <= 0 {
+		d = keyInitialBackoff << min(k.failures-1, 16)
+		d = min(d, keyMaxBackoff)
+	}
+	k.cooldownUntil = a.now().Add(d)
+
+	if index == a.idx {
+		a.rotate()
+	}
+	return !a.keys[a.idx].cooldownUntil.After(a.now())
+}
+
+// RecordSuccess clears the rate limit state of the key at index.
+func (a *AuthProfile) RecordSuccess(index int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if index < 0 || index >= len(a.keys) {
+		return
+	}
+	a.keys[index].failures = 0
+	a.keys[index].cooldownUntil = time.Time{}
+}
+
+// Status returns the health of each key, in order.
+func (a *AuthProfile) Status() []KeyStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]KeyStatus, len(a.keys))
+	for i, k := range a.keys {
+		out[i] = KeyStatus{
+			Index:         i,
+			Hint:          keyHint(k.key),
+			Active:        i == a.idx,
+			Failures:      k.failures,
+			CooldownUntil: k.cooldownUntil,
+		}
+	}
+	return out
+}
+
+// keyHint returns the last four characters of a key, or nothing for keys
+// too short to hide.
+func keyHint(key string) string {
+	if len(key) < 12 {
+		return ""
+	}
+	return "…" + key[len(key)-4:]
+}
+
+// credentialKey is the context key of the request credential.
+type credentialKey struct{}
+
+// WithCredential returns a context carrying the API key a provider must
+// use for the request. The Chain sets it from the entry's AuthProfile.
+func WithCredential(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, credentialKey{}, key)
+}
+
+// CredentialFrom returns the API key carried by ctx, if any. Providers
+// should prefer it over their own default key.
+func CredentialFrom(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(credentialKey{}).(string)
+	return key, ok
+}
+
+// APIKey returns the key to send with a request: the credential carried
+// by ctx, else the active key of auth, which may be nil. It returns ""
+// when neither has one.
+func APIKey(ctx context.Context, auth *AuthProfile) string {
+	if key, ok := CredentialFrom(ctx); ok {
+		return key
+	}
+	if auth != nil {
+		return auth.CurrentKey()
+	}
+	return ""
+}
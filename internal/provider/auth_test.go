@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestAuth(t *testing.T, keys ...string) (*AuthProfile, *fakeTime) {
+	t.Helper()
+	a, err := NewAuthProfile(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft := &fakeTime{current: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a.now = ft.Now
+	return a, ft
+}
+
+func TestAuthProfile_RateLimitSkipsCoolingKey(t *testing.T) {
+	t.Parallel()
+
+	a, ft := newTestAuth(t, "a", "b", "c")
+
+	if !a.RecordRateLimit(0, 30*time.Second) {
+		t.Fatal("RecordRateLimit() = false, want other keys available")
+	}
+	if got := a.CurrentKey(); got != "b" {
+		t.Fatalf("key after rate limit = %q, want b", got)
+	}
+
+	// Wrapping around skips the cooling key.
+	a.Rotate()
+	a.Rotate()
+	if got := a.CurrentKey(); got != "b" {
+		t.Errorf("key after wrap = %q, want b (a is cooling down)", got)
+	}
+
+	ft.Advance(30 * time.Second)
+	a.Rotate()
+	a.Rotate()
+	if got := a.CurrentKey(); got != "a" {
+		t.Errorf("key after cooldown = %q, want a", got)
+	}
+}
+
+func TestAuthProfile_BackoffWithoutRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	a, ft := newTestAuth(t, "a")
+	start := ft.Now()
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	for i, d := range want {
+		a.RecordRateLimit(0, 0)
+		if got := a.Status()[0].CooldownUntil.Sub(start); got != d {
+			t.Errorf("cooldown after %d rate limits = %v, want %v", i+1, got, d)
+		}
+	}
+
+	for range 10 {
+		a.RecordRateLimit(0, 0)
+	}
+	if got := a.Status()[0].CooldownUntil.Sub(start); got != keyMaxBackoff {
+		t.Errorf("cooldown = %v, want capped at %v", got, keyMaxBackoff)
+	}
+}
+
+func TestAuthProfile_AllKeysCooling(t *testing.T) {
+	t.Parallel()
+
+	a, ft := newTestAuth(t, "a", "b")
+
+	a.RecordRateLimit(0, 10*time.Second)
+	if a.RecordRateLimit(1, 20*time.Second) {
+		t.Error("RecordRateLimit() = true, want no key available")
+	}
+	if a.Available() {
+		t.Error("Available() = true, want false")
+	}
+	if _, _, ok := a.acquire(); ok {
+		t.Error("acquire() ok = true, want false")
+	}
+	// The key whose cooldown ends first is active.
+	if got := a.CurrentKey(); got != "a" {
+		t.Errorf("CurrentKey() = %q, want a", got)
+	}
+
+	ft.Advance(10 * time.Second)
+	key, idx, ok := a.acquire()
+	if !ok || key != "a" || idx != 0 {
+		t.Errorf("acquire() = %q, %d, %v, want a, 0, true", key, idx, ok)
+	}
+}
+
+func TestAuthProfile_RecordSuccess(t *testing.T) {
+	t.Parallel()
+
+	a, _ := newTestAuth(t, "a", "b")
+	a.RecordRateLimit(0, 0)
+	a.RecordSuccess(0)
+
+	st := a.Status()[0]
+	if st.Failures != 0 || !st.CooldownUntil.IsZero() {
+		t.Errorf("status after success = %+v, want reset", st)
+	}
+	// Out-of-range indexes are ignored.
+	a.RecordSuccess(5)
+	if a.RecordRateLimit(-1, 0) {
+		t.Error("RecordRateLimit(-1) = true, want false")
+	}
+}
+
+func TestAuthProfile_Status(t *testing.T) {
+	t.Parallel()
+
+	a, _ := newTestAuth(t, "sk-proj-0123456789abcd", "short")
+	a.RecordRateLimit(0, time.Minute)
+
+	st := a.Status()
+	if len(st) != 2 {
+		t.Fatalf("len(Status()) = %d, want 2", len(st))
+	}
+	if st[0].Hint != "…abcd" || st[1].Hint != "" {
+		t.Errorf("hints = %q, %q, want …abcd and empty", st[0].Hint, st[1].Hint)
+	}
+	if st[0].Active || !st[1].Active {
+		t.Errorf("active = %v, %v, want false, true", st[0].Active, st[1].Active)
+	}
+	if st[0].Failures != 1 {
+		t.Errorf("Failures = %d, want 1", st[0].Failures)
+	}
+}
+
+func TestCredential(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := CredentialFrom(context.Background()); ok {
+		t.Error("CredentialFrom(empty context) ok = true")
+	}
+	ctx := WithCredential(context.Background(), "k")
+	if key, ok := CredentialFrom(ctx); !ok || key != "k" {
+		t.Errorf("CredentialFrom() = %q, %v, want k, true", key, ok)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	t.Parallel()
+
+	auth, err := NewAuthProfile("own")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chained := WithCredential(context.Background(), "chain")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		auth *AuthProfile
+		want string
+	}{
+		{"credential first", chained, auth, "chain"},
+		{"own key", context.Background(), auth, "own"},
+		{"none", context.Background(), nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := APIKey(tt.ctx, tt.auth); got != tt.want {
+				t.Errorf("APIKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Strategy selects the order in which a Chain tries the providers of a
+// role. Whatever the strategy, unhealthy providers are skipped and the
+// fallbacks of the role are tried last, in configuration order.
+type Strategy string
+
+// Load balancing strategies.
+const (
+	// StrategyOrdered tries providers in configuration order: the first
+	// healthy one takes all the traffic. This is the default.
+	StrategyOrdered Strategy = "ordered"
+
+	// StrategyRoundRobin starts each request on the next provider in turn.
+	StrategyRoundRobin Strategy = "round_robin"
+
+	// StrategyWeighted starts requests on providers in proportion to
+	// their ChainEntry.Weight, spread evenly over time.
+	StrategyWeighted Strategy = "weighted"
+
+	// StrategyLeastLatency tries providers by increasing average response
+	// time, an exponentially weighted moving average over the successful
+	// calls: the whole response for Complete, until the stream opens for
+	// Stream. Providers without observations are tried first.
+	StrategyLeastLatency Strategy = "least_latency"
+
+	// StrategyLeastCost tries providers by increasing ChainEntry.Cost.
+	StrategyLeastCost Strategy = "least_cost"
+)
+
+// ParseStrategy returns the strategy named s. The empty string is
+// StrategyOrdered.
+func ParseStrategy(s string) (Strategy, error) {
+	switch st := Strategy(s); st {
+	case "":
+		return StrategyOrdered, nil
+	case StrategyOrdered, StrategyRoundRobin, StrategyWeighted, StrategyLeastLatency, StrategyLeastCost:
+		return st, nil
+	default:
+		return "", fmt.Errorf("unknown load balancing strategy %q", s)
+	}
+}
+
+// WithStrategy sets the load balancing strategy of role. Roles without a
+// strategy use StrategyOrdered.
+func WithStrategy(role Role, s Strategy) ChainOption {
+	return func(c *Chain) {
+		if c.balancer.strategies == nil {
+			c.balancer.strategies = make(map[Role]Strategy)
+		}
+		c.balancer.strategies[role] = s
+	}
+}
+
+// latencyAlpha is the weight of a new observation in the latency average.
+const latencyAlpha = 0.3
+
+// latencyAverage is an exponentially weighted moving average of response
+// times.
+type latencyAverage struct {
+	mu    sync.Mutex
+	value time.Duration
+	set   bool
+}
+
+// observe adds a response time to the average.
+func (a *latencyAverage) observe(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.set {
+		a.value, a.set = d, true
+		return
+	}
+	a.value = time.Duration(latencyAlpha*float64(d) + (1-latencyAlpha)*float64(a.value))
+}
+
+// get returns the average, or zero without observations.
+func (a *latencyAverage) get() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.value
+}
+
+// balancer orders the providers of a role by its strategy.
+type balancer struct {
+	strategies map[Role]Strategy
+
+	mu      sync.Mutex
+	next    map[Role]int        // round robin position per role
+	current map[*chainEntry]int // smooth weighted round robin state
+}
+
+// order returns entries, the direct candidates of role, in the order the
+// role's strategy tries them. It does not modify entries.
+func (b *balancer) order(role Role, entries []*chainEntry) []*chainEntry {
+	if len(entries) < 2 {
+		return entries
+	}
+	out := slices.Clone(entries)
+
+	switch b.strategies[role] {
+	case StrategyRoundRobin:
+		b.mu.Lock()
+		if b.next == nil {
+			b.next = make(map[Role]int)
+		}
+		start := b.next[role] % len(out)
+		b.next[role] = start + 1
+		b.mu.Unlock()
+		out = append(out[start:len(out):len(out)], out[:start]...)
+
+	case StrategyWeighted:
+		b.mu.Lock()
+		i := b.pickWeighted(out)
+		b.mu.Unlock()
+		first := out[i]
+		out = append([]*chainEntry{first}, slices.Delete(out, i, i+1)...)
+
+	case StrategyLeastLatency:
+		slices.SortStableFunc(out, func(x, y *chainEntry) int {
+			return cmp.Compare(x.latency.get(), y.latency.get())
+		})
+
+	case StrategyLeastCost:
+		slices.SortStableFunc(out, func(x, y *chainEntry) int {
+			return cmp.Compare(x.Cost, y.Cost)
+		})
+	}
+	return out
+}
+
+// pickWeighted returns the index of the entry to start with, by smooth
+// weighted round robin: over any run of requests, each entry starts a
+// share proportional to its weight, interleaved rather than in bursts.
+// The caller must hold b.mu.
+func (b *balancer) pickWeighted(entries []*chainEntry) int {
+	if b.current == nil {
+		b.current = make(map[*chainEntry]int)
+	}
+	best, total := 0, 0
+	for i, e := range entries {
+		w := e.weight()
+		total += w
+		b.current[e] += w
+		if b.current[e] > b.current[entries[best]] {
+			best = i
+		}
+	}
+	b.current[entries[best]] -= total
+	return best
+}
@@ -0,0 +1,156 @@
+package provider_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+// served returns the content of n successive completions for role.
+func served(t *testing.T, chain *provider.Chain, role provider.Role, n int) []string {
+	t.Helper()
+	out := make([]string, 0, n)
+	for range n {
+		resp, err := chain.Complete(context.Background(), role, provider.CompletionRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, resp.Content)
+	}
+	return out
+}
+
+func TestParseStrategy(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"ordered", "round_robin", "weighted", "least_latency", "least_cost"} {
+		if s, err := provider.ParseStrategy(name); err != nil || string(s) != name {
+			t.Errorf("ParseStrategy(%q) = %q, %v", name, s, err)
+		}
+	}
+	if s, err := provider.ParseStrategy(""); err != nil || s != provider.StrategyOrdered {
+		t.Errorf("ParseStrategy(\"\") = %q, %v, want ordered", s, err)
+	}
+	if _, err := provider.ParseStrategy("random"); err == nil {
+		t.Error("ParseStrategy(random) error = nil, want error")
+	}
+}
+
+func TestStrategy_Ordered(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "a", Provider: okProvider("a"), Role: provider.RolePrimary},
+		{Name: "b", Provider: okProvider("b"), Role: provider.RolePrimary},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := served(t, chain, provider.RolePrimary, 3); !slices.Equal(got, []string{"a", "a", "a"}) {
+		t.Errorf("served = %v, want a only", got)
+	}
+}
+
+func TestStrategy_RoundRobin(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "a", Provider: okProvider("a"), Role: provider.RolePrimary},
+		{Name: "b", Provider: okProvider("b"), Role: provider.RolePrimary},
+		{Name: "c", Provider: okProvider("c"), Role: provider.RolePrimary},
+		{Name: "fb", Provider: okProvider("fb"), Role: provider.RoleFallback},
+	}, provider.WithStrategy(provider.RolePrimary, provider.StrategyRoundRobin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := served(t, chain, provider.RolePrimary, 4)
+	if want := []string{"a", "b", "c", "a"}; !slices.Equal(got, want) {
+		t.Errorf("served = %v, want %v", got, want)
+	}
+}
+
+func TestStrategy_RoundRobinSkipsUnhealthy(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "a", Provider: okProvider("a"), Role: provider.RolePrimary},
+		{Name: "down", Provider: failProvider(provider.ErrProviderDown), Role: provider.RolePrimary, Health: provider.HealthConfig{InitialBackoff: time.Hour}},
+	}, provider.WithStrategy(provider.RolePrimary, provider.StrategyRoundRobin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := served(t, chain, provider.RolePrimary, 4)
+	if want := []string{"a", "a", "a", "a"}; !slices.Equal(got, want) {
+		t.Errorf("served = %v, want %v", got, want)
+	}
+}
+
+func TestStrategy_Weighted(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "a", Provider: okProvider("a"), Role: provider.RolePrimary, Weight: 3},
+		{Name: "b", Provider: okProvider("b"), Role: provider.RolePrimary},
+	}, provider.WithStrategy(provider.RolePrimary, provider.StrategyWeighted))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := served(t, chain, provider.RolePrimary, 8)
+	if n := strings.Count(strings.Join(got, ""), "a"); n != 6 {
+		t.Errorf("served = %v, want a 6 times out of 8", got)
+	}
+	// Smooth weighted round robin interleaves the lighter entry.
+	if got[0] != "a" || got[2] != "b" {
+		t.Errorf("served = %v, want b interleaved", got)
+	}
+}
+
+func TestStrategy_LeastLatency(t *testing.T) {
+	t.Parallel()
+
+	slow := okProvider("slow")
+	slow.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		time.Sleep(20 * time.Millisecond)
+		return provider.CompletionResponse{Content: "slow"}, nil
+	}
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "slow", Provider: slow, Role: provider.RolePrimary},
+		{Name: "fast", Provider: okProvider("fast"), Role: provider.RolePrimary},
+	}, provider.WithStrategy(provider.RolePrimary, provider.StrategyLeastLatency))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither has observations: config order. Then fast has none while
+	// slow has one, then fast is measured faster.
+	got := served(t, chain, provider.RolePrimary, 4)
+	if want := []string{"slow", "fast", "fast", "fast"}; !slices.Equal(got, want) {
+		t.Errorf("served = %v, want %v", got, want)
+	}
+}
+
+func TestStrategy_LeastCost(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "pricey", Provider: okProvider("pricey"), Role: provider.RolePrimary, Cost: 15},
+		{Name: "cheap", Provider: failProvider(provider.ErrProviderDown), Role: provider.RolePrimary, Cost: 0.5},
+		{Name: "mid", Provider: okProvider("mid"), Role: provider.RolePrimary, Cost: 3},
+	}, provider.WithStrategy(provider.RolePrimary, provider.StrategyLeastCost))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cheapest is down: the next cheapest serves.
+	if got := served(t, chain, provider.RolePrimary, 2); !slices.Equal(got, []string{"mid", "mid"}) {
+		t.Errorf("served = %v, want mid", got)
+	}
+}
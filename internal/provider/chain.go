@@ -31,52 +31,6 @@ func (nopHandler) WithGroup(string) slog.Handler {
 	return nopHandler{}
 }
 
-// AuthProfile manages a set of API keys for a single provider,
-// supporting rotation on rate limit errors.
-type AuthProfile struct {
-	mu   sync.Mutex
-	keys []string
-	idx  int
-}
-
-// ErrNoKeys is returned when NewAuthProfile is called without any keys.
-var ErrNoKeys = errors.New("AuthProfile requires at least one key")
-
-// NewAuthProfile creates an AuthProfile with the given keys.
-// At least one key is required.
-func NewAuthProfile(keys ...string) (*AuthProfile, error) {
-	if len(keys) == 0 {
-		return nil, ErrNoKeys
-	}
-	return &AuthProfile{keys: keys}, nil
-}
-
-// CurrentKey returns the currently active API key.
-func (a *AuthProfile) CurrentKey() string {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	return a.keys[a.idx]
-}
-
-// Rotate advances to the next key in the list, wrapping around.
-// Returns true if rotation happened (i.e. more than one key exists).
-func (a *AuthProfile) Rotate() bool {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	if len(a.keys) <= 1 {
-		return false
-	}
-	a.idx = (a.idx + 1) % len(a.keys)
-	return true
-}
-
-// CurrentIndex returns the zero-based index of the active key.
-func (a *AuthProfile) CurrentIndex() int {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	return a.idx
-}
-
 // ChainEntry configures a single provider in the chain.
 type ChainEntry struct {
 	Name        string
@@ -85,12 +39,62 @@ type ChainEntry struct {
 	Auth        *AuthProfile
 	Health      HealthConfig
 	FallbackFor []Role // empty = fallback for all roles
+
+	// Weight is the share of requests of the entry under
+	// StrategyWeighted, relative to the other entries of its role.
+	// Default: 1.
+	Weight int
+
+	// Cost is the relative cost of the entry, such as its price per
+	// million tokens, used by StrategyLeastCost. Zero means free, like a
+	// local model.
+	Cost float64
+}
+
+// ChainMember is an optional interface for provider modules that carry
+// their own chain settings (role, auth, health), typically read from their
+// YAML configuration.
+type ChainMember interface {
+	ChainEntry() ChainEntry
+}
+
+// EntryFor builds the chain entry for a provider registered under name.
+// Providers implementing ChainMember supply their own settings; others
+// join as RolePrimary with default health settings. Name and Provider are
+// always set from the arguments.
+func EntryFor(name string, p Provider) ChainEntry {
+	var e ChainEntry
+	if m, ok := p.(ChainMember); ok {
+		e = m.ChainEntry()
+	}
+	e.Name = name
+	e.Provider = p
+	if e.Role == "" {
+		e.Role = RolePrimary
+	}
+	return e
 }
 
 // chainEntry is the internal representation with health tracking.
 type chainEntry struct {
 	ChainEntry
-	health *healthTracker
+	health  *healthTracker
+	latency latencyAverage
+	stats   entryStats
+}
+
+// weight returns the Weight of the entry, or 1 when unset.
+func (e *chainEntry) weight() int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// observeLatency records the response time of a successful call to e.
+func (e *chainEntry) observeLatency(d time.Duration) {
+	e.latency.observe(d)
+	e.stats.observe(d)
 }
 
 // ChainOption configures optional Chain behavior.
@@ -102,12 +106,42 @@ func WithLogger(l *slog.Logger) ChainOption {
 	return func(c *Chain) { c.logger = l }
 }
 
+// StreamFailover controls how Chain.Stream handles retryable errors that
+// arrive mid-stream, after the provider accepted the request.
+type StreamFailover int
+
+const (
+	// StreamFailoverOff forwards mid-stream errors to the consumer. Only
+	// failures to open a stream fail over. This is the default.
+	StreamFailoverOff StreamFailover = iota
+
+	// StreamFailoverBeforeOutput restarts the request on the next
+	// candidate when the stream fails before emitting any chunk. Errors
+	// after output are forwarded.
+	StreamFailoverBeforeOutput
+
+	// StreamFailoverReset also restarts the request after output was
+	// emitted. The consumer first receives a chunk with Reset set and
+	// must discard everything it received before.
+	StreamFailoverReset
+)
+
+// WithStreamFailover sets how Stream handles mid-stream errors.
+func WithStreamFailover(mode StreamFailover) ChainOption {
+	return func(c *Chain) { c.streamFailover = mode }
+}
+
 // Chain orchestrates failover across multiple providers.
 // It is NOT itself a Provider — it adds role-based routing and
 // health-aware failover on top.
 type Chain struct {
-	entries []chainEntry
-	logger  *slog.Logger
+	entries        []chainEntry
+	logger         *slog.Logger
+	streamFailover StreamFailover
+	balancer       balancer
+	hedges         map[Role]time.Duration
+	subs           subscribers
+	metrics        chainMetrics
 
 	mu     sync.Mutex
 	cancel context.CancelFunc
@@ -146,6 +180,12 @@ func NewChain(entries []ChainEntry, opts ...ChainOption) (*Chain, error) {
 		name := e.Name
 		logger := c.logger
 		e.health.onStateChange = func(from, to healthState) {
+			c.subs.publish(StateEvent{
+				Provider: name,
+				From:     HealthState(from.String()),
+				To:       HealthState(to.String()),
+				Time:     time.Now(),
+			})
 			switch to {
 			case stateCooldown:
 				logger.Warn("provider entered cooldown",
@@ -158,6 +198,10 @@ func NewChain(entries []ChainEntry, opts ...ChainOption) (*Chain, error) {
 					"provider", name,
 					"total_failures", e.health.Failures(),
 				)
+			case stateHalfOpen:
+				logger.Info("provider half-open, probing with live traffic",
+					"provider", name,
+				)
 			case stateHealthy:
 				logger.Info("provider revived",
 					"provider", name,
@@ -200,43 +244,52 @@ func (pc *Chain) Stop() {
 // Complete sends a completion request to the best available provider
 // for the given role, with automatic failover.
 func (pc *Chain) Complete(ctx context.Context, role Role, req CompletionRequest) (CompletionResponse, error) {
-	candidates := pc.candidates(role)
+	resp, _, err := pc.complete(ctx, role, req)
+	return resp, err
+}
+
+// complete implements Complete. It also returns the entry that served
+// the response.
+func (pc *Chain) complete(ctx context.Context, role Role, req CompletionRequest) (CompletionResponse, *chainEntry, error) {
+	candidates := pc.schedule(role)
 	if len(candidates) == 0 {
-		return CompletionResponse{}, fmt.Errorf("%w for role %q", ErrNoProvider, role)
+		return CompletionResponse{}, nil, fmt.Errorf("%w for role %q", ErrNoProvider, role)
+	}
+	if delay := pc.hedges[role]; delay > 0 {
+		return pc.hedgedComplete(ctx, role, req, candidates, delay)
 	}
 
 	var lastErr error
 	for _, e := range candidates {
 		if err := ctx.Err(); err != nil {
-			return CompletionResponse{}, err
+			return CompletionResponse{}, nil, err
 		}
-		if !e.health.IsAvailable() {
+		callCtx, keyIndex, ok := pc.prepare(ctx, e)
+		if !ok {
 			continue
 		}
 
-		resp, err := e.Provider.Complete(ctx, req)
+		start := time.Now()
+		resp, err := e.Provider.Complete(callCtx, req)
+		pc.metrics.call(e, role, err)
 		if err == nil {
-			e.health.RecordSuccess()
-			return resp, nil
+			elapsed := time.Since(start)
+			e.observeLatency(elapsed)
+			pc.metrics.latency(e, role, elapsed)
+			pc.metrics.usage(e, role, resp.Usage)
+			pc.recordSuccess(e, keyIndex)
+			return resp, e, nil
 		}
 
 		lastErr = err
+		e.stats.failed(err)
 
 		// Non-retryable errors stop failover.
 		if !IsRetryable(err) {
-			return CompletionResponse{}, err
-		}
-
-		// Rotate auth on rate limit before recording failure.
-		if IsRateLimit(err) && e.Auth != nil {
-			e.Auth.Rotate()
-			pc.logger.Info("auth key rotated",
-				"provider", e.Name,
-				"key_index", e.Auth.CurrentIndex(),
-			)
+			return CompletionResponse{}, nil, err
 		}
 
-		e.health.RecordFailure()
+		pc.recordFailure(e, keyIndex, err)
 
 		pc.logger.Warn("provider failed, failing over",
 			"provider", e.Name,
@@ -244,56 +297,99 @@ func (pc *Chain) Complete(ctx context.Context, role Role, req CompletionRequest)
 		)
 	}
 
+	return CompletionResponse{}, nil, pc.exhausted(role, lastErr)
+}
+
+// exhausted logs and returns the error of a request no candidate of role
+// served. lastErr is the last failure, nil when every candidate was
+// unavailable.
+func (pc *Chain) exhausted(role Role, lastErr error) error {
 	if lastErr != nil {
 		pc.logger.Error("all providers exhausted",
 			"role", role,
 			"last_error", lastErr,
 		)
-		return CompletionResponse{}, fmt.Errorf("%w: last error: %w", ErrAllProviders, lastErr)
+		return fmt.Errorf("%w: last error: %w", ErrAllProviders, lastErr)
 	}
 	pc.logger.Error("all providers exhausted",
 		"role", role,
 	)
-	return CompletionResponse{}, fmt.Errorf("%w for role %q: all candidates unavailable", ErrAllProviders, role)
+	return fmt.Errorf("%w for role %q: all candidates unavailable", ErrAllProviders, role)
 }
 
 // Stream sends a streaming completion request to the best available
-// provider for the given role, with automatic failover.
+// provider for the given role, with automatic failover. Depending on the
+// StreamFailover mode, errors arriving mid-stream also fail over.
 func (pc *Chain) Stream(ctx context.Context, role Role, req CompletionRequest) (<-chan StreamChunk, error) {
-	candidates := pc.candidates(role)
+	return pc.stream(ctx, role, req, nil)
+}
+
+// stream implements Stream. When served is not nil, it is called with
+// each entry a stream is opened on, before its first chunk is delivered.
+func (pc *Chain) stream(ctx context.Context, role Role, req CompletionRequest, served func(*chainEntry)) (<-chan StreamChunk, error) {
+	candidates := pc.schedule(role)
 	if len(candidates) == 0 {
 		return nil, fmt.Errorf("%w for role %q", ErrNoProvider, role)
 	}
 
-	var lastErr error
-	for _, e := range candidates {
+	var (
+		ch       <-chan StreamChunk
+		e        *chainEntry
+		keyIndex int
+		used     int
+		err      error
+	)
+	if delay := pc.hedges[role]; delay > 0 {
+		ch, e, keyIndex, used, err = pc.hedgedOpenStream(ctx, role, req, candidates, delay)
+	} else {
+		ch, e, keyIndex, used, err = pc.openStream(ctx, role, req, candidates, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if served != nil {
+		served(e)
+	}
+	if pc.streamFailover == StreamFailoverOff {
+		return pc.wrapStream(ch, e, role, keyIndex), nil
+	}
+
+	out := make(chan StreamChunk, cap(ch))
+	go pc.failoverStream(ctx, role, req, out, ch, e, keyIndex, candidates[used+1:], served)
+	return out, nil
+}
+
+// openStream opens a stream on the first candidate that accepts it. It
+// returns the stream, its entry, the key index used and the position of
+// the entry in candidates. lastErr is the failure that led here, if any.
+func (pc *Chain) openStream(ctx context.Context, role Role, req CompletionRequest, candidates []*chainEntry, lastErr error) (<-chan StreamChunk, *chainEntry, int, int, error) {
+	for i, e := range candidates {
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return nil, nil, -1, 0, err
 		}
-		if !e.health.IsAvailable() {
+		callCtx, keyIndex, ok := pc.prepare(ctx, e)
+		if !ok {
 			continue
 		}
 
-		ch, err := e.Provider.Stream(ctx, req)
+		start := time.Now()
+		ch, err := e.Provider.Stream(callCtx, req)
 		if err == nil {
-			return pc.wrapStream(ch, e), nil
+			elapsed := time.Since(start)
+			e.observeLatency(elapsed)
+			pc.metrics.latency(e, role, elapsed)
+			return ch, e, keyIndex, i, nil
 		}
 
+		pc.metrics.call(e, role, err)
 		lastErr = err
+		e.stats.failed(err)
 
 		if !IsRetryable(err) {
-			return nil, err
+			return nil, nil, -1, 0, err
 		}
 
-		if IsRateLimit(err) && e.Auth != nil {
-			e.Auth.Rotate()
-			pc.logger.Info("auth key rotated",
-				"provider", e.Name,
-				"key_index", e.Auth.CurrentIndex(),
-			)
-		}
-
-		e.health.RecordFailure()
+		pc.recordFailure(e, keyIndex, err)
 
 		pc.logger.Warn("provider failed, failing over",
 			"provider", e.Name,
@@ -301,31 +397,99 @@ func (pc *Chain) Stream(ctx context.Context, role Role, req CompletionRequest) (
 		)
 	}
 
-	if lastErr != nil {
-		pc.logger.Error("all providers exhausted",
-			"role", role,
-			"last_error", lastErr,
+	return nil, nil, -1, 0, pc.exhausted(role, lastErr)
+}
+
+// failoverStream forwards src to out. When src fails with a retryable
+// error, it restarts the request on the remaining candidates as the
+// StreamFailover mode allows, sending a Reset chunk first if output was
+// already emitted, and reports each new entry to served, if not nil. It
+// closes out when done.
+func (pc *Chain) failoverStream(ctx context.Context, role Role, req CompletionRequest, out chan<- StreamChunk, src <-chan StreamChunk, e *chainEntry, keyIndex int, rest []*chainEntry, served func(*chainEntry)) {
+	defer close(out)
+	for {
+		emitted, err := pc.forwardStream(out, src, e, role, keyIndex)
+		if err == nil {
+			return
+		}
+		if emitted && pc.streamFailover != StreamFailoverReset {
+			out <- StreamChunk{Err: err}
+			return
+		}
+
+		pc.logger.Warn("provider failed mid-stream, restarting on next provider",
+			"provider", e.Name,
+			"error", err,
+			"reset", emitted,
 		)
-		return nil, fmt.Errorf("%w: last error: %w", ErrAllProviders, lastErr)
+		ch, next, nextKey, used, oerr := pc.openStream(ctx, role, req, rest, err)
+		if oerr != nil {
+			out <- StreamChunk{Err: oerr}
+			return
+		}
+		if served != nil {
+			served(next)
+		}
+		if emitted {
+			out <- StreamChunk{Reset: true}
+		}
+		src, e, keyIndex, rest = ch, next, nextKey, rest[used+1:]
 	}
-	pc.logger.Error("all providers exhausted",
-		"role", role,
-	)
-	return nil, fmt.Errorf("%w for role %q: all candidates unavailable", ErrAllProviders, role)
+}
+
+// forwardStream forwards the chunks of src to out and records the outcome
+// of the call, made for role, in health and metrics. It stops at the
+// first retryable error, which it returns instead of forwarding, and
+// drains src. emitted reports whether any chunk was forwarded before.
+func (pc *Chain) forwardStream(out chan<- StreamChunk, src <-chan StreamChunk, e *chainEntry, role Role, keyIndex int) (emitted bool, err error) {
+	var failed error
+	for chunk := range src {
+		if chunk.Usage != nil {
+			pc.metrics.usage(e, role, *chunk.Usage)
+		}
+		if chunk.Err != nil && IsRetryable(chunk.Err) {
+			e.stats.failed(chunk.Err)
+			pc.metrics.call(e, role, chunk.Err)
+			pc.recordFailure(e, keyIndex, chunk.Err)
+			//nolint:revive // intentional empty drain loop
+			for range src { //nolint:revive
+			}
+			return emitted, chunk.Err
+		}
+		if chunk.Err != nil && failed == nil {
+			failed = chunk.Err
+		}
+		out <- chunk
+		emitted = true
+	}
+	if failed != nil {
+		e.stats.failed(failed)
+	}
+	pc.metrics.call(e, role, failed)
+	pc.recordSuccess(e, keyIndex)
+	return emitted, nil
 }
 
 // wrapStream wraps a provider's stream channel to defer the health verdict.
 // RecordSuccess is only called if the entire stream completes without retryable errors.
-// Mid-stream retryable errors trigger RecordFailure immediately.
-func (pc *Chain) wrapStream(src <-chan StreamChunk, e *chainEntry) <-chan StreamChunk {
+// Mid-stream retryable errors trigger RecordFailure immediately. The
+// outcome of the call, made for role, is recorded in metrics at the end.
+func (pc *Chain) wrapStream(src <-chan StreamChunk, e *chainEntry, role Role, keyIndex int) <-chan StreamChunk {
 	out := make(chan StreamChunk, cap(src))
 	go func() {
 		defer close(out)
 		var sawError bool
+		var failed error
 		for chunk := range src {
+			if chunk.Usage != nil {
+				pc.metrics.usage(e, role, *chunk.Usage)
+			}
+			if chunk.Err != nil && failed == nil {
+				failed = chunk.Err
+			}
 			if chunk.Err != nil && IsRetryable(chunk.Err) {
 				sawError = true
-				e.health.RecordFailure()
+				pc.recordFailure(e, keyIndex, chunk.Err)
 				pc.logger.Warn("mid-stream error degraded provider health",
 					"provider", e.Name,
 					"error", chunk.Err,
@@ -333,13 +497,78 @@ func (pc *Chain) wrapStream(src <-chan StreamChunk, e *chainEntry) <-chan Stream
 			}
 			out <- chunk
 		}
+		if failed != nil {
+			e.stats.failed(failed)
+		}
+		pc.metrics.call(e, role, failed)
 		if !sawError {
-			e.health.RecordSuccess()
+			pc.recordSuccess(e, keyIndex)
 		}
 	}()
 	return out
 }
 
+// prepare readies a call to e and counts it. It returns the context
+// carrying the credential selected from the entry's AuthProfile and the
+// index of that key, or -1 without AuthProfile. ok is false when the
+// provider is unhealthy, not admitting this request while half-open, or
+// all its keys are cooling down.
+func (pc *Chain) prepare(ctx context.Context, e *chainEntry) (callCtx context.Context, keyIndex int, ok bool) {
+	if !e.health.Admit() {
+		return ctx, -1, false
+	}
+	if e.Auth == nil {
+		e.stats.requests.Add(1)
+		return ctx, -1, true
+	}
+	key, idx, ok := e.Auth.acquire()
+	if !ok {
+		pc.logger.Debug("all auth keys cooling down, skipping provider",
+			"provider", e.Name,
+		)
+		return ctx, -1, false
+	}
+	e.stats.requests.Add(1)
+	return WithCredential(ctx, key), idx, true
+}
+
+// recordSuccess records a successful call to e made with the key at
+// keyIndex.
+func (pc *Chain) recordSuccess(e *chainEntry, keyIndex int) {
+	e.health.RecordSuccess()
+	if e.Auth != nil {
+		e.Auth.RecordSuccess(keyIndex)
+	}
+}
+
+// recordFailure records a retryable failure of a call to e made with the
+// key at keyIndex. A rate limit scoped to the key, or of unknown scope, is
+// charged to the key first: it cools down and the profile rotates, and
+// provider health only degrades when no other key is available. Rate
+// limits put the provider in cooldown for the delay it asked for, if any.
+func (pc *Chain) recordFailure(e *chainEntry, keyIndex int, err error) {
+	if !IsRateLimit(err) {
+		e.health.RecordFailure()
+		return
+	}
+
+	retryAfter := RetryAfter(err)
+	scope := rateLimitScope(err)
+	if e.Auth != nil && (scope == RateLimitScopeKey || scope == RateLimitScopeUnknown) {
+		available := e.Auth.RecordRateLimit(keyIndex, retryAfter)
+		pc.logger.Info("auth key rotated",
+			"provider", e.Name,
+			"key_index", e.Auth.CurrentIndex(),
+			"limited_key_index", keyIndex,
+			"retry_after", retryAfter,
+		)
+		if available {
+			return
+		}
+	}
+	e.health.RecordRateLimit(retryAfter)
+}
+
 // minHealthCheckInterval returns the shortest configured check interval
 // across all chain entries. Invalid or zero values fall back to defaults.
 func minHealthCheckInterval(entries []chainEntry) time.Duration {
@@ -370,8 +599,21 @@ func (pc *Chain) GetProvider(role Role) (Provider, error) {
 // candidates returns chain entries matching the given role.
 // Direct role matches come first, then fallback entries.
 func (pc *Chain) candidates(role Role) []*chainEntry {
-	var direct, fallbacks []*chainEntry
+	direct, fallbacks := pc.roleEntries(role)
+	return append(direct, fallbacks...)
+}
 
+// schedule returns the candidates of role in the order a request tries
+// them: direct matches ordered by the role's strategy, then fallback
+// entries.
+func (pc *Chain) schedule(role Role) []*chainEntry {
+	direct, fallbacks := pc.roleEntries(role)
+	return append(pc.balancer.order(role, direct), fallbacks...)
+}
+
+// roleEntries returns the direct matches and the fallback entries of
+// role, in configuration order.
+func (pc *Chain) roleEntries(role Role) (direct, fallbacks []*chainEntry) {
 	for i := range pc.entries {
 		e := &pc.entries[i]
 		if e.Role == role {
@@ -382,8 +624,7 @@ func (pc *Chain) candidates(role Role) []*chainEntry {
 			fallbacks = append(fallbacks, e)
 		}
 	}
-
-	return append(direct, fallbacks...)
+	return direct, fallbacks
 }
 
 // isFallbackFor checks if a fallback entry covers the given role.
@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"context"
+	"sync"
+)
+
+// roleProvider is a Provider bound to one role of a Chain. Every call
+// goes through the chain, with its failover.
+type roleProvider struct {
+	chain *Chain
+	role  Role
+
+	mu     sync.Mutex
+	served *chainEntry // entry of the last response, nil before any
+}
+
+// ForRole returns a Provider serving the given role through the chain,
+// with the same health-aware failover as Complete and Stream. It lets
+// components that take a single Provider, such as the agent loop, benefit
+// from the chain.
+//
+// ContextWindowSize reports the smallest window among the providers that
+// would serve the next request: the available providers of the role, else
+// its available fallbacks. A fallback that takes over with a smaller
+// window rejects a long context, which the agent loop compacts. ModelName
+// reports the model of the provider that served the last response, or of
+// the first available candidate before any.
+func (pc *Chain) ForRole(role Role) Provider {
+	return &roleProvider{chain: pc, role: role}
+}
+
+// Complete implements Provider.
+func (r *roleProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	resp, e, err := r.chain.complete(ctx, r.role, req)
+	if e != nil {
+		r.setServed(e)
+	}
+	return resp, err
+}
+
+// Stream implements Provider.
+func (r *roleProvider) Stream(ctx context.Context, req CompletionRequest) (<-chan StreamChunk, error) {
+	return r.chain.stream(ctx, r.role, req, r.setServed)
+}
+
+// ContextWindowSize implements Provider. It returns the smallest context
+// window among the available direct candidates of the role, else among
+// the available fallbacks, else among all candidates, or zero without
+// candidates.
+func (r *roleProvider) ContextWindowSize() int {
+	direct, fallbacks := r.chain.roleEntries(r.role)
+	for _, tier := range [][]*chainEntry{direct, fallbacks} {
+		if size := minWindow(tier, true); size > 0 {
+			return size
+		}
+	}
+	return minWindow(append(direct, fallbacks...), false)
+}
+
+// minWindow returns the smallest known context window among entries, only
+// counting available ones when availableOnly is set. Unknown windows
+// (zero) are ignored; the result is zero when none is known.
+func minWindow(entries []*chainEntry, availableOnly bool) int {
+	size := 0
+	for _, e := range entries {
+		if availableOnly && !e.health.IsAvailable() {
+			continue
+		}
+		if n := e.Provider.ContextWindowSize(); n > 0 && (size == 0 || n < size) {
+			size = n
+		}
+	}
+	return size
+}
+
+// ModelName implements Provider.
+func (r *roleProvider) ModelName() string {
+	r.mu.Lock()
+	e := r.served
+	r.mu.Unlock()
+	if e != nil {
+		return e.Provider.ModelName()
+	}
+
+	candidates := r.chain.candidates(r.role)
+	for _, e := range candidates {
+		if e.health.IsAvailable() {
+			return e.Provider.ModelName()
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0].Provider.ModelName()
+	}
+	return ""
+}
+
+// SupportsContent implements ContentSupporter. A part type is supported
+// only when every candidate supports it, since any of them may serve the
+// request.
+func (r *roleProvider) SupportsContent(t ContentPartType) bool {
+	for _, e := range r.chain.candidates(r.role) {
+		if cs, ok := e.Provider.(ContentSupporter); ok && !cs.SupportsContent(t) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *roleProvider) setServed(e *chainEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.served = e
+}
+
+// Interface guards.
+var (
+	_ Provider         = (*roleProvider)(nil)
+	_ ContentSupporter = (*roleProvider)(nil)
+)
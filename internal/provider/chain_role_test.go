@@ -0,0 +1,177 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+)
+
+// contentProvider is a mock provider declaring its image support.
+type contentProvider struct {
+	*providertest.MockProvider
+	image bool
+}
+
+func (p *contentProvider) SupportsContent(t provider.ContentPartType) bool {
+	return t == provider.ContentPartText || (p.image && t == provider.ContentPartImage)
+}
+
+func TestForRole_CompleteFailover(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "down", Provider: failProvider(provider.ErrProviderDown), Role: provider.RolePrimary},
+		{Name: "backup", Provider: okProvider("backup"), Role: provider.RoleFallback},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := chain.ForRole(provider.RolePrimary)
+
+	if got := p.ModelName(); got != "fail" {
+		t.Errorf("ModelName() before any call = %q, want first available candidate", got)
+	}
+	resp, err := p.Complete(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "backup" {
+		t.Errorf("Content = %q, want backup", resp.Content)
+	}
+	if got := p.ModelName(); got != "backup" {
+		t.Errorf("ModelName() = %q, want the serving provider backup", got)
+	}
+}
+
+func TestForRole_StreamModelName(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "flaky", Provider: chunkProvider("flaky", provider.StreamChunk{Err: provider.ErrProviderDown}), Role: provider.RolePrimary},
+		{Name: "backup", Provider: okProvider("backup"), Role: provider.RoleFallback},
+	}, provider.WithStreamFailover(provider.StreamFailoverBeforeOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := chain.ForRole(provider.RolePrimary)
+
+	ch, err := p.Stream(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content string
+	for c := range ch {
+		if c.Err != nil {
+			t.Fatalf("stream error: %v", c.Err)
+		}
+		content += c.Content
+	}
+	if content != "backup" {
+		t.Errorf("content = %q, want backup", content)
+	}
+	if got := p.ModelName(); got != "backup" {
+		t.Errorf("ModelName() = %q, want backup after mid-stream failover", got)
+	}
+}
+
+func TestForRole_ContextWindowSize(t *testing.T) {
+	t.Parallel()
+
+	down := false
+	big := okProvider("big")
+	big.ContextWindowSizeFunc = func() int { return 200000 }
+	big.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		if down {
+			return provider.CompletionResponse{}, provider.ErrProviderDown
+		}
+		return provider.CompletionResponse{Content: "big"}, nil
+	}
+	large := okProvider("large")
+	large.ContextWindowSizeFunc = func() int { return 128000 }
+	medium := okProvider("medium")
+	medium.ContextWindowSizeFunc = func() int { return 32768 }
+	small := okProvider("small")
+	small.ContextWindowSizeFunc = func() int { return 8192 }
+	other := okProvider("other")
+	other.ContextWindowSizeFunc = func() int { return 1024 }
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "big", Provider: big, Role: provider.RolePrimary},
+		{Name: "small", Provider: small, Role: provider.RoleFallback},
+		{Name: "other", Provider: other, Role: provider.RoleInternal},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := chain.ForRole(provider.RolePrimary)
+
+	if got := p.ContextWindowSize(); got != 200000 {
+		t.Errorf("ContextWindowSize() = %d, want 200000 while the primary is available", got)
+	}
+	down = true
+	if _, err := p.Complete(context.Background(), provider.CompletionRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.ContextWindowSize(); got != 8192 {
+		t.Errorf("ContextWindowSize() = %d, want the fallback's 8192 while the primary cools down", got)
+	}
+
+	balanced, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "large", Provider: large, Role: provider.RolePrimary},
+		{Name: "medium", Provider: medium, Role: provider.RolePrimary},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := balanced.ForRole(provider.RolePrimary).ContextWindowSize(); got != 32768 {
+		t.Errorf("ContextWindowSize() = %d, want the smallest available primary 32768", got)
+	}
+}
+
+func TestForRole_SupportsContent(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "vision", Provider: &contentProvider{MockProvider: okProvider("vision"), image: true}, Role: provider.RolePrimary},
+		{Name: "text", Provider: &contentProvider{MockProvider: okProvider("text")}, Role: provider.RoleFallback},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, ok := chain.ForRole(provider.RolePrimary).(provider.ContentSupporter)
+	if !ok {
+		t.Fatal("ForRole() does not implement ContentSupporter")
+	}
+	if cs.SupportsContent(provider.ContentPartImage) {
+		t.Error("SupportsContent(image) = true, want false: a candidate lacks it")
+	}
+	if !cs.SupportsContent(provider.ContentPartText) {
+		t.Error("SupportsContent(text) = false, want true")
+	}
+}
+
+func TestForRole_NoProvider(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "p", Provider: okProvider("p"), Role: provider.RolePrimary},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := chain.ForRole(provider.RoleInternal)
+
+	if _, err := p.Complete(context.Background(), provider.CompletionRequest{}); !errors.Is(err, provider.ErrNoProvider) {
+		t.Errorf("Complete() error = %v, want ErrNoProvider", err)
+	}
+	if _, err := p.Stream(context.Background(), provider.CompletionRequest{}); !errors.Is(err, provider.ErrNoProvider) {
+		t.Errorf("Stream() error = %v, want ErrNoProvider", err)
+	}
+	if p.ModelName() != "" || p.ContextWindowSize() != 0 {
+		t.Errorf("ModelName() = %q, ContextWindowSize() = %d, want empty", p.ModelName(), p.ContextWindowSize())
+	}
+}
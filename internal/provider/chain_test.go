@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -379,6 +380,142 @@ func TestProviderChain_AuthRotation(t *testing.T) {
 	}
 }
 
+// retryAfterError is a rate limit carrying a server-requested delay.
+type retryAfterError time.Duration
+
+func (e retryAfterError) Error() string             { return "rate limited" }
+func (e retryAfterError) Unwrap() error             { return provider.ErrRateLimit }
+func (e retryAfterError) RetryDelay() time.Duration { return time.Duration(e) }
+
+func TestProviderChain_PassesCredential(t *testing.T) {
+	t.Parallel()
+
+	auth, err := provider.NewAuthProfile("key1", "key2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	p := &providertest.MockProvider{
+		CompleteFunc: func(ctx context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+			key, _ := provider.CredentialFrom(ctx)
+			seen = append(seen, key)
+			if key == "key1" {
+				return provider.CompletionResponse{}, &provider.RateLimitError{RetryAfter: time.Minute, Remaining: -1}
+			}
+			return provider.CompletionResponse{Content: key}, nil
+		},
+		ContextWindowSizeFunc: func() int { return 4096 },
+		ModelNameFunc:         func() string { return "test" },
+	}
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "p", Provider: p, Role: provider.RolePrimary, Auth: auth},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{}); !errors.Is(err, provider.ErrAllProviders) {
+		t.Fatalf("first call err = %v, want ErrAllProviders", err)
+	}
+	resp, err := chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+	if err != nil {
+		t.Fatalf("second call err = %v, want nil (provider healthy, key2 available)", err)
+	}
+	if resp.Content != "key2" {
+		t.Errorf("Content = %q, want key2", resp.Content)
+	}
+	if want := []string{"key1", "key2"}; !slices.Equal(seen, want) {
+		t.Errorf("credentials = %v, want %v", seen, want)
+	}
+
+	st := auth.Status()
+	if st[0].Failures != 1 || st[0].CooldownUntil.IsZero() {
+		t.Errorf("key1 status = %+v, want cooling down", st[0])
+	}
+}
+
+func TestProviderChain_SkipsEntryWithAllKeysCooling(t *testing.T) {
+	t.Parallel()
+
+	auth, err := provider.NewAuthProfile("key1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.RecordRateLimit(0, time.Hour)
+
+	primaryCalled := false
+	primary := okProvider("primary")
+	primary.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		primaryCalled = true
+		return provider.CompletionResponse{}, nil
+	}
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "primary", Provider: primary, Role: provider.RolePrimary, Auth: auth},
+		{Name: "fallback", Provider: okProvider("fallback"), Role: provider.RoleFallback},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primaryCalled {
+		t.Error("primary called while all its keys are cooling down")
+	}
+	if resp.Content != "fallback" {
+		t.Errorf("Content = %q, want fallback", resp.Content)
+	}
+}
+
+func TestProviderChain_ScopedRateLimitCoolsProvider(t *testing.T) {
+	t.Parallel()
+
+	auth, err := provider.NewAuthProfile("key1", "key2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	primary := okProvider("primary")
+	primary.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		calls++
+		return provider.CompletionResponse{}, &provider.RateLimitError{
+			RetryAfter: time.Hour,
+			Remaining:  0,
+			Scope:      provider.RateLimitScopeOrg,
+		}
+	}
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "primary", Provider: primary, Role: provider.RolePrimary, Auth: auth},
+		{Name: "fallback", Provider: okProvider("fallback"), Role: provider.RoleFallback},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		resp, err := chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Content != "fallback" {
+			t.Errorf("Content = %q, want fallback", resp.Content)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("primary calls = %d, want 1 (cooling down for Retry-After)", calls)
+	}
+	// An org-wide limit is not charged to the key.
+	if auth.CurrentKey() != "key1" || auth.Status()[0].Failures != 0 {
+		t.Errorf("key status = %+v, want key1 untouched", auth.Status()[0])
+	}
+}
+
 func TestProviderChain_Stream(t *testing.T) {
 	t.Parallel()
 
@@ -691,6 +828,51 @@ func TestProviderChain_HealthCheckRevival(t *testing.T) {
 	}
 }
 
+func TestProviderChain_HalfOpenRevivalWithoutHealthChecker(t *testing.T) {
+	t.Parallel()
+
+	logger, buf := testLogger()
+
+	calls := 0
+	flaky := okProvider("flaky")
+	flaky.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		calls++
+		if calls == 1 {
+			return provider.CompletionResponse{}, provider.ErrProviderDown
+		}
+		return provider.CompletionResponse{Content: "flaky"}, nil
+	}
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{
+			Name:     "flaky",
+			Provider: flaky,
+			Role:     provider.RolePrimary,
+			Health:   provider.HealthConfig{MaxFailures: 1, HalfOpenAfter: 20 * time.Millisecond, HalfOpenFraction: 1},
+		},
+		{Name: "backup", Provider: okProvider("backup"), Role: provider.RoleFallback},
+	}, provider.WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No Start: the chain never probes, only live traffic can revive.
+	if got := served(t, chain, provider.RolePrimary, 2); !slices.Equal(got, []string{"backup", "backup"}) {
+		t.Fatalf("served = %v, want backup while flaky is dead", got)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := served(t, chain, provider.RolePrimary, 2); !slices.Equal(got, []string{"flaky", "flaky"}) {
+		t.Errorf("served = %v, want flaky revived by a live request", got)
+	}
+
+	logs := buf.String()
+	for _, msg := range []string{"provider marked dead", "provider half-open", "provider revived"} {
+		if !strings.Contains(logs, msg) {
+			t.Errorf("logs missing %q:\n%s", msg, logs)
+		}
+	}
+}
+
 func TestProviderChain_ConcurrentAccess(t *testing.T) {
 	t.Parallel()
 
@@ -1184,6 +1366,152 @@ func TestProviderChain_StreamMidStreamError(t *testing.T) {
 	}
 }
 
+// chunkProvider streams the given chunks.
+func chunkProvider(name string, chunks ...provider.StreamChunk) *providertest.MockProvider {
+	p := okProvider(name)
+	p.StreamFunc = func(_ context.Context, _ provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+		ch := make(chan provider.StreamChunk, len(chunks))
+		for _, c := range chunks {
+			ch <- c
+		}
+		close(ch)
+		return ch, nil
+	}
+	return p
+}
+
+func TestProviderChain_StreamMidStreamFailover(t *testing.T) {
+	t.Parallel()
+
+	down := provider.StreamChunk{Err: provider.ErrProviderDown}
+	tests := []struct {
+		name      string
+		mode      provider.StreamFailover
+		primary   []provider.StreamChunk
+		fallback  bool
+		want      []string
+		wantErr   error
+		wantReset bool
+	}{
+		{
+			name:     "off forwards error",
+			mode:     provider.StreamFailoverOff,
+			primary:  []provider.StreamChunk{down},
+			fallback: true,
+			wantErr:  provider.ErrProviderDown,
+		},
+		{
+			name:     "before output restarts",
+			mode:     provider.StreamFailoverBeforeOutput,
+			primary:  []provider.StreamChunk{down},
+			fallback: true,
+			want:     []string{"fallback"},
+		},
+		{
+			name:     "before output keeps error after output",
+			mode:     provider.StreamFailoverBeforeOutput,
+			primary:  []provider.StreamChunk{{Content: "hel"}, down},
+			fallback: true,
+			want:     []string{"hel"},
+			wantErr:  provider.ErrProviderDown,
+		},
+		{
+			name:      "reset restarts after output",
+			mode:      provider.StreamFailoverReset,
+			primary:   []provider.StreamChunk{{Content: "hel"}, down, {Content: "lost"}},
+			fallback:  true,
+			want:      []string{"hel", "fallback"},
+			wantReset: true,
+		},
+		{
+			name:    "reset without candidates left",
+			mode:    provider.StreamFailoverReset,
+			primary: []provider.StreamChunk{{Content: "hel"}, down},
+			want:    []string{"hel"},
+			wantErr: provider.ErrAllProviders,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			entries := []provider.ChainEntry{
+				{Name: "primary", Provider: chunkProvider("primary", tt.primary...), Role: provider.RolePrimary},
+			}
+			if tt.fallback {
+				entries = append(entries, provider.ChainEntry{Name: "fallback", Provider: okProvider("fallback"), Role: provider.RoleFallback})
+			}
+			chain, err := provider.NewChain(entries, provider.WithStreamFailover(tt.mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ch, err := chain.Stream(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+			if err != nil {
+				t.Fatalf("Stream: %v", err)
+			}
+			var (
+				content  []string
+				gotErr   error
+				gotReset bool
+			)
+			for c := range ch {
+				switch {
+				case c.Err != nil:
+					gotErr = c.Err
+				case c.Reset:
+					if gotReset || len(content) == 0 {
+						t.Errorf("unexpected reset after %v", content)
+					}
+					gotReset = true
+				default:
+					content = append(content, c.Content)
+				}
+			}
+
+			if !slices.Equal(content, tt.want) {
+				t.Errorf("content = %v, want %v", content, tt.want)
+			}
+			if gotReset != tt.wantReset {
+				t.Errorf("reset = %v, want %v", gotReset, tt.wantReset)
+			}
+			if !errors.Is(gotErr, tt.wantErr) || (tt.wantErr == nil) != (gotErr == nil) {
+				t.Errorf("stream error = %v, want %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProviderChain_StreamFailoverRecordsHealth(t *testing.T) {
+	t.Parallel()
+
+	primary := chunkProvider("primary", provider.StreamChunk{Err: provider.ErrProviderDown})
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "primary", Provider: primary, Role: provider.RolePrimary, Health: provider.HealthConfig{InitialBackoff: time.Hour}},
+		{Name: "fallback", Provider: okProvider("fallback"), Role: provider.RoleFallback},
+	}, provider.WithStreamFailover(provider.StreamFailoverBeforeOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := chain.Stream(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range ch { //nolint:revive
+	}
+
+	// The primary is cooling down: the next call goes to the fallback.
+	p, err := chain.GetProvider(provider.RolePrimary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ModelName() != "fallback" {
+		t.Errorf("provider = %q, want fallback after mid-stream failure", p.ModelName())
+	}
+}
+
 func TestProviderChain_StreamSuccessAfterFullConsumption(t *testing.T) {
 	t.Parallel()
 
@@ -1218,3 +1546,40 @@ func TestProviderChain_StreamSuccessAfterFullConsumption(t *testing.T) {
 		t.Errorf("content = %q, want %q", resp.Content, "streamer")
 	}
 }
+
+// memberProvider is a provider that declares its own chain settings.
+type memberProvider struct {
+	*providertest.MockProvider
+	entry provider.ChainEntry
+}
+
+func (m memberProvider) ChainEntry() provider.ChainEntry { return m.entry }
+
+func TestEntryFor(t *testing.T) {
+	t.Parallel()
+
+	plain := okProvider("plain")
+	e := provider.EntryFor("provider.plain", plain)
+	if e.Name != "provider.plain" || e.Provider != plain || e.Role != provider.RolePrimary {
+		t.Errorf("plain entry = %+v, want primary provider.plain", e)
+	}
+
+	member := memberProvider{
+		MockProvider: okProvider("member"),
+		entry: provider.ChainEntry{
+			Name:        "ignored",
+			Role:        provider.RoleFallback,
+			FallbackFor: []provider.Role{provider.RolePrimary},
+		},
+	}
+	e = provider.EntryFor("provider.member", member)
+	if e.Name != "provider.member" {
+		t.Errorf("Name = %q, want provider.member", e.Name)
+	}
+	if e.Role != provider.RoleFallback || len(e.FallbackFor) != 1 {
+		t.Errorf("member settings not kept: %+v", e)
+	}
+	if e.Provider == nil || e.Provider.ModelName() != "member" {
+		t.Error("Provider not set from argument")
+	}
+}
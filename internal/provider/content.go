@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// ContentPartType discriminates the variant stored in a ContentPart.
+type ContentPartType string
+
+// ContentPartType constants for structured message content.
+const (
+	ContentPartText  ContentPartType = "text"
+	ContentPartImage ContentPartType = "image"
+	ContentPartAudio ContentPartType = "audio"
+	ContentPartFile  ContentPartType = "file"
+)
+
+// ContentPart is one piece of a multimodal LLMMessage. The Type field
+// discriminates which fields are meaningful.
+type ContentPart struct {
+	Type     ContentPartType `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	URL      string          `json:"url,omitempty"`
+	MIMEType string          `json:"mime_type,omitempty"`
+	FileName string          `json:"file_name,omitempty"`
+	IsVoice  bool            `json:"is_voice,omitempty"`
+}
+
+// ContentSupporter is an optional interface that providers may implement
+// to declare which content part types their model accepts. Providers that
+// do not implement it are assumed to accept every part type.
+type ContentSupporter interface {
+	SupportsContent(t ContentPartType) bool
+}
+
+// TextPart creates a text content part.
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentPartText, Text: text}
+}
+
+// ImagePart creates an image content part.
+func ImagePart(url, mimeType string) ContentPart {
+	return ContentPart{Type: ContentPartImage, URL: url, MIMEType: mimeType}
+}
+
+// placeholder renders a non-text part as a short textual description,
+// used when the target model cannot consume the part natively.
+func (p ContentPart) placeholder() string {
+	switch p.Type {
+	case ContentPartText:
+		return p.Text
+	case ContentPartImage:
+		return describeMedia("image", p.URL, p.MIMEType)
+	case ContentPartAudio:
+		kind := "audio"
+		if p.IsVoice {
+			kind = "voice message"
+		}
+		return describeMedia(kind, p.URL, p.MIMEType)
+	case ContentPartFile:
+		name := p.FileName
+		if name == "" {
+			name = p.URL
+		}
+		return describeMedia("file", name, p.MIMEType)
+	default:
+		return ""
+	}
+}
+
+func describeMedia(kind, ref, mimeType string) string {
+	if mimeType == "" {
+		return fmt.Sprintf("[%s: %s]", kind, ref)
+	}
+	return fmt.Sprintf("[%s: %s (%s)]", kind, ref, mimeType)
+}
+
+// TextContent returns the textual rendering of the message. Plain messages
+// return Content as-is; multimodal messages render each part, using a
+// placeholder for media, joined by newlines.
+func (m LLMMessage) TextContent() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	return partsText(m.Parts)
+}
+
+// HasMedia reports whether the message carries any non-text part.
+func (m LLMMessage) HasMedia() bool {
+	for _, p := range m.Parts {
+		if p.Type != ContentPartText {
+			return true
+		}
+	}
+	return false
+}
+
+func partsText(parts []ContentPart) string {
+	lines := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if s := p.placeholder(); s != "" {
+			lines = append(lines, s)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PartsFromBlocks converts channel content blocks into content parts,
+// preserving their order. Locations and reactions become text parts since
+// no model consumes them natively; captions follow their media as text;
+// raw blocks are dropped.
+func PartsFromBlocks(blocks []message.ContentBlock) []ContentPart {
+	parts := make([]ContentPart, 0, len(blocks))
+	for _, b := range blocks {
+		switch b.Type {
+		case message.BlockText:
+			if b.Text != "" {
+				parts = append(parts, TextPart(b.Text))
+			}
+		case message.BlockImage:
+			parts = append(parts, ImagePart(b.URL, b.MIMEType))
+		case message.BlockAudio:
+			parts = append(parts, ContentPart{
+				Type:     ContentPartAudio,
+				URL:      b.URL,
+				MIMEType: b.MIMEType,
+				IsVoice:  b.IsVoice,
+			})
+		case message.BlockFile:
+			parts = append(parts, ContentPart{
+				Type:     ContentPartFile,
+				URL:      b.URL,
+				MIMEType: b.MIMEType,
+				FileName: b.FileName,
+			})
+		case message.BlockLocation:
+			var lat, lon float64
+			if b.Lat != nil {
+				lat = *b.Lat
+			}
+			if b.Lon != nil {
+				lon = *b.Lon
+			}
+			parts = append(parts, TextPart(fmt.Sprintf("[location: %g, %g]", lat, lon)))
+		case message.BlockReaction:
+			parts = append(parts, TextPart(fmt.Sprintf("[reaction: %s]", b.Emoji)))
+		default: // message.BlockRaw and unknown types carry nothing for the model.
+			continue
+		}
+		if b.Caption != "" {
+			parts = append(parts, TextPart(b.Caption))
+		}
+	}
+	return parts
+}
+
+// UserMessageFromInbound builds a user LLMMessage from an inbound channel
+// message. Text-only messages use Content alone; messages with media also
+// carry the structured Parts, with Content set to the textual rendering.
+func UserMessageFromInbound(msg *message.InboundMessage) LLMMessage {
+	parts := PartsFromBlocks(msg.Blocks)
+	out := LLMMessage{
+		Role:    MessageRoleUser,
+		Content: partsText(parts),
+	}
+	for _, p := range parts {
+		if p.Type != ContentPartText {
+			out.Parts = parts
+			break
+		}
+	}
+	return out
+}
+
+// AdaptMessages prepares a message history for the given provider. When the
+// provider implements ContentSupporter, parts it cannot accept are replaced
+// by their textual placeholder, and messages left with text only are
+// collapsed back to plain Content. The input slice is never modified; it is
+// returned as-is when no adaptation is needed.
+func AdaptMessages(p Provider, msgs []LLMMessage) []LLMMessage {
+	cs, ok := p.(ContentSupporter)
+	if !ok {
+		return msgs
+	}
+
+	var out []LLMMessage
+	for i, m := range msgs {
+		if !needsAdapting(cs, m) {
+			if out != nil {
+				out = append(out, m)
+			}
+			continue
+		}
+		if out == nil {
+			out = make([]LLMMessage, i, len(msgs))
+			copy(out, msgs[:i])
+		}
+		out = append(out, adaptMessage(cs, m))
+	}
+	if out == nil {
+		return msgs
+	}
+	return out
+}
+
+func needsAdapting(cs ContentSupporter, m LLMMessage) bool {
+	for _, p := range m.Parts {
+		if !cs.SupportsContent(p.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+func adaptMessage(cs ContentSupporter, m LLMMessage) LLMMessage {
+	parts := make([]ContentPart, 0, len(m.Parts))
+	textOnly := true
+	for _, p := range m.Parts {
+		if !cs.SupportsContent(p.Type) {
+			p = TextPart(p.placeholder())
+		}
+		if p.Type != ContentPartText {
+			textOnly = false
+		}
+		parts = append(parts, p)
+	}
+
+	m.Content = partsText(parts)
+	if textOnly {
+		m.Parts = nil
+	} else {
+		m.Parts = parts
+	}
+	return m
+}
@@ -0,0 +1,204 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+func TestPartsFromBlocks(t *testing.T) {
+	t.Parallel()
+
+	img := message.NewImageBlock("https://example.com/cat.png", "image/png")
+	img.Caption = "my cat"
+
+	got := PartsFromBlocks([]message.ContentBlock{
+		message.NewTextBlock("look"),
+		img,
+		message.NewAudioBlock("https://example.com/v.ogg", "audio/ogg", true),
+		message.NewFileBlock("https://example.com/r.pdf", "application/pdf", "r.pdf"),
+		message.NewLocationBlock(48.8566, 2.3522),
+		message.NewReactionBlock("thumbsup"),
+		message.NewRawBlock([]byte(`{"k":"v"}`)),
+		message.NewTextBlock(""),
+	})
+
+	want := []ContentPart{
+		TextPart("look"),
+		ImagePart("https://example.com/cat.png", "image/png"),
+		TextPart("my cat"),
+		{Type: ContentPartAudio, URL: "https://example.com/v.ogg", MIMEType: "audio/ogg", IsVoice: true},
+		{Type: ContentPartFile, URL: "https://example.com/r.pdf", MIMEType: "application/pdf", FileName: "r.pdf"},
+		TextPart("[location: 48.8566, 2.3522]"),
+		TextPart("[reaction: thumbsup]"),
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PartsFromBlocks() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestUserMessageFromInbound(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		blocks      []message.ContentBlock
+		wantContent string
+		wantParts   int
+	}{
+		{
+			name:        "text only",
+			blocks:      []message.ContentBlock{message.NewTextBlock("a"), message.NewTextBlock("b")},
+			wantContent: "a\nb",
+			wantParts:   0,
+		},
+		{
+			name: "with image",
+			blocks: []message.ContentBlock{
+				message.NewTextBlock("what is this?"),
+				message.NewImageBlock("https://example.com/x.jpg", "image/jpeg"),
+			},
+			wantContent: "what is this?\n[image: https://example.com/x.jpg (image/jpeg)]",
+			wantParts:   2,
+		},
+		{
+			name:        "location only",
+			blocks:      []message.ContentBlock{message.NewLocationBlock(1, 2)},
+			wantContent: "[location: 1, 2]",
+			wantParts:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := UserMessageFromInbound(&message.InboundMessage{Blocks: tt.blocks})
+			if got.Role != MessageRoleUser {
+				t.Errorf("Role = %q, want %q", got.Role, MessageRoleUser)
+			}
+			if got.Content != tt.wantContent {
+				t.Errorf("Content = %q, want %q", got.Content, tt.wantContent)
+			}
+			if len(got.Parts) != tt.wantParts {
+				t.Errorf("len(Parts) = %d, want %d", len(got.Parts), tt.wantParts)
+			}
+		})
+	}
+}
+
+func TestLLMMessageTextContent(t *testing.T) {
+	t.Parallel()
+
+	plain := LLMMessage{Role: MessageRoleUser, Content: "hi"}
+	if got := plain.TextContent(); got != "hi" {
+		t.Errorf("TextContent() = %q, want %q", got, "hi")
+	}
+	if plain.HasMedia() {
+		t.Error("HasMedia() = true for plain message")
+	}
+
+	multi := LLMMessage{
+		Role: MessageRoleUser,
+		Parts: []ContentPart{
+			TextPart("listen"),
+			{Type: ContentPartAudio, URL: "u", IsVoice: true},
+			{Type: ContentPartFile, URL: "u2"},
+		},
+	}
+	want := "listen\n[voice message: u]\n[file: u2]"
+	if got := multi.TextContent(); got != want {
+		t.Errorf("TextContent() = %q, want %q", got, want)
+	}
+	if !multi.HasMedia() {
+		t.Error("HasMedia() = false for multimodal message")
+	}
+}
+
+// textOnlyProvider declares support for text parts only.
+type textOnlyProvider struct{ Provider }
+
+func (textOnlyProvider) SupportsContent(t ContentPartType) bool {
+	return t == ContentPartText
+}
+
+// imageProvider accepts text and images but not audio or files.
+type imageProvider struct{ Provider }
+
+func (imageProvider) SupportsContent(t ContentPartType) bool {
+	return t == ContentPartText || t == ContentPartImage
+}
+
+// plainProvider implements Provider without ContentSupporter.
+type plainProvider struct{}
+
+func (plainProvider) Complete(context.Context, CompletionRequest) (CompletionResponse, error) {
+	return CompletionResponse{}, nil
+}
+
+func (plainProvider) Stream(context.Context, CompletionRequest) (<-chan StreamChunk, error) {
+	return nil, nil
+}
+func (plainProvider) ContextWindowSize() int { return 0 }
+func (plainProvider) ModelName() string      { return "plain" }
+
+func TestAdaptMessages(t *testing.T) {
+	t.Parallel()
+
+	msgs := []LLMMessage{
+		{Role: MessageRoleSystem, Content: "be nice"},
+		{
+			Role:    MessageRoleUser,
+			Content: "see\n[image: u (image/png)]\n[voice message: v]",
+			Parts: []ContentPart{
+				TextPart("see"),
+				ImagePart("u", "image/png"),
+				{Type: ContentPartAudio, URL: "v", IsVoice: true},
+			},
+		},
+	}
+
+	t.Run("no declaration passes through", func(t *testing.T) {
+		t.Parallel()
+
+		got := AdaptMessages(plainProvider{}, msgs)
+		if &got[0] != &msgs[0] {
+			t.Error("expected the original slice to be returned")
+		}
+	})
+
+	t.Run("text only collapses to content", func(t *testing.T) {
+		t.Parallel()
+
+		got := AdaptMessages(textOnlyProvider{}, msgs)
+		if len(got) != 2 {
+			t.Fatalf("len = %d, want 2", len(got))
+		}
+		if got[1].Parts != nil {
+			t.Errorf("Parts = %+v, want nil", got[1].Parts)
+		}
+		if got[1].Content != "see\n[image: u (image/png)]\n[voice message: v]" {
+			t.Errorf("Content = %q", got[1].Content)
+		}
+		if len(msgs[1].Parts) != 3 {
+			t.Error("input history was modified")
+		}
+	})
+
+	t.Run("partial support keeps supported parts", func(t *testing.T) {
+		t.Parallel()
+
+		got := AdaptMessages(imageProvider{}, msgs)
+		want := []ContentPart{
+			TextPart("see"),
+			ImagePart("u", "image/png"),
+			TextPart("[voice message: v]"),
+		}
+		if !reflect.DeepEqual(got[1].Parts, want) {
+			t.Errorf("Parts = %+v, want %+v", got[1].Parts, want)
+		}
+	})
+}
@@ -1,6 +1,14 @@
 package provider
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Sentinel errors for provider operations.
 var (
@@ -25,3 +33,101 @@ var (
 func IsRetryable(err error) bool {
 	return errors.Is(err, ErrRateLimit) || errors.Is(err, ErrProviderDown)
 }
+
+// RateLimitScope identifies what a rate limit applies to.
+type RateLimitScope string
+
+// Rate limit scopes. A key-scoped limit is lifted by switching keys; org
+// and model limits hold for every key of the provider.
+const (
+	RateLimitScopeUnknown RateLimitScope = ""
+	RateLimitScopeKey     RateLimitScope = "key"
+	RateLimitScopeOrg     RateLimitScope = "org"
+	RateLimitScopeModel   RateLimitScope = "model"
+)
+
+// RateLimitError is a rate limit response with the details the provider
+// gave about it. It matches ErrRateLimit with errors.Is.
+type RateLimitError struct {
+	// RetryAfter is how long the provider asked to wait before retrying.
+	// Zero when it did not say.
+	RetryAfter time.Duration
+
+	// Remaining is the number of requests left in the current window, or
+	// -1 when unknown.
+	Remaining int
+
+	// Scope is what the limit applies to.
+	Scope RateLimitScope
+}
+
+func (e *RateLimitError) Error() string {
+	var b strings.Builder
+	b.WriteString(ErrRateLimit.Error())
+	if e.Scope != RateLimitScopeUnknown {
+		fmt.Fprintf(&b, " (%s)", e.Scope)
+	}
+	if e.RetryAfter > 0 {
+		fmt.Fprintf(&b, ", retry after %s", e.RetryAfter)
+	}
+	return b.String()
+}
+
+// Unwrap returns ErrRateLimit.
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimit
+}
+
+// RetryAfter returns how long the provider asked to wait before retrying
+// after err, or zero when err carries no RateLimitError or it gave no
+// delay.
+func RetryAfter(err error) time.Duration {
+	var rl *RateLimitError
+	if errors.As(err, &rl) {
+		return max(rl.RetryAfter, 0)
+	}
+	return 0
+}
+
+// rateLimitScope returns the scope of the rate limit in err, or
+// RateLimitScopeUnknown.
+func rateLimitScope(err error) RateLimitScope {
+	var rl *RateLimitError
+	if errors.As(err, &rl) {
+		return rl.Scope
+	}
+	return RateLimitScopeUnknown
+}
+
+// ParseRemaining parses a rate limit remaining-count header value. It
+// returns -1 for empty or invalid values.
+func ParseRemaining(value string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// maxRetryAfter caps the delays parsed by ParseRetryAfter.
+const maxRetryAfter = 24 * time.Hour
+
+// ParseRetryAfter parses the value of a Retry-After HTTP header, either a
+// number of seconds or an HTTP date, into a delay from now, capped at a
+// day. It returns zero for empty, invalid or past values.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		if secs <= 0 || math.IsNaN(secs) || math.IsInf(secs, 0) {
+			return 0
+		}
+		return time.Duration(min(secs, maxRetryAfter.Seconds()) * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return min(t.Sub(now), maxRetryAfter)
+	}
+	return 0
+}
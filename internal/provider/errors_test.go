@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestSentinelErrors(t *testing.T) {
@@ -75,3 +76,96 @@ func TestIsRetryable(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimitError(t *testing.T) {
+	t.Parallel()
+
+	err := fmt.Errorf("api: %w", &RateLimitError{RetryAfter: 30 * time.Second, Remaining: 0, Scope: RateLimitScopeOrg})
+	if !errors.Is(err, ErrRateLimit) || !IsRetryable(err) {
+		t.Errorf("errors.Is(%v, ErrRateLimit) = false, want true", err)
+	}
+	if got, want := err.Error(), "api: provider rate limited (org), retry after 30s"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if got := rateLimitScope(err); got != RateLimitScopeOrg {
+		t.Errorf("rateLimitScope() = %q, want org", got)
+	}
+	if got := rateLimitScope(ErrRateLimit); got != RateLimitScopeUnknown {
+		t.Errorf("rateLimitScope(ErrRateLimit) = %q, want unknown", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"nil", nil, 0},
+		{"sentinel", ErrRateLimit, 0},
+		{"delay", &RateLimitError{RetryAfter: 3 * time.Second}, 3 * time.Second},
+		{"wrapped delay", fmt.Errorf("api: %w", &RateLimitError{RetryAfter: time.Minute}), time.Minute},
+		{"negative delay", &RateLimitError{RetryAfter: -time.Second}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := RetryAfter(tt.err); got != tt.want {
+				t.Errorf("RetryAfter(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRemaining(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", -1},
+		{"0", 0},
+		{" 42 ", 42},
+		{"-1", -1},
+		{"many", -1},
+	}
+
+	for _, tt := range tests {
+		if got := ParseRemaining(tt.value); got != tt.want {
+			t.Errorf("ParseRemaining(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"7", 7 * time.Second},
+		{" 1.5 ", 1500 * time.Millisecond},
+		{"0", 0},
+		{"-3", 0},
+		{"soon", 0},
+		{"Wed, 01 Jan 2025 12:00:30 GMT", 30 * time.Second},
+		{"Wed, 01 Jan 2025 11:59:00 GMT", 0},
+		{"99999999999", maxRetryAfter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Parallel()
+			if got := ParseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"math"
 	"sync"
 	"time"
 )
@@ -12,6 +13,7 @@ const (
 	stateHealthy  healthState = iota
 	stateCooldown             // transient failure, backing off
 	stateDead                 // too many consecutive failures
+	stateHalfOpen             // dead, letting a share of live requests probe
 )
 
 // String returns a human-readable label for the health state.
@@ -23,6 +25,8 @@ func (s healthState) String() string {
 		return "cooldown"
 	case stateDead:
 		return "dead"
+	case stateHalfOpen:
+		return "half-open"
 	default:
 		return "unknown"
 	}
@@ -45,6 +49,16 @@ type HealthConfig struct {
 	// CheckInterval is how often the background goroutine probes
 	// dead/cooldown providers. Default: 10s.
 	CheckInterval time.Duration
+
+	// HalfOpenAfter is how long a dead provider stays out of rotation
+	// before live requests may probe it again (the half-open state). It
+	// doubles after each failed probe, up to the larger of itself and
+	// MaxBackoff. Default: 30s.
+	HalfOpenAfter time.Duration
+
+	// HalfOpenFraction is the share of requests let through to a
+	// half-open provider. Default: 0.1.
+	HalfOpenFraction float64
 }
 
 // checkIntervalOrDefault returns the configured CheckInterval, or the
@@ -70,11 +84,23 @@ func (c *HealthConfig) defaults() {
 	if c.CheckInterval <= 0 {
 		c.CheckInterval = 10 * time.Second
 	}
+	if c.HalfOpenAfter <= 0 {
+		c.HalfOpenAfter = 30 * time.Second
+	}
+	if c.HalfOpenFraction <= 0 || c.HalfOpenFraction > 1 {
+		c.HalfOpenFraction = 0.1
+	}
 }
 
 // healthTracker monitors the availability of a single provider.
 // It implements exponential backoff on failures and marks the
 // provider dead after MaxFailures consecutive failures.
+//
+// A dead provider is a tripped circuit breaker. It is revived by a
+// successful HealthCheck probe or, for providers without one, by live
+// traffic: once HalfOpenAfter has passed, the tracker turns half-open and
+// Admit lets a share of the requests through. A success closes the
+// circuit; a failure opens it again for twice as long.
 type healthTracker struct {
 	cfg HealthConfig
 
@@ -88,6 +114,10 @@ type healthTracker struct {
 	currentBackoff  time.Duration
 	cooldownExpires time.Time
 
+	deadBackoff  time.Duration // time out of rotation before half-open
+	halfOpenAt   time.Time     // when a dead provider turns half-open
+	halfOpenSeen int           // requests seen while half-open
+
 	// now is injectable for testing. Defaults to time.Now.
 	now func() time.Time
 }
@@ -118,6 +148,40 @@ func (h *healthTracker) IsAvailable() bool {
 	}
 }
 
+// Admit reports whether a request may be sent to the provider. It is
+// IsAvailable, except that a dead provider turns half-open once
+// HalfOpenAfter has passed and then admits a share of the requests:
+// the first one, then one every 1/HalfOpenFraction.
+func (h *healthTracker) Admit() bool {
+	h.mu.Lock()
+	prev := h.state
+	var ok bool
+	switch h.state {
+	case stateHealthy:
+		ok = true
+	case stateCooldown:
+		ok = !h.now().Before(h.cooldownExpires)
+	case stateDead:
+		if h.now().Before(h.halfOpenAt) {
+			break
+		}
+		h.state = stateHalfOpen
+		h.halfOpenSeen = 0
+		fallthrough
+	case stateHalfOpen:
+		every := max(1, int(math.Round(1/h.cfg.HalfOpenFraction)))
+		ok = h.halfOpenSeen%every == 0
+		h.halfOpenSeen++
+	}
+	state := h.state
+	h.mu.Unlock()
+
+	if prev != state && h.onStateChange != nil {
+		h.onStateChange(prev, state)
+	}
+	return ok
+}
+
 // RecordSuccess resets the tracker to the healthy state.
 func (h *healthTracker) RecordSuccess() {
 	h.mu.Lock()
@@ -125,6 +189,7 @@ func (h *healthTracker) RecordSuccess() {
 	h.state = stateHealthy
 	h.failures = 0
 	h.currentBackoff = 0
+	h.deadBackoff = 0
 	h.mu.Unlock()
 
 	if prev != stateHealthy && h.onStateChange != nil {
@@ -133,15 +198,24 @@ func (h *healthTracker) RecordSuccess() {
 }
 
 // RecordFailure records a failed request. It transitions the tracker
-// to cooldown (with exponential backoff) or dead after MaxFailures.
+// to cooldown (with exponential backoff) or dead after MaxFailures. A
+// failed half-open probe reopens the circuit for twice as long.
 func (h *healthTracker) RecordFailure() {
 	h.mu.Lock()
 	prev := h.state
 	h.failures++
 
 	var newState healthState
-	if h.failures >= h.cfg.MaxFailures {
+	if h.failures >= h.cfg.MaxFailures || prev == stateHalfOpen {
 		newState = stateDead
+		switch {
+		case prev == stateHalfOpen:
+			h.deadBackoff = min(h.deadBackoff*2, max(h.cfg.HalfOpenAfter, h.cfg.MaxBackoff))
+			h.halfOpenAt = h.now().Add(h.deadBackoff)
+		case prev != stateDead:
+			h.deadBackoff = h.cfg.HalfOpenAfter
+			h.halfOpenAt = h.now().Add(h.deadBackoff)
+		}
 	} else {
 		newState = stateCooldown
 		if h.currentBackoff == 0 {
@@ -162,14 +236,41 @@ func (h *healthTracker) RecordFailure() {
 	}
 }
 
+// RecordRateLimit records a rate limited request. When the provider said
+// how long to wait, the tracker cools down for exactly that long, never
+// shortening a longer cooldown already in place, and is not marked dead:
+// the provider told us when it will be back. Without a delay it behaves
+// like RecordFailure.
+func (h *healthTracker) RecordRateLimit(retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		h.RecordFailure()
+		return
+	}
+
+	h.mu.Lock()
+	prev := h.state
+	h.failures++
+	h.state = stateCooldown
+	h.currentBackoff = retryAfter
+	if until := h.now().Add(retryAfter); prev != stateCooldown || until.After(h.cooldownExpires) {
+		h.cooldownExpires = until
+	}
+	h.mu.Unlock()
+
+	if prev != stateCooldown && h.onStateChange != nil {
+		h.onStateChange(prev, stateCooldown)
+	}
+}
+
 // ShouldHealthCheck reports whether the provider needs an active
-// health probe. This is true for dead and cooldown-expired providers.
+// health probe. This is true for dead, half-open and cooldown-expired
+// providers.
 func (h *healthTracker) ShouldHealthCheck() bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	switch h.state {
-	case stateDead:
+	case stateDead, stateHalfOpen:
 		return true
 	case stateCooldown:
 		return !h.now().Before(h.cooldownExpires)
@@ -198,3 +299,22 @@ func (h *healthTracker) CurrentBackoff() time.Duration {
 	defer h.mu.Unlock()
 	return h.currentBackoff
 }
+
+// snapshot returns the state, consecutive failures and time out of
+// rotation of the provider, read together: the backoff and end of the
+// cooldown, or of the wait before half-open when dead.
+func (h *healthTracker) snapshot() (state healthState, failures int, backoff time.Duration, until time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch h.state {
+	case stateCooldown:
+		return h.state, h.failures, h.currentBackoff, h.cooldownExpires
+	case stateDead:
+		return h.state, h.failures, h.deadBackoff, h.halfOpenAt
+	case stateHalfOpen:
+		return h.state, h.failures, h.deadBackoff, time.Time{}
+	default:
+		return h.state, h.failures, 0, time.Time{}
+	}
+}
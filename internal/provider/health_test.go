@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -178,6 +179,67 @@ func TestHealthTracker_RecoveryFromDead(t *testing.T) {
 	}
 }
 
+func TestHealthTracker_HalfOpenAdmitsFraction(t *testing.T) {
+	t.Parallel()
+	h, ft := newTestTracker(HealthConfig{MaxFailures: 1, HalfOpenAfter: 10 * time.Second, HalfOpenFraction: 0.25})
+
+	h.RecordFailure()
+	if h.Admit() {
+		t.Fatal("dead provider admitted before HalfOpenAfter")
+	}
+
+	ft.Advance(10 * time.Second)
+	var got []bool
+	for range 8 {
+		got = append(got, h.Admit())
+	}
+	want := []bool{true, false, false, false, true, false, false, false}
+	if !slices.Equal(got, want) {
+		t.Errorf("admitted = %v, want %v", got, want)
+	}
+	if h.State() != stateHalfOpen {
+		t.Errorf("state = %v, want half-open", h.State())
+	}
+	if h.IsAvailable() {
+		t.Error("half-open provider reported available")
+	}
+	if !h.ShouldHealthCheck() {
+		t.Error("ShouldHealthCheck() = false for half-open provider")
+	}
+
+	h.RecordSuccess()
+	if h.State() != stateHealthy || !h.Admit() {
+		t.Errorf("state = %v after successful probe, want healthy", h.State())
+	}
+}
+
+func TestHealthTracker_HalfOpenFailureReopens(t *testing.T) {
+	t.Parallel()
+	h, ft := newTestTracker(HealthConfig{
+		MaxFailures:      1,
+		MaxBackoff:       30 * time.Second,
+		HalfOpenAfter:    10 * time.Second,
+		HalfOpenFraction: 1,
+	})
+
+	// Each failed probe doubles the time out of rotation, up to MaxBackoff.
+	h.RecordFailure()
+	for _, wait := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+		ft.Advance(wait - time.Second)
+		if h.Admit() {
+			t.Errorf("admitted before %v out of rotation", wait)
+		}
+		ft.Advance(time.Second)
+		if !h.Admit() {
+			t.Fatalf("not half-open after %v", wait)
+		}
+		h.RecordFailure()
+		if h.State() != stateDead {
+			t.Fatalf("state = %v after failed probe, want dead", h.State())
+		}
+	}
+}
+
 func TestHealthTracker_SuccessResetsBackoff(t *testing.T) {
 	t.Parallel()
 	h, ft := newTestTracker(HealthConfig{
@@ -206,6 +268,48 @@ func TestHealthTracker_SuccessResetsBackoff(t *testing.T) {
 	}
 }
 
+func TestHealthTracker_RateLimitUsesRetryAfter(t *testing.T) {
+	t.Parallel()
+	h, ft := newTestTracker(HealthConfig{InitialBackoff: time.Second, MaxFailures: 2})
+
+	h.RecordRateLimit(90 * time.Second)
+	if h.State() != stateCooldown {
+		t.Fatalf("state = %v, want cooldown", h.State())
+	}
+	ft.Advance(89 * time.Second)
+	if h.IsAvailable() {
+		t.Error("available before Retry-After elapsed")
+	}
+
+	// A shorter delay does not shorten the cooldown, and rate limits with
+	// a known delay never mark the provider dead.
+	h.RecordRateLimit(time.Second)
+	if h.State() != stateCooldown {
+		t.Errorf("state = %v, want cooldown", h.State())
+	}
+	if h.IsAvailable() {
+		t.Error("cooldown shortened by a shorter Retry-After")
+	}
+	ft.Advance(time.Second)
+	if !h.IsAvailable() {
+		t.Error("not available once Retry-After elapsed")
+	}
+}
+
+func TestHealthTracker_RateLimitWithoutRetryAfter(t *testing.T) {
+	t.Parallel()
+	h, ft := newTestTracker(HealthConfig{InitialBackoff: 2 * time.Second})
+
+	h.RecordRateLimit(0)
+	if h.CurrentBackoff() != 2*time.Second {
+		t.Errorf("backoff = %v, want InitialBackoff", h.CurrentBackoff())
+	}
+	ft.Advance(2 * time.Second)
+	if !h.IsAvailable() {
+		t.Error("not available after backoff")
+	}
+}
+
 func TestHealthTracker_ShouldHealthCheck(t *testing.T) {
 	t.Parallel()
 	h, ft := newTestTracker(HealthConfig{MaxFailures: 3})
@@ -435,6 +539,7 @@ func TestHealthState_String(t *testing.T) {
 		{stateHealthy, "healthy"},
 		{stateCooldown, "cooldown"},
 		{stateDead, "dead"},
+		{stateHalfOpen, "half-open"},
 		{healthState(99), "unknown"},
 	}
 
@@ -0,0 +1,344 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithHedging enables hedged requests for role: when the provider
+// handling a request has not answered within delay, the request is also
+// sent to the next candidate. The first successful response wins and the
+// other calls are cancelled. A zero delay disables hedging.
+//
+// Streams are hedged until their first chunk: the first provider to
+// deliver one serves the whole stream. Failing over mid-stream is not
+// hedged.
+//
+// Hedging trades cost for latency: a hedged request may be billed by two
+// providers. It suits interactive roles where a slow provider is as bad
+// as a dead one.
+func WithHedging(role Role, delay time.Duration) ChainOption {
+	return func(c *Chain) {
+		if c.hedges == nil {
+			c.hedges = make(map[Role]time.Duration)
+		}
+		c.hedges[role] = delay
+	}
+}
+
+// hedgeResult is the outcome of one call of a hedged request.
+type hedgeResult struct {
+	entry    *chainEntry
+	role     Role
+	keyIndex int
+	resp     CompletionResponse
+	err      error
+	elapsed  time.Duration
+}
+
+// hedgedComplete implements Complete for a role with hedging. Candidates
+// are started in order: the next one when the delay expires without a
+// response, or right away when a call fails with a retryable error. Calls
+// still running once the request is decided are cancelled, and their
+// outcome is recorded in health when they return.
+func (pc *Chain) hedgedComplete(ctx context.Context, role Role, req CompletionRequest, candidates []*chainEntry, delay time.Duration) (CompletionResponse, *chainEntry, error) {
+	hedgeCtx, cancel := context.WithCancel(ctx)
+
+	results := make(chan hedgeResult, len(candidates))
+	pending := 0
+	next := 0
+
+	// launch starts a call on the next available candidate. It returns
+	// false when none is left.
+	launch := func() bool {
+		for next < len(candidates) {
+			e := candidates[next]
+			next++
+			callCtx, keyIndex, ok := pc.prepare(hedgeCtx, e)
+			if !ok {
+				continue
+			}
+			pending++
+			go func() {
+				start := time.Now()
+				resp, err := e.Provider.Complete(callCtx, req)
+				results <- hedgeResult{entry: e, role: role, keyIndex: keyIndex, resp: resp, err: err, elapsed: time.Since(start)}
+			}()
+			return true
+		}
+		return false
+	}
+
+	// finish cancels the calls still running and records their outcome
+	// in the background.
+	finish := func() {
+		cancel()
+		if pending == 0 {
+			return
+		}
+		go func(n int) {
+			for range n {
+				pc.recordHedgeOutcome(<-results)
+			}
+		}(pending)
+	}
+
+	if !launch() {
+		cancel()
+		return CompletionResponse{}, nil, pc.exhausted(role, nil)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			pc.metrics.call(r.entry, role, r.err)
+			if r.err == nil {
+				finish()
+				r.entry.observeLatency(r.elapsed)
+				pc.metrics.latency(r.entry, role, r.elapsed)
+				pc.metrics.usage(r.entry, role, r.resp.Usage)
+				pc.recordSuccess(r.entry, r.keyIndex)
+				return r.resp, r.entry, nil
+			}
+
+			lastErr = r.err
+			r.entry.stats.failed(r.err)
+			if !IsRetryable(r.err) {
+				finish()
+				return CompletionResponse{}, nil, r.err
+			}
+			pc.recordFailure(r.entry, r.keyIndex, r.err)
+			pc.logger.Warn("provider failed, failing over",
+				"provider", r.entry.Name,
+				"error", r.err,
+			)
+			if launch() {
+				timer.Reset(delay)
+			}
+
+		case <-timer.C:
+			if launch() {
+				pc.logger.Info("provider slow, hedging request",
+					"provider", candidates[next-1].Name,
+					"role", role,
+					"delay", delay,
+				)
+				timer.Reset(delay)
+			}
+
+		case <-ctx.Done():
+			finish()
+			return CompletionResponse{}, nil, ctx.Err()
+		}
+	}
+
+	cancel()
+	return CompletionResponse{}, nil, pc.exhausted(role, lastErr)
+}
+
+// recordHedgeOutcome records the outcome of a call that returned after
+// its hedged request was decided. Calls that ended because they were
+// cancelled say nothing about the provider and are not recorded.
+func (pc *Chain) recordHedgeOutcome(r hedgeResult) {
+	if r.err != nil {
+		r.entry.stats.failed(r.err)
+	}
+	pc.metrics.call(r.entry, r.role, r.err)
+	switch {
+	case r.err == nil:
+		r.entry.observeLatency(r.elapsed)
+		pc.metrics.latency(r.entry, r.role, r.elapsed)
+		pc.metrics.usage(r.entry, r.role, r.resp.Usage)
+		pc.recordSuccess(r.entry, r.keyIndex)
+	case errors.Is(r.err, context.Canceled):
+	case IsRetryable(r.err):
+		pc.recordFailure(r.entry, r.keyIndex, r.err)
+	}
+}
+
+// streamStart is the beginning of one stream of a hedged request: the
+// stream and its first chunk, or the error opening it.
+type streamStart struct {
+	entry    *chainEntry
+	keyIndex int
+	pos      int // position in the candidates
+	ch       <-chan StreamChunk
+	first    StreamChunk
+	ok       bool // first was received: false when ch closed empty
+	err      error
+	elapsed  time.Duration
+}
+
+// failure returns the retryable error that ended the stream before it
+// delivered anything useful, the error opening it, or nil.
+func (s streamStart) failure() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.ok && s.first.Err != nil && IsRetryable(s.first.Err) {
+		return s.first.Err
+	}
+	return nil
+}
+
+// hedgedOpenStream implements openStream for a role with hedging. A
+// stream is started on the next candidate when the delay expires before
+// the current ones deliver their first chunk, or right away when one
+// fails with a retryable error. The first stream to deliver a chunk wins
+// and is returned with that chunk replayed; the others are cancelled and
+// drained. It returns the same values as openStream, the position being
+// that of the last candidate started.
+func (pc *Chain) hedgedOpenStream(ctx context.Context, role Role, req CompletionRequest, candidates []*chainEntry, delay time.Duration) (<-chan StreamChunk, *chainEntry, int, int, error) {
+	starts := make(chan streamStart, len(candidates))
+	cancels := make([]context.CancelFunc, len(candidates))
+	pending := 0
+	next := 0
+
+	// launch starts a stream on the next available candidate. It returns
+	// false when none is left.
+	launch := func() bool {
+		for next < len(candidates) {
+			pos := next
+			e := candidates[pos]
+			next++
+			callCtx, keyIndex, ok := pc.prepare(ctx, e)
+			if !ok {
+				continue
+			}
+			callCtx, cancels[pos] = context.WithCancel(callCtx)
+			pending++
+			go func() {
+				s := streamStart{entry: e, keyIndex: keyIndex, pos: pos}
+				start := time.Now()
+				s.ch, s.err = e.Provider.Stream(callCtx, req)
+				if s.err == nil {
+					s.first, s.ok = <-s.ch
+				}
+				s.elapsed = time.Since(start)
+				starts <- s
+			}()
+			return true
+		}
+		return false
+	}
+
+	// finish cancels the streams other than the winner at position won,
+	// or all when won is negative, and drains and records them in the
+	// background.
+	finish := func(won int) {
+		for pos, cancel := range cancels {
+			if cancel != nil && pos != won {
+				cancel()
+			}
+		}
+		if pending == 0 {
+			return
+		}
+		go func(n int) {
+			for range n {
+				pc.discardStream(<-starts, role)
+			}
+		}(pending)
+	}
+
+	if !launch() {
+		return nil, nil, -1, 0, pc.exhausted(role, nil)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case s := <-starts:
+			pending--
+			err := s.failure()
+			if err == nil {
+				finish(s.pos)
+				s.entry.observeLatency(s.elapsed)
+				pc.metrics.latency(s.entry, role, s.elapsed)
+				return replayStream(s, cancels[s.pos]), s.entry, s.keyIndex, next - 1, nil
+			}
+
+			cancels[s.pos]()
+			if s.err == nil {
+				go drainStream(s.ch)
+			}
+			pc.metrics.call(s.entry, role, err)
+			lastErr = err
+			s.entry.stats.failed(err)
+			if !IsRetryable(err) {
+				finish(-1)
+				return nil, nil, -1, 0, err
+			}
+			pc.recordFailure(s.entry, s.keyIndex, err)
+			pc.logger.Warn("provider failed, failing over",
+				"provider", s.entry.Name,
+				"error", err,
+			)
+			if launch() {
+				timer.Reset(delay)
+			}
+
+		case <-timer.C:
+			if launch() {
+				pc.logger.Info("provider slow, hedging stream",
+					"provider", candidates[next-1].Name,
+					"role", role,
+					"delay", delay,
+				)
+				timer.Reset(delay)
+			}
+
+		case <-ctx.Done():
+			finish(-1)
+			return nil, nil, -1, 0, ctx.Err()
+		}
+	}
+
+	return nil, nil, -1, 0, pc.exhausted(role, lastErr)
+}
+
+// discardStream drains a stream that lost its hedged request and records
+// its outcome. A stream that was still going counts as cancelled.
+func (pc *Chain) discardStream(s streamStart, role Role) {
+	if s.err == nil {
+		drainStream(s.ch)
+	}
+	err := s.failure()
+	if err == nil {
+		err = context.Canceled
+	}
+	pc.recordHedgeOutcome(hedgeResult{entry: s.entry, role: role, keyIndex: s.keyIndex, err: err})
+}
+
+// replayStream returns the stream of s with its first chunk put back in
+// front. cancel releases the call once the stream ends.
+func replayStream(s streamStart, cancel context.CancelFunc) <-chan StreamChunk {
+	out := make(chan StreamChunk, cap(s.ch))
+	go func() {
+		defer close(out)
+		defer cancel()
+		if s.ok {
+			out <- s.first
+		}
+		for chunk := range s.ch {
+			out <- chunk
+		}
+	}()
+	return out
+}
+
+// drainStream discards the chunks of ch until it is closed.
+func drainStream(ch <-chan StreamChunk) {
+	//nolint:revive // intentional empty drain loop
+	for range ch { //nolint:revive
+	}
+}
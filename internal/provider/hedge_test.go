@@ -0,0 +1,263 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+)
+
+// hangingProvider never answers: its calls fail with the context error
+// once cancelled, closing cancelled.
+func hangingProvider(name string, cancelled chan<- struct{}) *providertest.MockProvider {
+	p := okProvider(name)
+	p.CompleteFunc = func(ctx context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return provider.CompletionResponse{}, ctx.Err()
+	}
+	return p
+}
+
+func TestHedging_SlowPrimary(t *testing.T) {
+	t.Parallel()
+
+	cancelled := make(chan struct{})
+	backupCalled := false
+	backup := okProvider("backup")
+	backup.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		backupCalled = true
+		return provider.CompletionResponse{Content: "backup"}, nil
+	}
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "slow", Provider: hangingProvider("slow", cancelled), Role: provider.RolePrimary},
+		{Name: "backup", Provider: backup, Role: provider.RoleFallback},
+	}, provider.WithHedging(provider.RolePrimary, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "backup" || !backupCalled {
+		t.Errorf("Content = %q, want backup", resp.Content)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("losing call not cancelled")
+	}
+
+	// Being slow is not a failure: the primary stays healthy.
+	p, err := chain.GetProvider(provider.RolePrimary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ModelName() != "slow" {
+		t.Errorf("GetProvider() = %q, want slow still healthy", p.ModelName())
+	}
+}
+
+func TestHedging_FastPrimary(t *testing.T) {
+	t.Parallel()
+
+	backup := okProvider("backup")
+	backup.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		t.Error("backup called although the primary answered within the delay")
+		return provider.CompletionResponse{}, nil
+	}
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "fast", Provider: okProvider("fast"), Role: provider.RolePrimary},
+		{Name: "backup", Provider: backup, Role: provider.RoleFallback},
+	}, provider.WithHedging(provider.RolePrimary, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "fast" {
+		t.Errorf("Content = %q, want fast", resp.Content)
+	}
+}
+
+func TestHedging_FailureStartsNextImmediately(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "down", Provider: failProvider(provider.ErrProviderDown), Role: provider.RolePrimary},
+		{Name: "backup", Provider: okProvider("backup"), Role: provider.RoleFallback},
+	}, provider.WithHedging(provider.RolePrimary, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := chain.Complete(ctx, provider.RolePrimary, provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "backup" {
+		t.Errorf("Content = %q, want backup", resp.Content)
+	}
+}
+
+func TestHedging_RecordsLoserFailure(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	flaky := okProvider("flaky")
+	flaky.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		<-release // ignores cancellation
+		return provider.CompletionResponse{}, provider.ErrProviderDown
+	}
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "flaky", Provider: flaky, Role: provider.RolePrimary, Health: provider.HealthConfig{InitialBackoff: time.Hour}},
+		{Name: "backup", Provider: okProvider("backup"), Role: provider.RoleFallback},
+	}, provider.WithHedging(provider.RolePrimary, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		p, err := chain.GetProvider(provider.RolePrimary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ModelName() == "backup" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("failure of the losing call not recorded in health")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHedging_NonRetryableStops(t *testing.T) {
+	t.Parallel()
+
+	errBad := errors.New("bad request")
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "bad", Provider: failProvider(errBad), Role: provider.RolePrimary},
+		{Name: "backup", Provider: okProvider("backup"), Role: provider.RoleFallback},
+	}, provider.WithHedging(provider.RolePrimary, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{}); !errors.Is(err, errBad) {
+		t.Errorf("Complete() error = %v, want %v", err, errBad)
+	}
+}
+
+func TestHedging_AllFail(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "a", Provider: failProvider(provider.ErrProviderDown), Role: provider.RolePrimary},
+		{Name: "b", Provider: failProvider(provider.ErrRateLimit), Role: provider.RoleFallback},
+	}, provider.WithHedging(provider.RolePrimary, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+	if !errors.Is(err, provider.ErrAllProviders) {
+		t.Errorf("Complete() error = %v, want ErrAllProviders", err)
+	}
+}
+
+// collect drains a stream, failing on chunk errors, and returns its text.
+func collect(t *testing.T, ch <-chan provider.StreamChunk) string {
+	t.Helper()
+	var content string
+	for c := range ch {
+		if c.Err != nil {
+			t.Fatalf("stream error: %v", c.Err)
+		}
+		content += c.Content
+	}
+	return content
+}
+
+func TestHedging_StreamSlowFirstChunk(t *testing.T) {
+	t.Parallel()
+
+	cancelled := make(chan struct{})
+	slow := okProvider("slow")
+	slow.StreamFunc = func(ctx context.Context, _ provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+		ch := make(chan provider.StreamChunk)
+		go func() {
+			defer close(ch)
+			<-ctx.Done()
+			close(cancelled)
+		}()
+		return ch, nil
+	}
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "slow", Provider: slow, Role: provider.RolePrimary},
+		{Name: "backup", Provider: chunkProvider("backup", provider.StreamChunk{Content: "back"}, provider.StreamChunk{Content: "up"}), Role: provider.RoleFallback},
+	}, provider.WithHedging(provider.RolePrimary, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := chain.Stream(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(t, ch); got != "backup" {
+		t.Errorf("content = %q, want backup", got)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("losing stream not cancelled")
+	}
+	p, err := chain.GetProvider(provider.RolePrimary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ModelName() != "slow" {
+		t.Errorf("GetProvider() = %q, want slow still healthy", p.ModelName())
+	}
+}
+
+func TestHedging_StreamFirstChunkFailure(t *testing.T) {
+	t.Parallel()
+
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "flaky", Provider: chunkProvider("flaky", provider.StreamChunk{Err: provider.ErrProviderDown}), Role: provider.RolePrimary},
+		{Name: "backup", Provider: okProvider("backup"), Role: provider.RoleFallback},
+	}, provider.WithHedging(provider.RolePrimary, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ch, err := chain.Stream(ctx, provider.RolePrimary, provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(t, ch); got != "backup" {
+		t.Errorf("content = %q, want backup", got)
+	}
+}
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxErrorBody bounds how much of an error response ReadErrorBody reads.
+const MaxErrorBody = 64 << 10
+
+// errRequestTimeout is the cause of the contexts cut by
+// WithRequestTimeout.
+var errRequestTimeout = errors.New("provider request timed out")
+
+// WithRequestTimeout returns a copy of ctx that expires after timeout,
+// the provider's own bound on a request. Unlike a deadline of the caller,
+// TransportError reports its expiry as the provider being down.
+func WithRequestTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, timeout, errRequestTimeout)
+}
+
+// TransportError classifies a failure of the provider name to talk to its
+// API with ctx. Cancellation and deadlines of the caller are returned
+// as-is; anything else, including the expiry of WithRequestTimeout, wraps
+// ErrProviderDown.
+func TransportError(ctx context.Context, name string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(context.Cause(ctx), errRequestTimeout) {
+		return ctxErr
+	}
+	return fmt.Errorf("%w: %s: %w", ErrProviderDown, name, err)
+}
+
+// ReadErrorBody reads the body of an unsuccessful response, up to
+// MaxErrorBody bytes. It also returns the message to report when the body
+// holds no error object: the body as text, or the status text when empty.
+func ReadErrorBody(resp *http.Response) (data []byte, text string) {
+	data, _ = io.ReadAll(io.LimitReader(resp.Body, MaxErrorBody))
+	text = strings.TrimSpace(string(data))
+	if text == "" {
+		text = http.StatusText(resp.StatusCode)
+	}
+	return data, text
+}
+
+// RateLimitHeaders completes the RateLimitError in err, if any, with the
+// delay of the Retry-After header of h and, when remaining is not empty,
+// the count in the header named remaining. It returns the RateLimitError,
+// or nil when err has none.
+func RateLimitHeaders(err error, h http.Header, remaining string) *RateLimitError {
+	var rl *RateLimitError
+	if !errors.As(err, &rl) {
+		return nil
+	}
+	rl.RetryAfter = ParseRetryAfter(h.Get("Retry-After"), time.Now())
+	if remaining != "" {
+		rl.Remaining = ParseRemaining(h.Get(remaining))
+	}
+	return rl
+}
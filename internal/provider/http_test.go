@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransportError(t *testing.T) {
+	t.Parallel()
+
+	errConn := errors.New("connection refused")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timedOut, cancelTimedOut := WithRequestTimeout(context.Background(), time.Millisecond)
+	t.Cleanup(cancelTimedOut)
+	<-timedOut.Done()
+	caller, cancelCaller := context.WithTimeout(context.Background(), time.Millisecond)
+	t.Cleanup(cancelCaller)
+	<-caller.Done()
+	callerDeadline, cancelDeadline := WithRequestTimeout(caller, time.Minute)
+	t.Cleanup(cancelDeadline)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		want     error
+		wantDown bool
+	}{
+		{"live context", context.Background(), errConn, true},
+		{"caller cancelled", cancelled, context.Canceled, false},
+		{"caller deadline", callerDeadline, context.DeadlineExceeded, false},
+		{"request timeout", timedOut, errConn, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := TransportError(tt.ctx, "test", errConn)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("TransportError() = %v, want %v", err, tt.want)
+			}
+			if errors.Is(err, ErrProviderDown) != tt.wantDown {
+				t.Errorf("TransportError() = %v, want ErrProviderDown: %v", err, tt.wantDown)
+			}
+		})
+	}
+}
+
+func TestReadErrorBody(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantData string
+		wantText string
+	}{
+		{"text", " overloaded\n", " overloaded\n", "overloaded"},
+		{"empty", "", "", "Service Unavailable"},
+		{"bounded", strings.Repeat("x", MaxErrorBody+1), strings.Repeat("x", MaxErrorBody), strings.Repeat("x", MaxErrorBody)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			resp := &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			data, text := ReadErrorBody(resp)
+			if string(data) != tt.wantData || text != tt.wantText {
+				t.Errorf("ReadErrorBody() = %d bytes, %q, want %d bytes, %q", len(data), text, len(tt.wantData), tt.wantText)
+			}
+		})
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	t.Parallel()
+
+	h := http.Header{}
+	h.Set("Retry-After", "7")
+	h.Set("X-Remaining", "3")
+
+	if rl := RateLimitHeaders(fmt.Errorf("api: %w", ErrProviderDown), h, "X-Remaining"); rl != nil {
+		t.Errorf("RateLimitHeaders(no rate limit) = %v, want nil", rl)
+	}
+
+	rl := RateLimitHeaders(fmt.Errorf("api: %w", &RateLimitError{Remaining: -1}), h, "X-Remaining")
+	if rl == nil || rl.RetryAfter != 7*time.Second || rl.Remaining != 3 {
+		t.Errorf("RateLimitHeaders() = %+v, want 7s and 3 remaining", rl)
+	}
+
+	rl = RateLimitHeaders(&RateLimitError{Remaining: -1}, h, "")
+	if rl == nil || rl.RetryAfter != 7*time.Second || rl.Remaining != -1 {
+		t.Errorf("RateLimitHeaders(no remaining header) = %+v, want 7s and unknown remaining", rl)
+	}
+}
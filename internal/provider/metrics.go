@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/metrics"
+)
+
+// WithMetrics records the calls of the chain in reg: every call to a
+// provider by outcome, the response time of successful ones and the
+// tokens they used, labelled with the provider and the requested role.
+func WithMetrics(reg *metrics.Registry) ChainOption {
+	return func(c *Chain) { c.metrics = newChainMetrics(reg) }
+}
+
+// Call outcomes, the outcome label of sclaw_provider_calls.
+const (
+	outcomeSuccess     = "success"
+	outcomeError       = "error"
+	outcomeRateLimited = "rate_limited"
+	outcomeCanceled    = "canceled"
+)
+
+// chainMetrics holds the metrics of a chain. The zero value discards
+// observations.
+type chainMetrics struct {
+	calls    *metrics.Counter
+	duration *metrics.Histogram
+	tokens   *metrics.Counter
+}
+
+func newChainMetrics(reg *metrics.Registry) chainMetrics {
+	return chainMetrics{
+		calls: reg.Counter("sclaw_provider_calls",
+			"Provider calls by provider, requested role and outcome.",
+			"provider", "role", "outcome"),
+		duration: reg.Histogram("sclaw_provider_call_duration_seconds",
+			"Response time of successful provider calls, until the stream opens for streamed calls.",
+			metrics.DurationBuckets, "provider", "role"),
+		tokens: reg.Counter("sclaw_provider_tokens",
+			"Tokens used by provider calls, by provider, requested role and type (prompt or completion).",
+			"provider", "role", "type"),
+	}
+}
+
+// call counts a call to e for role that ended with err.
+func (m chainMetrics) call(e *chainEntry, role Role, err error) {
+	m.calls.Inc(e.Name, string(role), callOutcome(err))
+}
+
+// latency records the response time of a successful call to e for role.
+func (m chainMetrics) latency(e *chainEntry, role Role, d time.Duration) {
+	m.duration.Observe(d.Seconds(), e.Name, string(role))
+}
+
+// usage records the tokens used by a call to e for role.
+func (m chainMetrics) usage(e *chainEntry, role Role, u TokenUsage) {
+	m.tokens.Add(float64(u.PromptTokens), e.Name, string(role), "prompt")
+	m.tokens.Add(float64(u.CompletionTokens), e.Name, string(role), "completion")
+}
+
+// callOutcome classifies the result of a provider call.
+func callOutcome(err error) string {
+	switch {
+	case err == nil:
+		return outcomeSuccess
+	case errors.Is(err, context.Canceled):
+		return outcomeCanceled
+	case IsRateLimit(err):
+		return outcomeRateLimited
+	default:
+		return outcomeError
+	}
+}
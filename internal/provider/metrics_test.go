@@ -0,0 +1,72 @@
+package provider_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/metrics"
+	"github.com/flemzord/sclaw/internal/metrics/metricstest"
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+func TestMetrics_Complete(t *testing.T) {
+	t.Parallel()
+
+	backup := okProvider("backup")
+	backup.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		return provider.CompletionResponse{
+			Content: "backup",
+			Usage:   provider.TokenUsage{PromptTokens: 10, CompletionTokens: 3, TotalTokens: 13},
+		}, nil
+	}
+	reg := metrics.NewRegistry()
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "limited", Provider: failProvider(provider.ErrRateLimit), Role: provider.RolePrimary},
+		{Name: "down", Provider: failProvider(provider.ErrProviderDown), Role: provider.RoleFallback},
+		{Name: "backup", Provider: backup, Role: provider.RoleFallback},
+	}, provider.WithMetrics(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	metricstest.AssertLines(t, reg,
+		`sclaw_provider_calls_total{provider="backup",role="primary",outcome="success"} 1`,
+		`sclaw_provider_calls_total{provider="down",role="primary",outcome="error"} 1`,
+		`sclaw_provider_calls_total{provider="limited",role="primary",outcome="rate_limited"} 1`,
+		`sclaw_provider_call_duration_seconds_count{provider="backup",role="primary"} 1`,
+		`sclaw_provider_tokens_total{provider="backup",role="primary",type="completion"} 3`,
+		`sclaw_provider_tokens_total{provider="backup",role="primary",type="prompt"} 10`,
+	)
+}
+
+func TestMetrics_Stream(t *testing.T) {
+	t.Parallel()
+
+	usage := &provider.TokenUsage{PromptTokens: 7, CompletionTokens: 2}
+	reg := metrics.NewRegistry()
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "flaky", Provider: chunkProvider("flaky", provider.StreamChunk{Err: provider.ErrProviderDown}), Role: provider.RolePrimary},
+		{Name: "backup", Provider: chunkProvider("backup", provider.StreamChunk{Content: "hi"}, provider.StreamChunk{Usage: usage}), Role: provider.RoleFallback},
+	}, provider.WithMetrics(reg), provider.WithStreamFailover(provider.StreamFailoverBeforeOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := chain.Stream(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//nolint:revive // intentional empty drain loop
+	for range ch { //nolint:revive
+	}
+
+	metricstest.AssertLines(t, reg,
+		`sclaw_provider_calls_total{provider="backup",role="primary",outcome="success"} 1`,
+		`sclaw_provider_calls_total{provider="flaky",role="primary",outcome="error"} 1`,
+		`sclaw_provider_tokens_total{provider="backup",role="primary",type="prompt"} 7`,
+	)
+}
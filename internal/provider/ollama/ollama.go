@@ -0,0 +1,409 @@
+// Package ollama provides a provider module for a local or remote Ollama
+// server, so that a chain can fall back to a model running offline.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	core.RegisterModule(&Provider{})
+}
+
+// ModuleID is the identifier of the Ollama provider module.
+const ModuleID = "provider.ollama"
+
+// Defaults applied by Provision.
+const (
+	DefaultBaseURL = "http://localhost:11434"
+	DefaultTimeout = 10 * time.Minute
+)
+
+// showTimeout bounds the model metadata request made to discover the
+// context window.
+const showTimeout = 5 * time.Second
+
+// showRetryInterval is how long the context window stays unknown after
+// the model metadata could not be read, before it is requested again.
+const showRetryInterval = time.Minute
+
+// maxLineSize bounds one line of a streamed response.
+const maxLineSize = 4 << 20
+
+// Provider is a provider.Provider speaking the Ollama chat API.
+type Provider struct {
+	// BaseURL is the server root, without the /api suffix.
+	// Default: DefaultBaseURL.
+	BaseURL string `yaml:"base_url"`
+
+	// Model is the model requested from the server. It must already be
+	// pulled: the provider never pulls models.
+	Model string `yaml:"model"`
+
+	// APIKeys are optional keys sent as bearer tokens, for servers behind
+	// an authenticating proxy.
+	APIKeys []string `yaml:"api_keys"`
+
+	// ContextWindow is the context size requested from the server, in
+	// tokens. When unset, it is read from the model metadata: the num_ctx
+	// parameter of the model, else its trained context length. Large
+	// windows use a lot of memory; set it to bound memory use.
+	ContextWindow int `yaml:"context_window"`
+
+	// KeepAlive controls how long the server keeps the model loaded after
+	// a request, e.g. "10m". Empty uses the server default.
+	KeepAlive string `yaml:"keep_alive"`
+
+	// Timeout bounds a whole request, including reading a streamed
+	// response and loading the model. Default: DefaultTimeout.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Role is the role of the provider in the chain. Default: primary.
+	Role provider.Role `yaml:"role"`
+
+	// FallbackFor restricts a fallback provider to the listed roles.
+	// Empty means all roles.
+	FallbackFor []provider.Role `yaml:"fallback_for"`
+
+	// Weight is the share of traffic of the provider among those of its
+	// role under the weighted strategy. Default: 1.
+	Weight int `yaml:"weight"`
+
+	// Cost is the relative cost of the provider, such as its price per
+	// million tokens, for the least_cost strategy.
+	Cost float64 `yaml:"cost"`
+
+	auth   *provider.AuthProfile
+	client *http.Client
+	logger *slog.Logger
+
+	// window caches the context window read from the model metadata;
+	// windowRetry delays the next read after a failed one.
+	windowMu    sync.Mutex
+	window      int
+	windowRetry time.Time
+
+	// callSeq numbers tool calls: the API does not identify them.
+	callSeq atomic.Uint64
+}
+
+// New creates a provider for model served at baseURL, authenticated with
+// the keys of auth, which may be nil. It is ready to use without the
+// module lifecycle.
+func New(baseURL, model string, auth *provider.AuthProfile) *Provider {
+	p := &Provider{BaseURL: baseURL, Model: model, auth: auth}
+	p.defaults()
+	return p
+}
+
+// ModuleInfo implements core.Module.
+func (*Provider) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{
+		ID:       ModuleID,
+		New:      func() core.Module { return &Provider{} },
+		Optional: true,
+	}
+}
+
+// Configure implements core.Configurable.
+func (p *Provider) Configure(node *yaml.Node) error {
+	return node.Decode(p)
+}
+
+// Provision implements core.Provisioner.
+func (p *Provider) Provision(ctx *core.AppContext) error {
+	if len(p.APIKeys) > 0 {
+		auth, err := provider.NewAuthProfile(p.APIKeys...)
+		if err != nil {
+			return fmt.Errorf("ollama: %w", err)
+		}
+		p.auth = auth
+	}
+	p.logger = ctx.Logger
+	p.defaults()
+	return nil
+}
+
+func (p *Provider) defaults() {
+	if p.BaseURL == "" {
+		p.BaseURL = DefaultBaseURL
+	}
+	p.BaseURL = strings.TrimSuffix(p.BaseURL, "/")
+	if p.Timeout <= 0 {
+		p.Timeout = DefaultTimeout
+	}
+	if p.client == nil {
+		p.client = &http.Client{}
+	}
+	if p.logger == nil {
+		p.logger = slog.New(slog.DiscardHandler)
+	}
+}
+
+// Validate implements core.Validator.
+func (p *Provider) Validate() error {
+	if p.Model == "" {
+		return errors.New("ollama: model is required")
+	}
+	if p.ContextWindow < 0 {
+		return errors.New("ollama: context_window must not be negative")
+	}
+	for _, k := range p.APIKeys {
+		if k == "" {
+			return errors.New("ollama: api_keys must not contain empty keys")
+		}
+	}
+	if p.Weight < 0 {
+		return errors.New("ollama: weight must not be negative")
+	}
+	if p.Cost < 0 {
+		return errors.New("ollama: cost must not be negative")
+	}
+	return nil
+}
+
+// ChainEntry implements provider.ChainMember.
+func (p *Provider) ChainEntry() provider.ChainEntry {
+	return provider.ChainEntry{
+		Role:        p.Role,
+		Auth:        p.auth,
+		FallbackFor: p.FallbackFor,
+		Weight:      p.Weight,
+		Cost:        p.Cost,
+	}
+}
+
+// ModelName implements provider.Provider.
+func (p *Provider) ModelName() string {
+	return p.Model
+}
+
+// ContextWindowSize implements provider.Provider. Unless configured, the
+// window is read from the model metadata on first use. When it cannot be
+// read, 0 (unknown) is returned and the metadata is not requested again
+// for showRetryInterval, so an unreachable server does not delay every
+// caller.
+func (p *Provider) ContextWindowSize() int {
+	if p.ContextWindow > 0 {
+		return p.ContextWindow
+	}
+
+	p.windowMu.Lock()
+	defer p.windowMu.Unlock()
+	if p.window > 0 || time.Now().Before(p.windowRetry) {
+		return p.window
+	}
+
+	ctx, cancel := provider.WithRequestTimeout(context.Background(), showTimeout)
+	defer cancel()
+	window, err := p.modelWindow(ctx)
+	if err != nil {
+		p.logger.Warn("ollama: reading model context window", "model", p.Model, "error", err)
+	}
+	if err != nil || window <= 0 {
+		p.windowRetry = time.Now().Add(showRetryInterval)
+		return 0
+	}
+	p.window = window
+	return window
+}
+
+// modelWindow reads the context window of the model from /api/show.
+func (p *Provider) modelWindow(ctx context.Context) (int, error) {
+	resp, err := p.do(ctx, http.MethodPost, "/api/show", showRequest{Model: p.Model})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var show showResponse
+	if err := json.NewDecoder(resp.Body).Decode(&show); err != nil {
+		return 0, fmt.Errorf("ollama: decoding model metadata: %w", err)
+	}
+	return show.contextWindow(), nil
+}
+
+// SupportsContent implements provider.ContentSupporter. Images are sent
+// to vision models when given as base64 data URLs; other media are
+// replaced by text placeholders.
+func (p *Provider) SupportsContent(t provider.ContentPartType) bool {
+	return t == provider.ContentPartText || t == provider.ContentPartImage
+}
+
+// Complete implements provider.Provider.
+func (p *Provider) Complete(ctx context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+	ctx, cancel := provider.WithRequestTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	resp, err := p.do(ctx, http.MethodPost, "/api/chat", p.newRequest(req, false))
+	if err != nil {
+		return provider.CompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var body chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return provider.CompletionResponse{}, provider.TransportError(ctx, "ollama", fmt.Errorf("decoding response: %w", err))
+	}
+	if body.Error != "" {
+		return provider.CompletionResponse{}, classify(0, body.Error)
+	}
+
+	calls := p.toolCalls(body.Message.ToolCalls)
+	return provider.CompletionResponse{
+		Content:      body.Message.Content,
+		ToolCalls:    calls,
+		FinishReason: finishReason(body.DoneReason, len(calls) > 0),
+		Usage:        body.tokenUsage(),
+	}, nil
+}
+
+// Stream implements provider.Provider.
+func (p *Provider) Stream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+	ctx, cancel := provider.WithRequestTimeout(ctx, p.Timeout)
+
+	resp, err := p.do(ctx, http.MethodPost, "/api/chat", p.newRequest(req, true))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	ch := make(chan provider.StreamChunk, 16)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		defer resp.Body.Close()
+		p.readStream(ctx, resp.Body, ch)
+	}()
+	return ch, nil
+}
+
+// readStream decodes the lines of a streamed response into ch. Each line
+// is a JSON object; the last one has done set and carries the counts.
+func (p *Provider) readStream(ctx context.Context, body io.Reader, ch chan<- provider.StreamChunk) {
+	send := func(c provider.StreamChunk) bool {
+		select {
+		case ch <- c:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	var sawCalls bool
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk chatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			send(provider.StreamChunk{Err: fmt.Errorf("%w: ollama: decoding stream line: %v", provider.ErrProviderDown, err)})
+			return
+		}
+		if chunk.Error != "" {
+			send(provider.StreamChunk{Err: classify(0, chunk.Error)})
+			return
+		}
+
+		out := provider.StreamChunk{
+			Content:   chunk.Message.Content,
+			ToolCalls: p.toolCalls(chunk.Message.ToolCalls),
+		}
+		sawCalls = sawCalls || len(out.ToolCalls) > 0
+		if chunk.Done {
+			usage := chunk.tokenUsage()
+			out.FinishReason = finishReason(chunk.DoneReason, sawCalls)
+			out.Usage = &usage
+		}
+		if out.Content != "" || out.ToolCalls != nil || chunk.Done {
+			if !send(out) {
+				return
+			}
+		}
+		if chunk.Done {
+			return
+		}
+	}
+
+	err := sc.Err()
+	if err == nil {
+		err = errors.New("stream ended before completion")
+	}
+	send(provider.StreamChunk{Err: provider.TransportError(ctx, "ollama", err)})
+}
+
+// HealthCheck implements provider.HealthChecker by listing the local
+// models, which neither loads nor pulls a model.
+func (p *Provider) HealthCheck(ctx context.Context) error {
+	resp, err := p.do(ctx, http.MethodGet, "/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// do sends a request to the API and returns the response when its status
+// is successful. A nil body sends no body.
+func (p *Provider) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("ollama: encoding request: %w", err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.BaseURL+path, r)
+	if err != nil {
+		return nil, fmt.Errorf("ollama: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key := provider.APIKey(req.Context(), p.auth); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, provider.TransportError(ctx, "ollama", err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// Interface guards.
+var (
+	_ provider.Provider         = (*Provider)(nil)
+	_ provider.HealthChecker    = (*Provider)(nil)
+	_ provider.ChainMember      = (*Provider)(nil)
+	_ provider.ContentSupporter = (*Provider)(nil)
+	_ core.Configurable         = (*Provider)(nil)
+	_ core.Provisioner          = (*Provider)(nil)
+	_ core.Validator            = (*Provider)(nil)
+)
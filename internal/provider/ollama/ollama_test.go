@@ -0,0 +1,345 @@
+package ollama_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/ollama"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+	"gopkg.in/yaml.v3"
+)
+
+// showBody is a /api/show response for a model trained on 131072 tokens.
+const showBody = `{"parameters":"stop \"<|eot_id|>\"","model_info":{"general.architecture":"llama","llama.context_length":131072}}`
+
+// newServer starts a server routing /api/show to showBody and every other
+// path to handler, and returns a provider pointed at it.
+func newServer(t *testing.T, handler http.HandlerFunc) *ollama.Provider {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/show", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, showBody)
+	})
+	mux.HandleFunc("/", handler)
+	return ollama.New(providertest.NewServer(t, mux), "llama3.1", nil)
+}
+
+func TestComplete(t *testing.T) {
+	t.Parallel()
+
+	var got map[string]any
+	var path string
+	p := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = io.WriteString(w, `{
+			"message": {"role": "assistant", "content": "", "tool_calls": [
+				{"function": {"name": "read", "arguments": {"path": "a"}}}
+			]},
+			"done": true,
+			"done_reason": "stop",
+			"prompt_eval_count": 20,
+			"eval_count": 4
+		}`)
+	})
+
+	resp, err := p.Complete(context.Background(), provider.CompletionRequest{
+		Messages: []provider.LLMMessage{
+			{Role: provider.MessageRoleSystem, Content: "be brief"},
+			{Role: provider.MessageRoleUser, Content: "look", Parts: []provider.ContentPart{
+				provider.TextPart("look"),
+				provider.ImagePart("data:image/png;base64,AAAA", "image/png"),
+				provider.ImagePart("https://example.com/a.png", "image/png"),
+			}},
+			{Role: provider.MessageRoleAssistant, ToolCalls: []provider.ToolCall{
+				{ID: "call_1", Name: "read", Arguments: json.RawMessage(`{"path":"b"}`)},
+			}},
+			{Role: provider.MessageRoleTool, Name: "read", ToolID: "call_1", Content: "data"},
+		},
+		Tools:     []provider.ToolDefinition{{Name: "read", Parameters: json.RawMessage(`{"type":"object"}`)}},
+		MaxTokens: 50,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if path != "/api/chat" {
+		t.Errorf("path = %q, want /api/chat", path)
+	}
+	gotJSON, _ := json.Marshal(got)
+	want := `{"messages":[` +
+		`{"content":"be brief","role":"system"},` +
+		`{"content":"look\n[image: https://example.com/a.png (image/png)]","images":["AAAA"],"role":"user"},` +
+		`{"content":"","role":"assistant","tool_calls":[{"function":{"arguments":{"path":"b"},"name":"read"}}]},` +
+		`{"content":"data","role":"tool","tool_name":"read"}],` +
+		`"model":"llama3.1","options":{"num_ctx":131072,"num_predict":50},"stream":false,` +
+		`"tools":[{"function":{"name":"read","parameters":{"type":"object"}},"type":"function"}]}`
+	if string(gotJSON) != want {
+		t.Errorf("request = %s, want %s", gotJSON, want)
+	}
+
+	if resp.FinishReason != provider.FinishReasonToolUse {
+		t.Errorf("FinishReason = %q, want tool_use", resp.FinishReason)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID == "" || resp.ToolCalls[0].Name != "read" ||
+		string(resp.ToolCalls[0].Arguments) != `{"path": "a"}` {
+		t.Errorf("ToolCalls = %+v", resp.ToolCalls)
+	}
+	want2 := provider.TokenUsage{PromptTokens: 20, CompletionTokens: 4, TotalTokens: 24}
+	if resp.Usage != want2 {
+		t.Errorf("Usage = %+v, want %+v", resp.Usage, want2)
+	}
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = io.WriteString(w, strings.Join([]string{
+			`{"message":{"role":"assistant","content":"Hel"},"done":false}`,
+			`{"message":{"role":"assistant","content":"lo"},"done":false}`,
+			``,
+			`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"read","arguments":{"path":"a"}}},{"function":{"name":"list","arguments":{}}}]},"done":false}`,
+			`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":7,"eval_count":3}`,
+		}, "\n")+"\n")
+	})
+
+	ch, err := p.Stream(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	content, calls, finish, usage, err := providertest.Collect(ch)
+	if err != nil {
+		t.Fatalf("stream error = %v", err)
+	}
+	if content != "Hello" {
+		t.Errorf("content = %q, want Hello", content)
+	}
+	if finish != provider.FinishReasonToolUse {
+		t.Errorf("finish = %q, want tool_use", finish)
+	}
+	if len(calls) != 2 || calls[0].Name != "read" || calls[1].Name != "list" {
+		t.Fatalf("calls = %+v", calls)
+	}
+	if calls[0].ID == calls[1].ID {
+		t.Errorf("tool call IDs = %q, %q, want distinct", calls[0].ID, calls[1].ID)
+	}
+	if usage == nil || usage.TotalTokens != 10 {
+		t.Errorf("usage = %+v, want total 10", usage)
+	}
+}
+
+func TestStream_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		body string
+		want error
+	}{
+		{name: "truncated", body: `{"message":{"content":"Hel"},"done":false}` + "\n", want: provider.ErrProviderDown},
+		{name: "error line", body: `{"error":"model runner crashed"}` + "\n", want: provider.ErrProviderDown},
+		{name: "invalid line", body: "not json\n", want: provider.ErrProviderDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, tt.body)
+			})
+			ch, err := p.Stream(context.Background(), provider.CompletionRequest{})
+			if err != nil {
+				t.Fatalf("Stream() error = %v", err)
+			}
+			if _, _, _, _, err := providertest.Collect(ch); !errors.Is(err, tt.want) {
+				t.Errorf("stream error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{name: "busy", status: http.StatusServiceUnavailable, body: `{"error":"server busy, please try again"}`, want: provider.ErrProviderDown},
+		{name: "rate limit", status: http.StatusTooManyRequests, body: `{"error":"too many requests"}`, want: provider.ErrRateLimit},
+		{name: "context", status: http.StatusBadRequest, body: `{"error":"input exceeds context length"}`, want: provider.ErrContextLength},
+		{name: "model not found", status: http.StatusNotFound, body: `{"error":"model \"llama3.1\" not found, try pulling it first"}`, want: nil},
+	}
+
+	sentinels := []error{provider.ErrRateLimit, provider.ErrProviderDown, provider.ErrContextLength}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			})
+
+			_, err := p.Complete(context.Background(), provider.CompletionRequest{})
+			var apiErr *ollama.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Fatalf("error = %v, want APIError with status %d", err, tt.status)
+			}
+			for _, s := range sentinels {
+				if got := errors.Is(err, s); got != (s == tt.want) {
+					t.Errorf("errors.Is(%v, %v) = %v", err, s, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	var chats atomic.Int32
+	status := http.StatusOK
+	var path string
+	p := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if r.URL.Path == "/api/chat" {
+			chats.Add(1)
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, `{"models":[]}`)
+	})
+
+	if err := p.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() error = %v", err)
+	}
+	if path != "/api/tags" {
+		t.Errorf("path = %q, want /api/tags", path)
+	}
+	if chats.Load() != 0 {
+		t.Error("HealthCheck sent a chat request")
+	}
+
+	status = http.StatusInternalServerError
+	if err := p.HealthCheck(context.Background()); !errors.Is(err, provider.ErrProviderDown) {
+		t.Errorf("HealthCheck() error = %v, want ErrProviderDown", err)
+	}
+}
+
+func TestContextWindowSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		configured int
+		show       string
+		status     int
+		want       int
+	}{
+		{name: "configured", configured: 8192, show: showBody, want: 8192},
+		{name: "model info", show: showBody, want: 131072},
+		{
+			name: "num_ctx parameter",
+			show: `{"parameters":"num_ctx                        16384\nstop x","model_info":{"general.architecture":"llama","llama.context_length":131072}}`,
+			want: 16384,
+		},
+		{name: "no metadata", show: `{}`, want: 0},
+		{name: "server error", status: http.StatusInternalServerError, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var shows atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				shows.Add(1)
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				_, _ = io.WriteString(w, tt.show)
+			}))
+			t.Cleanup(srv.Close)
+
+			p := ollama.New(srv.URL, "llama3.1", nil)
+			p.ContextWindow = tt.configured
+			if got := p.ContextWindowSize(); got != tt.want {
+				t.Errorf("ContextWindowSize() = %d, want %d", got, tt.want)
+			}
+			p.ContextWindowSize()
+			wantShows := int32(1) // failures are cached too
+			if tt.configured > 0 {
+				wantShows = 0
+			}
+			if got := shows.Load(); got != wantShows {
+				t.Errorf("metadata requests = %d, want %d", got, wantShows)
+			}
+		})
+	}
+}
+
+func TestModule(t *testing.T) {
+	t.Parallel()
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(`
+model: llama3.1
+role: fallback
+context_window: 32768
+keep_alive: 10m
+`), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &ollama.Provider{}
+	if err := p.Configure(node.Content[0]); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	if err := p.Provision(core.NewAppContext(nil, t.TempDir(), t.TempDir())); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if p.BaseURL != ollama.DefaultBaseURL {
+		t.Errorf("BaseURL = %q, want %q", p.BaseURL, ollama.DefaultBaseURL)
+	}
+	if p.ContextWindowSize() != 32768 {
+		t.Errorf("ContextWindowSize() = %d, want 32768", p.ContextWindowSize())
+	}
+	e := provider.EntryFor(ollama.ModuleID, p)
+	if e.Role != provider.RoleFallback || e.Auth != nil {
+		t.Errorf("entry = %+v, want fallback without auth", e)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		p       *ollama.Provider
+		wantErr bool
+	}{
+		{name: "valid", p: &ollama.Provider{Model: "llama3.1"}},
+		{name: "missing model", p: &ollama.Provider{}, wantErr: true},
+		{name: "negative window", p: &ollama.Provider{Model: "llama3.1", ContextWindow: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package ollama
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+// chatRequest is the body of a /api/chat request.
+type chatRequest struct {
+	Model     string        `json:"model"`
+	Messages  []chatMessage `json:"messages"`
+	Tools     []chatTool    `json:"tools,omitempty"`
+	Stream    bool          `json:"stream"`
+	Options   *options      `json:"options,omitempty"`
+	KeepAlive string        `json:"keep_alive,omitempty"`
+}
+
+type options struct {
+	NumCtx      int      `json:"num_ctx,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// chatMessage is a message of a request or response. Images are base64
+// encoded.
+type chatMessage struct {
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	Images    []string       `json:"images,omitempty"`
+	ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
+	ToolName  string         `json:"tool_name,omitempty"`
+}
+
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// chatToolCall is a tool call. Unlike the OpenAI format, arguments are a
+// JSON object and calls carry no ID.
+type chatToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatResponse is the body of a /api/chat response, or one line of a
+// streamed response.
+type chatResponse struct {
+	Message         chatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+	Error           string      `json:"error"`
+}
+
+func (r chatResponse) tokenUsage() provider.TokenUsage {
+	return provider.TokenUsage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// showRequest is the body of a /api/show request.
+type showRequest struct {
+	Model string `json:"model"`
+}
+
+// showResponse holds the parts of the model metadata used to find its
+// context window.
+type showResponse struct {
+	Parameters string         `json:"parameters"`
+	ModelInfo  map[string]any `json:"model_info"`
+}
+
+// contextWindow returns the num_ctx parameter of the model, else its
+// trained context length, else zero.
+func (s showResponse) contextWindow() int {
+	for line := range strings.SplitSeq(s.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+
+	if arch, ok := s.ModelInfo["general.architecture"].(string); ok {
+		if n, ok := s.ModelInfo[arch+".context_length"].(float64); ok && n > 0 {
+			return int(n)
+		}
+	}
+	for k, v := range s.ModelInfo {
+		if n, ok := v.(float64); ok && n > 0 && strings.HasSuffix(k, ".context_length") {
+			return int(n)
+		}
+	}
+	return 0
+}
+
+// newRequest converts a completion request to the wire format. The context
+// window is always requested explicitly, so that the server does not
+// silently truncate prompts to its smaller default.
+func (p *Provider) newRequest(req provider.CompletionRequest, stream bool) chatRequest {
+	out := chatRequest{
+		Model:     p.Model,
+		Messages:  make([]chatMessage, 0, len(req.Messages)),
+		Stream:    stream,
+		KeepAlive: p.KeepAlive,
+	}
+	opts := options{
+		NumCtx:      p.ContextWindowSize(),
+		NumPredict:  req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+	}
+	if opts.NumCtx != 0 || opts.NumPredict != 0 || opts.Temperature != nil || opts.TopP != nil || len(opts.Stop) > 0 {
+		out.Options = &opts
+	}
+
+	for _, m := range req.Messages {
+		out.Messages = append(out.Messages, toWireMessage(m))
+	}
+	for _, t := range req.Tools {
+		out.Tools = append(out.Tools, chatTool{
+			Type: "function",
+			Function: chatFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+	return out
+}
+
+func toWireMessage(m provider.LLMMessage) chatMessage {
+	out := chatMessage{Role: string(m.Role)}
+	switch m.Role {
+	case provider.MessageRoleTool:
+		out.ToolName = m.Name
+	case provider.MessageRoleAssistant:
+		for _, tc := range m.ToolCalls {
+			var call chatToolCall
+			call.Function.Name = tc.Name
+			call.Function.Arguments = arguments(tc.Arguments)
+			out.ToolCalls = append(out.ToolCalls, call)
+		}
+	}
+
+	if !m.HasMedia() {
+		out.Content = m.TextContent()
+		return out
+	}
+
+	// Images the server can take inline are attached; the others, like
+	// other media, stay as placeholders in the text.
+	var text []string
+	for _, part := range m.Parts {
+		if data, ok := base64Image(part); ok {
+			out.Images = append(out.Images, data)
+			continue
+		}
+		if s := (provider.LLMMessage{Parts: []provider.ContentPart{part}}).TextContent(); s != "" {
+			text = append(text, s)
+		}
+	}
+	out.Content = strings.Join(text, "\n")
+	return out
+}
+
+// base64Image returns the payload of an image given as a base64 data URL.
+func base64Image(part provider.ContentPart) (string, bool) {
+	if part.Type != provider.ContentPartImage {
+		return "", false
+	}
+	rest, ok := strings.CutPrefix(part.URL, "data:")
+	if !ok {
+		return "", false
+	}
+	meta, data, found := strings.Cut(rest, ",")
+	if !found || !strings.HasSuffix(meta, ";base64") {
+		return "", false
+	}
+	return data, true
+}
+
+// toolCalls converts the tool calls of a response, numbering them since
+// the API does not identify them.
+func (p *Provider) toolCalls(calls []chatToolCall) []provider.ToolCall {
+	var out []provider.ToolCall
+	for _, c := range calls {
+		out = append(out, provider.ToolCall{
+			ID:        fmt.Sprintf("call_%d", p.callSeq.Add(1)),
+			Name:      c.Function.Name,
+			Arguments: arguments(c.Function.Arguments),
+		})
+	}
+	return out
+}
+
+func arguments(raw json.RawMessage) json.RawMessage {
+	if s := strings.TrimSpace(string(raw)); s == "" || s == "null" {
+		return json.RawMessage("{}")
+	}
+	return raw
+}
+
+// finishReason maps the done reason of a response to a
+// provider.FinishReason. The API reports "stop" after tool calls.
+func finishReason(reason string, toolCalls bool) provider.FinishReason {
+	switch {
+	case toolCalls:
+		return provider.FinishReasonToolUse
+	case reason == "length":
+		return provider.FinishReasonLength
+	default:
+		return provider.FinishReasonStop
+	}
+}
+
+// APIError is an error response of the server. It wraps the provider
+// sentinel error matching its cause, if any.
+type APIError struct {
+	StatusCode int
+	Message    string
+
+	err error
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("ollama: HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	return "ollama: " + e.Message
+}
+
+// Unwrap returns the provider error of e, if any: a
+// *provider.RateLimitError for rate limits, else a sentinel error.
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// responseError builds the error of an unsuccessful response.
+func responseError(resp *http.Response) error {
+	data, text := provider.ReadErrorBody(resp)
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Error == "" {
+		body.Error = text
+	}
+	e := classify(resp.StatusCode, body.Error)
+	provider.RateLimitHeaders(e, resp.Header, "")
+	return e
+}
+
+// classify maps a server error to the provider errors: rate
+// limits to a RateLimitError, a busy or failing server to ErrProviderDown, and
+// prompts too long for the model to ErrContextLength. A missing model is
+// a configuration error and maps to none of them.
+func classify(status int, message string) *APIError {
+	out := &APIError{StatusCode: status, Message: message}
+
+	msg := strings.ToLower(message)
+	switch {
+	case status == http.StatusTooManyRequests:
+		out.err = &provider.RateLimitError{Remaining: -1}
+	case strings.Contains(msg, "context length") || strings.Contains(msg, "context window"):
+		out.err = provider.ErrContextLength
+	case status >= http.StatusInternalServerError || status == http.StatusRequestTimeout || status == 0:
+		out.err = provider.ErrProviderDown
+	}
+	return out
+}
@@ -0,0 +1,362 @@
+// Package openai provides a provider module for the OpenAI chat
+// completions API and the many servers compatible with it (vLLM, LM Studio,
+// OpenRouter, llama.cpp and others).
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/sse"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	core.RegisterModule(&Provider{})
+}
+
+// ModuleID is the identifier of the OpenAI provider module.
+const ModuleID = "provider.openai"
+
+// Defaults applied by Provision.
+const (
+	DefaultBaseURL       = "https://api.openai.com/v1"
+	DefaultContextWindow = 128000
+	DefaultTimeout       = 5 * time.Minute
+)
+
+// doneMarker is the data of the event that ends a stream.
+const doneMarker = "[DONE]"
+
+// Provider is a provider.Provider speaking the OpenAI chat completions
+// wire format.
+type Provider struct {
+	// BaseURL is the API root, without the /chat/completions suffix.
+	// Default: DefaultBaseURL.
+	BaseURL string `yaml:"base_url"`
+
+	// Model is the model requested from the API.
+	Model string `yaml:"model"`
+
+	// APIKeys are the keys sent as bearer tokens. When several are set,
+	// the chain rotates to the next one on rate limits. Servers that need
+	// no authentication can leave it empty.
+	APIKeys []string `yaml:"api_keys"`
+
+	// Headers are extra HTTP headers sent with every request.
+	Headers map[string]string `yaml:"headers"`
+
+	// ContextWindow is the context window of the model in tokens.
+	// Default: DefaultContextWindow.
+	ContextWindow int `yaml:"context_window"`
+
+	// Timeout bounds a whole request, including reading a streamed
+	// response. Default: DefaultTimeout.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Role is the role of the provider in the chain. Default: primary.
+	Role provider.Role `yaml:"role"`
+
+	// FallbackFor restricts a fallback provider to the listed roles.
+	// Empty means all roles.
+	FallbackFor []provider.Role `yaml:"fallback_for"`
+
+	// Weight is the share of traffic of the provider among those of its
+	// role under the weighted strategy. Default: 1.
+	Weight int `yaml:"weight"`
+
+	// Cost is the relative cost of the provider, such as its price per
+	// million tokens, for the least_cost strategy.
+	Cost float64 `yaml:"cost"`
+
+	auth   *provider.AuthProfile
+	client *http.Client
+}
+
+// New creates a provider for model served at baseURL, authenticated with
+// the keys of auth, which may be nil. It is ready to use without the
+// module lifecycle.
+func New(baseURL, model string, auth *provider.AuthProfile) *Provider {
+	p := &Provider{BaseURL: baseURL, Model: model, auth: auth}
+	p.defaults()
+	return p
+}
+
+// ModuleInfo implements core.Module.
+func (*Provider) ModuleInfo() core.ModuleInfo {
+	return core.ModuleInfo{
+		ID:       ModuleID,
+		New:      func() core.Module { return &Provider{} },
+		Optional: true,
+	}
+}
+
+// Configure implements core.Configurable.
+func (p *Provider) Configure(node *yaml.Node) error {
+	return node.Decode(p)
+}
+
+// Provision implements core.Provisioner.
+func (p *Provider) Provision(_ *core.AppContext) error {
+	if len(p.APIKeys) > 0 {
+		auth, err := provider.NewAuthProfile(p.APIKeys...)
+		if err != nil {
+			return fmt.Errorf("openai: %w", err)
+		}
+		p.auth = auth
+	}
+	p.defaults()
+	return nil
+}
+
+func (p *Provider) defaults() {
+	if p.BaseURL == "" {
+		p.BaseURL = DefaultBaseURL
+	}
+	p.BaseURL = strings.TrimSuffix(p.BaseURL, "/")
+	if p.ContextWindow <= 0 {
+		p.ContextWindow = DefaultContextWindow
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = DefaultTimeout
+	}
+	if p.client == nil {
+		p.client = &http.Client{}
+	}
+}
+
+// Validate implements core.Validator.
+func (p *Provider) Validate() error {
+	if p.Model == "" {
+		return errors.New("openai: model is required")
+	}
+	for _, k := range p.APIKeys {
+		if k == "" {
+			return errors.New("openai: api_keys must not contain empty keys")
+		}
+	}
+	if p.Weight < 0 {
+		return errors.New("openai: weight must not be negative")
+	}
+	if p.Cost < 0 {
+		return errors.New("openai: cost must not be negative")
+	}
+	return nil
+}
+
+// ChainEntry implements provider.ChainMember.
+func (p *Provider) ChainEntry() provider.ChainEntry {
+	return provider.ChainEntry{
+		Role:        p.Role,
+		Auth:        p.auth,
+		FallbackFor: p.FallbackFor,
+		Weight:      p.Weight,
+		Cost:        p.Cost,
+	}
+}
+
+// ContextWindowSize implements provider.Provider.
+func (p *Provider) ContextWindowSize() int {
+	return p.ContextWindow
+}
+
+// ModelName implements provider.Provider.
+func (p *Provider) ModelName() string {
+	return p.Model
+}
+
+// SupportsContent implements provider.ContentSupporter. Images are sent
+// as image_url parts; other media are replaced by text placeholders.
+func (p *Provider) SupportsContent(t provider.ContentPartType) bool {
+	return t == provider.ContentPartText || t == provider.ContentPartImage
+}
+
+// Complete implements provider.Provider.
+func (p *Provider) Complete(ctx context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+	ctx, cancel := provider.WithRequestTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	resp, err := p.post(ctx, p.newRequest(req, false))
+	if err != nil {
+		return provider.CompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var body chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return provider.CompletionResponse{}, provider.TransportError(ctx, "openai", fmt.Errorf("decoding response: %w", err))
+	}
+	if len(body.Choices) == 0 {
+		return provider.CompletionResponse{}, fmt.Errorf("%w: openai: response has no choices", provider.ErrProviderDown)
+	}
+
+	choice := body.Choices[0]
+	out := provider.CompletionResponse{
+		Content:      choice.Message.Content,
+		ToolCalls:    fromWireToolCalls(choice.Message.ToolCalls),
+		FinishReason: finishReason(choice.FinishReason),
+	}
+	if body.Usage != nil {
+		out.Usage = body.Usage.tokenUsage()
+	}
+	return out, nil
+}
+
+// Stream implements provider.Provider. Tool calls are assembled from their
+// deltas and delivered whole in the chunk carrying the finish reason.
+func (p *Provider) Stream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+	ctx, cancel := provider.WithRequestTimeout(ctx, p.Timeout)
+
+	resp, err := p.post(ctx, p.newRequest(req, true))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	ch := make(chan provider.StreamChunk, 16)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		defer resp.Body.Close()
+		p.readStream(ctx, resp.Body, ch)
+	}()
+	return ch, nil
+}
+
+// readStream decodes the events of a streamed response into ch.
+func (p *Provider) readStream(ctx context.Context, body io.Reader, ch chan<- provider.StreamChunk) {
+	send := func(c provider.StreamChunk) bool {
+		select {
+		case ch <- c:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var calls toolCallAccumulator
+	events := sse.NewReader(body)
+	for {
+		ev, err := events.Next()
+		if errors.Is(err, io.EOF) {
+			send(provider.StreamChunk{Err: fmt.Errorf("%w: openai: stream ended before completion", provider.ErrProviderDown)})
+			return
+		}
+		if err != nil {
+			send(provider.StreamChunk{Err: provider.TransportError(ctx, "openai", fmt.Errorf("reading stream: %w", err))})
+			return
+		}
+		if ev.Data == doneMarker {
+			return
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+			send(provider.StreamChunk{Err: fmt.Errorf("%w: openai: decoding stream chunk: %v", provider.ErrProviderDown, err)})
+			return
+		}
+		if chunk.Error != nil {
+			send(provider.StreamChunk{Err: classify(0, *chunk.Error)})
+			return
+		}
+
+		for _, choice := range chunk.Choices {
+			calls.add(choice.Delta.ToolCalls)
+			out := provider.StreamChunk{Content: choice.Delta.Content}
+			if choice.FinishReason != "" {
+				out.FinishReason = finishReason(choice.FinishReason)
+				out.ToolCalls = calls.flush()
+			}
+			if out.Content == "" && out.FinishReason == "" {
+				continue
+			}
+			if !send(out) {
+				return
+			}
+		}
+		if chunk.Usage != nil {
+			usage := chunk.Usage.tokenUsage()
+			if !send(provider.StreamChunk{Usage: &usage}) {
+				return
+			}
+		}
+	}
+}
+
+// HealthCheck implements provider.HealthChecker by listing the models of
+// the API, which costs no tokens.
+func (p *Provider) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("openai: %w", err)
+	}
+	p.setHeaders(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return provider.TransportError(ctx, "openai", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return responseError(resp)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// post sends a chat completions request and returns the response when its
+// status is successful.
+func (p *Provider) post(ctx context.Context, body chatRequest) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("openai: encoding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if body.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	p.setHeaders(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, provider.TransportError(ctx, "openai", err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+func (p *Provider) setHeaders(req *http.Request) {
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	if key := provider.APIKey(req.Context(), p.auth); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+}
+
+// Interface guards.
+var (
+	_ provider.Provider         = (*Provider)(nil)
+	_ provider.HealthChecker    = (*Provider)(nil)
+	_ provider.ChainMember      = (*Provider)(nil)
+	_ provider.ContentSupporter = (*Provider)(nil)
+	_ core.Configurable         = (*Provider)(nil)
+	_ core.Provisioner          = (*Provider)(nil)
+	_ core.Validator            = (*Provider)(nil)
+)
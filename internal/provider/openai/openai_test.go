@@ -0,0 +1,530 @@
+package openai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/core"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/openai"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+	"gopkg.in/yaml.v3"
+)
+
+// newServer starts a server answering every request with handler and
+// returns a provider pointed at it.
+func newServer(t *testing.T, handler http.HandlerFunc, keys ...string) *openai.Provider {
+	t.Helper()
+	var auth *provider.AuthProfile
+	if len(keys) > 0 {
+		var err error
+		if auth, err = provider.NewAuthProfile(keys...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return openai.New(providertest.NewServer(t, handler), "gpt-test", auth)
+}
+
+func TestComplete_Request(t *testing.T) {
+	t.Parallel()
+
+	var got map[string]any
+	var gotPath, gotAuth string
+	p := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`)
+	}, "sk-1")
+
+	temp := 0.5
+	_, err := p.Complete(context.Background(), provider.CompletionRequest{
+		Messages: []provider.LLMMessage{
+			{Role: provider.MessageRoleSystem, Content: "be brief"},
+			{Role: provider.MessageRoleUser, Content: "look", Parts: []provider.ContentPart{
+				provider.TextPart("look"),
+				provider.ImagePart("https://example.com/a.png", "image/png"),
+			}},
+			{Role: provider.MessageRoleAssistant, ToolCalls: []provider.ToolCall{
+				{ID: "call_1", Name: "read", Arguments: json.RawMessage(`{"path":"a"}`)},
+			}},
+			{Role: provider.MessageRoleTool, Name: "read", ToolID: "call_1", Content: "data"},
+		},
+		Tools: []provider.ToolDefinition{
+			{Name: "read", Description: "Read a file", Parameters: json.RawMessage(`{"type":"object"}`)},
+		},
+		MaxTokens:   100,
+		Temperature: &temp,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if gotPath != "/chat/completions" {
+		t.Errorf("path = %q, want /chat/completions", gotPath)
+	}
+	if gotAuth != "Bearer sk-1" {
+		t.Errorf("Authorization = %q, want Bearer sk-1", gotAuth)
+	}
+
+	want := `{
+		"model": "gpt-test",
+		"max_tokens": 100,
+		"temperature": 0.5,
+		"messages": [
+			{"role": "system", "content": "be brief"},
+			{"role": "user", "content": [
+				{"type": "text", "text": "look"},
+				{"type": "image_url", "image_url": {"url": "https://example.com/a.png"}}
+			]},
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"index": 0, "id": "call_1", "type": "function", "function": {"name": "read", "arguments": "{\"path\":\"a\"}"}}
+			]},
+			{"role": "tool", "content": "data", "tool_call_id": "call_1"}
+		],
+		"tools": [
+			{"type": "function", "function": {"name": "read", "description": "Read a file", "parameters": {"type": "object"}}}
+		]
+	}`
+	var wantMap map[string]any
+	if err := json.Unmarshal([]byte(want), &wantMap); err != nil {
+		t.Fatal(err)
+	}
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(wantMap)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("request = %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+func TestComplete_Response(t *testing.T) {
+	t.Parallel()
+
+	p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `{
+			"choices": [{
+				"message": {"content": null, "tool_calls": [
+					{"id": "call_1", "type": "function", "function": {"name": "read", "arguments": "{\"path\":\"a\"}"}},
+					{"id": "call_2", "type": "function", "function": {"name": "list", "arguments": ""}}
+				]},
+				"finish_reason": "tool_calls"
+			}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+		}`)
+	})
+
+	resp, err := p.Complete(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.FinishReason != provider.FinishReasonToolUse {
+		t.Errorf("FinishReason = %q, want tool_use", resp.FinishReason)
+	}
+	if len(resp.ToolCalls) != 2 {
+		t.Fatalf("len(ToolCalls) = %d, want 2", len(resp.ToolCalls))
+	}
+	if tc := resp.ToolCalls[0]; tc.ID != "call_1" || tc.Name != "read" || string(tc.Arguments) != `{"path":"a"}` {
+		t.Errorf("ToolCalls[0] = %+v", tc)
+	}
+	if args := string(resp.ToolCalls[1].Arguments); args != "{}" {
+		t.Errorf("ToolCalls[1].Arguments = %s, want {}", args)
+	}
+	want := provider.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	if resp.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", resp.Usage, want)
+	}
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	var stream map[string]any
+	p := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&stream)
+		providertest.WriteSSE(w,
+			`{"choices":[{"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"choices":[{"delta":{"content":"lo"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"list","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a\"}"}}]}}]}`,
+			`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`,
+			`[DONE]`,
+		)
+	})
+
+	ch, err := p.Stream(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	content, calls, finish, usage, err := providertest.Collect(ch)
+	if err != nil {
+		t.Fatalf("stream error = %v", err)
+	}
+
+	if stream["stream"] != true {
+		t.Errorf("stream = %v, want true", stream["stream"])
+	}
+	if content != "Hello" {
+		t.Errorf("content = %q, want Hello", content)
+	}
+	if finish != provider.FinishReasonToolUse {
+		t.Errorf("finish = %q, want tool_use", finish)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("len(calls) = %d, want 2", len(calls))
+	}
+	if c := calls[0]; c.ID != "call_1" || c.Name != "read" || string(c.Arguments) != `{"path":"a"}` {
+		t.Errorf("calls[0] = %+v", c)
+	}
+	if c := calls[1]; c.ID != "call_2" || c.Name != "list" {
+		t.Errorf("calls[1] = %+v", c)
+	}
+	if usage == nil || usage.TotalTokens != 7 {
+		t.Errorf("usage = %+v, want total 7", usage)
+	}
+}
+
+func TestStream_Truncated(t *testing.T) {
+	t.Parallel()
+
+	p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		providertest.WriteSSE(w, `{"choices":[{"delta":{"content":"Hel"}}]}`)
+	})
+
+	ch, err := p.Stream(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	content, _, _, _, err := providertest.Collect(ch)
+	if content != "Hel" {
+		t.Errorf("content = %q, want Hel", content)
+	}
+	if !errors.Is(err, provider.ErrProviderDown) {
+		t.Errorf("stream error = %v, want ErrProviderDown", err)
+	}
+}
+
+func TestStream_ErrorEvent(t *testing.T) {
+	t.Parallel()
+
+	p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		providertest.WriteSSE(w, `{"error":{"message":"overloaded","type":"server_error"}}`)
+	})
+
+	ch, err := p.Stream(context.Background(), provider.CompletionRequest{})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if _, _, _, _, err := providertest.Collect(ch); !errors.Is(err, provider.ErrProviderDown) {
+		t.Errorf("stream error = %v, want ErrProviderDown", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{
+			name:   "rate limit",
+			status: http.StatusTooManyRequests,
+			body:   `{"error":{"message":"slow down","type":"requests","code":"rate_limit_exceeded"}}`,
+			want:   provider.ErrRateLimit,
+		},
+		{
+			name:   "server error",
+			status: http.StatusBadGateway,
+			body:   `<html>bad gateway</html>`,
+			want:   provider.ErrProviderDown,
+		},
+		{
+			name:   "context length code",
+			status: http.StatusBadRequest,
+			body:   `{"error":{"message":"too long","type":"invalid_request_error","code":"context_length_exceeded"}}`,
+			want:   provider.ErrContextLength,
+		},
+		{
+			name:   "context length message",
+			status: http.StatusBadRequest,
+			body:   `{"error":{"message":"This model's maximum context length is 8192 tokens"}}`,
+			want:   provider.ErrContextLength,
+		},
+		{
+			name:   "unauthorized",
+			status: http.StatusUnauthorized,
+			body:   `{"error":{"message":"invalid key","code":"invalid_api_key"}}`,
+			want:   nil,
+		},
+	}
+
+	sentinels := []error{provider.ErrRateLimit, provider.ErrProviderDown, provider.ErrContextLength}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			})
+
+			for _, call := range []func() error{
+				func() error {
+					_, err := p.Complete(context.Background(), provider.CompletionRequest{})
+					return err
+				},
+				func() error {
+					_, err := p.Stream(context.Background(), provider.CompletionRequest{})
+					return err
+				},
+			} {
+				err := call()
+				var apiErr *openai.APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+					t.Fatalf("error = %v, want APIError with status %d", err, tt.status)
+				}
+				for _, s := range sentinels {
+					if got := errors.Is(err, s); got != (s == tt.want) {
+						t.Errorf("errors.Is(%v, %v) = %v", err, s, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestComplete_Credential(t *testing.T) {
+	t.Parallel()
+
+	var gotAuth string
+	p := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}, "own-key")
+
+	ctx := provider.WithCredential(context.Background(), "chain-key")
+	if _, err := p.Complete(ctx, provider.CompletionRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if gotAuth != "Bearer chain-key" {
+		t.Errorf("Authorization = %q, want Bearer chain-key", gotAuth)
+	}
+}
+
+func TestErrors_RateLimit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    string
+		want    provider.RateLimitError
+	}{
+		{
+			name:    "retry after",
+			headers: map[string]string{"Retry-After": "12", "x-ratelimit-remaining-requests": "0"},
+			body:    `{"error":{"message":"slow down","code":"rate_limit_exceeded"}}`,
+			want:    provider.RateLimitError{RetryAfter: 12 * time.Second, Remaining: 0},
+		},
+		{
+			name: "reset of exhausted limit",
+			headers: map[string]string{
+				"x-ratelimit-remaining-requests": "3",
+				"x-ratelimit-reset-requests":     "1s",
+				"x-ratelimit-remaining-tokens":   "0",
+				"x-ratelimit-reset-tokens":       "6m0s",
+			},
+			body: `{"error":{"message":"Rate limit reached for gpt-4o in organization org-1 on tokens per min (TPM)","code":"rate_limit_exceeded"}}`,
+			want: provider.RateLimitError{RetryAfter: 6 * time.Minute, Remaining: 3, Scope: provider.RateLimitScopeModel},
+		},
+		{
+			name: "quota",
+			body: `{"error":{"message":"You exceeded your current quota","code":"insufficient_quota"}}`,
+			want: provider.RateLimitError{Remaining: -1, Scope: provider.RateLimitScopeOrg},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = io.WriteString(w, tt.body)
+			})
+
+			_, err := p.Complete(context.Background(), provider.CompletionRequest{})
+			var rl *provider.RateLimitError
+			if !errors.As(err, &rl) {
+				t.Fatalf("error = %v, want RateLimitError", err)
+			}
+			if *rl != tt.want {
+				t.Errorf("RateLimitError = %+v, want %+v", *rl, tt.want)
+			}
+			if !errors.Is(err, provider.ErrRateLimit) {
+				t.Errorf("errors.Is(%v, ErrRateLimit) = false", err)
+			}
+		})
+	}
+}
+
+func TestComplete_Unreachable(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	p := openai.New(srv.URL, "gpt-test", nil)
+
+	_, err := p.Complete(context.Background(), provider.CompletionRequest{})
+	if !errors.Is(err, provider.ErrProviderDown) {
+		t.Errorf("Complete() error = %v, want ErrProviderDown", err)
+	}
+}
+
+func TestComplete_Cancelled(t *testing.T) {
+	t.Parallel()
+
+	p := newServer(t, func(http.ResponseWriter, *http.Request) {})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := p.Complete(ctx, provider.CompletionRequest{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Complete() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	status := http.StatusOK
+	var gotPath string
+	p := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, `{"data":[]}`)
+	})
+
+	if err := p.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() error = %v", err)
+	}
+	if gotPath != "/models" {
+		t.Errorf("path = %q, want /models", gotPath)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := p.HealthCheck(context.Background()); !errors.Is(err, provider.ErrProviderDown) {
+		t.Errorf("HealthCheck() error = %v, want ErrProviderDown", err)
+	}
+}
+
+func TestModule(t *testing.T) {
+	t.Parallel()
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(`
+base_url: http://localhost:8000/v1/
+model: local-model
+api_keys: [k1, k2]
+context_window: 32000
+role: fallback
+fallback_for: [internal]
+weight: 3
+cost: 2.5
+`), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &openai.Provider{}
+	if err := p.Configure(node.Content[0]); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	if err := p.Provision(&core.AppContext{}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if p.BaseURL != "http://localhost:8000/v1" {
+		t.Errorf("BaseURL = %q, want trailing slash trimmed", p.BaseURL)
+	}
+	if p.ModelName() != "local-model" {
+		t.Errorf("ModelName() = %q, want local-model", p.ModelName())
+	}
+	if p.ContextWindowSize() != 32000 {
+		t.Errorf("ContextWindowSize() = %d, want 32000", p.ContextWindowSize())
+	}
+
+	e := provider.EntryFor(openai.ModuleID, p)
+	if e.Role != provider.RoleFallback || len(e.FallbackFor) != 1 || e.FallbackFor[0] != provider.RoleInternal {
+		t.Errorf("entry = %+v, want fallback for internal", e)
+	}
+	if e.Weight != 3 || e.Cost != 2.5 {
+		t.Errorf("entry weight, cost = %d, %v, want 3, 2.5", e.Weight, e.Cost)
+	}
+	if e.Auth == nil || e.Auth.CurrentKey() != "k1" {
+		t.Fatalf("entry auth missing or wrong key")
+	}
+}
+
+func TestModule_KeyRotation(t *testing.T) {
+	t.Parallel()
+
+	var keys []string
+	p := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}, "k1", "k2")
+
+	e := provider.EntryFor(openai.ModuleID, p)
+	if _, err := p.Complete(context.Background(), provider.CompletionRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	e.Auth.Rotate()
+	if _, err := p.Complete(context.Background(), provider.CompletionRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "k1,k2" {
+		t.Errorf("keys = %v, want [k1 k2]", keys)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		p       openai.Provider
+		wantErr bool
+	}{
+		{name: "valid", p: openai.Provider{Model: "gpt"}},
+		{name: "missing model", p: openai.Provider{}, wantErr: true},
+		{name: "empty key", p: openai.Provider{Model: "gpt", APIKeys: []string{""}}, wantErr: true},
+		{name: "negative weight", p: openai.Provider{Model: "gpt", Weight: -1}, wantErr: true},
+		{name: "negative cost", p: openai.Provider{Model: "gpt", Cost: -0.5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
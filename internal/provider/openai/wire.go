@@ -0,0 +1,363 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+// chatRequest is the body of a chat completions request.
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []chatMessage  `json:"messages"`
+	Tools         []chatTool     `json:"tools,omitempty"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+	TopP          *float64       `json:"top_p,omitempty"`
+	Stop          []string       `json:"stop,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatMessage is a message of the request. Content is a string, a list of
+// content parts, or nil for assistant messages that only call tools.
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    any            `json:"content"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+}
+
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// chatToolCall is a tool call. Arguments is a JSON document encoded as a
+// string. In stream deltas, Index identifies the call the fragment belongs
+// to and only the first fragment carries the ID and name.
+type chatToolCall struct {
+	Index    int              `json:"index"`
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function chatFunctionCall `json:"function"`
+}
+
+type chatFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// chatResponse is the body of a chat completions response.
+type chatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content   string         `json:"content"`
+			ToolCalls []chatToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage"`
+}
+
+// streamChunk is the data of one streamed event.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string         `json:"content"`
+			ToolCalls []chatToolCall `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage"`
+	Error *apiError  `json:"error"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u chatUsage) tokenUsage() provider.TokenUsage {
+	return provider.TokenUsage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+// newRequest converts a completion request to the wire format.
+func (p *Provider) newRequest(req provider.CompletionRequest, stream bool) chatRequest {
+	out := chatRequest{
+		Model:       p.Model,
+		Messages:    make([]chatMessage, 0, len(req.Messages)),
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+		Stream:      stream,
+	}
+	if stream {
+		out.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	for _, m := range req.Messages {
+		out.Messages = append(out.Messages, toWireMessage(m))
+	}
+	for _, t := range req.Tools {
+		out.Tools = append(out.Tools, chatTool{
+			Type: "function",
+			Function: chatFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+	return out
+}
+
+func toWireMessage(m provider.LLMMessage) chatMessage {
+	out := chatMessage{Role: string(m.Role), Content: messageContent(m)}
+	switch m.Role {
+	case provider.MessageRoleTool:
+		out.ToolCallID = m.ToolID
+	case provider.MessageRoleAssistant:
+		for _, tc := range m.ToolCalls {
+			args := string(tc.Arguments)
+			if args == "" {
+				args = "{}"
+			}
+			out.ToolCalls = append(out.ToolCalls, chatToolCall{
+				ID:       tc.ID,
+				Type:     "function",
+				Function: chatFunctionCall{Name: tc.Name, Arguments: args},
+			})
+		}
+		if len(out.ToolCalls) > 0 && m.Content == "" {
+			out.Content = nil
+		}
+	}
+	return out
+}
+
+// messageContent returns the text of m, or its content parts when it
+// carries images. Messages with other media use their text rendering.
+func messageContent(m provider.LLMMessage) any {
+	if !m.HasMedia() {
+		return m.TextContent()
+	}
+	parts := make([]contentPart, 0, len(m.Parts))
+	for _, part := range m.Parts {
+		switch part.Type {
+		case provider.ContentPartText:
+			parts = append(parts, contentPart{Type: "text", Text: part.Text})
+		case provider.ContentPartImage:
+			parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: part.URL}})
+		default:
+			return m.TextContent()
+		}
+	}
+	return parts
+}
+
+func fromWireToolCalls(calls []chatToolCall) []provider.ToolCall {
+	var out []provider.ToolCall
+	for _, c := range calls {
+		out = append(out, provider.ToolCall{
+			ID:        c.ID,
+			Name:      c.Function.Name,
+			Arguments: arguments(c.Function.Arguments),
+		})
+	}
+	return out
+}
+
+func arguments(s string) json.RawMessage {
+	if strings.TrimSpace(s) == "" {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(s)
+}
+
+// toolCallAccumulator assembles streamed tool call fragments by index.
+type toolCallAccumulator struct {
+	calls []chatToolCall
+}
+
+func (a *toolCallAccumulator) add(deltas []chatToolCall) {
+	for _, d := range deltas {
+		i := slices.IndexFunc(a.calls, func(c chatToolCall) bool { return c.Index == d.Index })
+		if i < 0 {
+			a.calls = append(a.calls, d)
+			continue
+		}
+		c := &a.calls[i]
+		if d.ID != "" {
+			c.ID = d.ID
+		}
+		c.Function.Name += d.Function.Name
+		c.Function.Arguments += d.Function.Arguments
+	}
+}
+
+// flush returns the assembled calls and resets the accumulator.
+func (a *toolCallAccumulator) flush() []provider.ToolCall {
+	calls := fromWireToolCalls(a.calls)
+	a.calls = nil
+	return calls
+}
+
+// finishReason maps an API finish reason to a provider.FinishReason.
+func finishReason(s string) provider.FinishReason {
+	switch s {
+	case "length":
+		return provider.FinishReasonLength
+	case "tool_calls", "function_call":
+		return provider.FinishReasonToolUse
+	case "content_filter":
+		return provider.FinishReasonFiltering
+	default:
+		return provider.FinishReasonStop
+	}
+}
+
+// APIError is an error response of the API. It wraps the provider
+// sentinel error matching its cause, if any.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+
+	err error
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	b.WriteString("openai: ")
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, "HTTP %d: ", e.StatusCode)
+	}
+	b.WriteString(e.Message)
+	if e.Code != "" {
+		fmt.Fprintf(&b, " (%s)", e.Code)
+	}
+	return b.String()
+}
+
+// Unwrap returns the provider error of e, if any: a
+// *provider.RateLimitError for rate limits, else a sentinel error.
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// apiError is the error object of an error response or stream event.
+type apiError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    any    `json:"code"`
+}
+
+// responseError builds the error of an unsuccessful response.
+func responseError(resp *http.Response) error {
+	data, text := provider.ReadErrorBody(resp)
+	var body struct {
+		Error apiError `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Error.Message == "" {
+		body.Error.Message = text
+	}
+	e := classify(resp.StatusCode, body.Error)
+	if rl := provider.RateLimitHeaders(e, resp.Header, "x-ratelimit-remaining-requests"); rl != nil && rl.RetryAfter == 0 {
+		rl.RetryAfter = resetDelay(resp.Header)
+	}
+	return e
+}
+
+// resetDelay returns the delay before the reset of the exhausted request
+// or token limit, for responses without a Retry-After header.
+func resetDelay(h http.Header) time.Duration {
+	var d time.Duration
+	for _, limit := range []string{"requests", "tokens"} {
+		if h.Get("x-ratelimit-remaining-"+limit) != "0" {
+			continue
+		}
+		// Reset times are durations such as "1s" or "6m0s".
+		if reset, err := time.ParseDuration(h.Get("x-ratelimit-reset-" + limit)); err == nil {
+			d = max(d, reset)
+		}
+	}
+	return d
+}
+
+// classify maps an API error to the provider errors: rate limits
+// to a RateLimitError, server failures to ErrProviderDown, and requests too
+// large for the model to ErrContextLength.
+func classify(status int, e apiError) *APIError {
+	out := &APIError{StatusCode: status, Type: e.Type, Message: e.Message}
+	if e.Code != nil {
+		out.Code = fmt.Sprint(e.Code)
+	}
+
+	switch {
+	case status == http.StatusTooManyRequests || out.Code == "rate_limit_exceeded" || e.Type == "rate_limit_error":
+		out.err = &provider.RateLimitError{Remaining: -1, Scope: rateLimitScope(out)}
+	case isContextLength(out):
+		out.err = provider.ErrContextLength
+	case status >= http.StatusInternalServerError || status == http.StatusRequestTimeout || e.Type == "server_error":
+		out.err = provider.ErrProviderDown
+	}
+	return out
+}
+
+// rateLimitScope returns the scope of a rate limit error. Exhausted
+// quotas are billed to the organization; limits reached "for <model> in
+// organization <org>" hold per model within the organization.
+func rateLimitScope(e *APIError) provider.RateLimitScope {
+	switch {
+	case e.Code == "insufficient_quota":
+		return provider.RateLimitScopeOrg
+	case strings.Contains(e.Message, " in organization "):
+		return provider.RateLimitScopeModel
+	default:
+		return provider.RateLimitScopeUnknown
+	}
+}
+
+func isContextLength(e *APIError) bool {
+	if e.Code == "context_length_exceeded" {
+		return true
+	}
+	if e.StatusCode != http.StatusBadRequest && e.StatusCode != http.StatusRequestEntityTooLarge && e.StatusCode != 0 {
+		return false
+	}
+	msg := strings.ToLower(e.Message)
+	return strings.Contains(msg, "context length") || strings.Contains(msg, "context window") ||
+		strings.Contains(msg, "maximum context")
+}
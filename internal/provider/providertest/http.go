@@ -0,0 +1,75 @@
+package providertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+// NewServer starts a server answering every request with handler, closed
+// when t ends, and returns its URL.
+func NewServer(t testing.TB, handler http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// WriteSSE writes server-sent events carrying only the given data.
+func WriteSSE(w http.ResponseWriter, data ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, d := range data {
+		fmt.Fprintf(w, "data: %s\n\n", d)
+	}
+}
+
+// WriteSSEEvents writes server-sent events given as alternating type and
+// data strings.
+func WriteSSEEvents(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for i := 0; i+1 < len(events); i += 2 {
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", events[i], events[i+1])
+	}
+}
+
+// Collect reads ch until it is closed. It returns the content of the
+// chunks joined, their tool calls, and the last finish reason, usage and
+// error they carried.
+func Collect(ch <-chan provider.StreamChunk) (content string, calls []provider.ToolCall, finish provider.FinishReason, usage *provider.TokenUsage, err error) {
+	for c := range ch {
+		if c.Err != nil {
+			err = c.Err
+		}
+		content += c.Content
+		calls = append(calls, c.ToolCalls...)
+		if c.FinishReason != "" {
+			finish = c.FinishReason
+		}
+		if c.Usage != nil {
+			usage = c.Usage
+		}
+	}
+	return content, calls, finish, usage, err
+}
+
+// JSONEqual reports an error on t unless got and want encode the same
+// JSON value.
+func JSONEqual(t testing.TB, got []byte, want string) {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("decoding %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("decoding want: %v", err)
+	}
+	gj, _ := json.Marshal(g)
+	wj, _ := json.Marshal(w)
+	if string(gj) != string(wj) {
+		t.Errorf("got %s, want %s", gj, wj)
+	}
+}
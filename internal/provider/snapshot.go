@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthState is the availability state of a chain entry, as reported by
+// Chain.Snapshot and Chain.Subscribe.
+type HealthState string
+
+// Health states of a chain entry.
+const (
+	HealthStateHealthy  HealthState = "healthy"
+	HealthStateCooldown HealthState = "cooldown"
+	HealthStateDead     HealthState = "dead"
+	HealthStateHalfOpen HealthState = "half-open"
+)
+
+// EntrySnapshot is the state of one chain entry at a point in time.
+type EntrySnapshot struct {
+	Name  string
+	Role  Role
+	Model string
+	State HealthState
+
+	// Failures is the number of consecutive failures.
+	Failures int
+
+	// Backoff is how long the entry is out of rotation: the cooldown
+	// duration, or the time before a dead entry turns half-open.
+	Backoff time.Duration
+
+	// CooldownUntil is when the entry becomes eligible for traffic again:
+	// the end of its cooldown, or when a dead entry turns half-open. Zero
+	// when healthy or half-open.
+	CooldownUntil time.Time
+
+	// Requests is the number of calls sent to the entry, and Errors the
+	// number of those that failed. Calls cancelled by the caller are not
+	// errors.
+	Requests uint64
+	Errors   uint64
+
+	// LatencyP50, LatencyP90 and LatencyP99 are percentiles of the response
+	// time of the latest successful calls, measured as for
+	// StrategyLeastLatency. Zero without observations.
+	LatencyP50 time.Duration
+	LatencyP90 time.Duration
+	LatencyP99 time.Duration
+
+	// Keys is the status of the entry's API keys, nil without AuthProfile.
+	Keys []KeyStatus
+}
+
+// StateEvent is a health state transition of a chain entry.
+type StateEvent struct {
+	Provider string
+	From     HealthState
+	To       HealthState
+	Time     time.Time
+}
+
+// Snapshot returns the state of every chain entry, in configuration order.
+func (pc *Chain) Snapshot() []EntrySnapshot {
+	out := make([]EntrySnapshot, len(pc.entries))
+	for i := range pc.entries {
+		e := &pc.entries[i]
+		state, failures, backoff, until := e.health.snapshot()
+		p50, p90, p99 := e.stats.percentiles()
+		out[i] = EntrySnapshot{
+			Name:          e.Name,
+			Role:          e.Role,
+			Model:         e.Provider.ModelName(),
+			State:         HealthState(state.String()),
+			Failures:      failures,
+			Backoff:       backoff,
+			CooldownUntil: until,
+			Requests:      e.stats.requests.Load(),
+			Errors:        e.stats.errors.Load(),
+			LatencyP50:    p50,
+			LatencyP90:    p90,
+			LatencyP99:    p99,
+		}
+		if e.Auth != nil {
+			out[i].Keys = e.Auth.Status()
+		}
+	}
+	return out
+}
+
+// subscriberBuffer is the channel capacity of a Subscribe subscription.
+const subscriberBuffer = 16
+
+// Subscribe returns a channel receiving the health state transitions of
+// the chain entries, and a function ending the subscription, which closes
+// the channel. The chain never blocks on a subscriber: events are dropped
+// while its channel is full.
+func (pc *Chain) Subscribe() (<-chan StateEvent, func()) {
+	ch := make(chan StateEvent, subscriberBuffer)
+	pc.subs.mu.Lock()
+	if pc.subs.chans == nil {
+		pc.subs.chans = make(map[chan StateEvent]struct{})
+	}
+	pc.subs.chans[ch] = struct{}{}
+	pc.subs.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			pc.subs.mu.Lock()
+			delete(pc.subs.chans, ch)
+			pc.subs.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// subscribers fans state transitions out to Subscribe channels.
+type subscribers struct {
+	mu    sync.Mutex
+	chans map[chan StateEvent]struct{}
+}
+
+// publish sends ev to every subscriber with room for it.
+func (s *subscribers) publish(ev StateEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.chans {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// latencyWindow is the number of latest successful calls latency
+// percentiles are computed over.
+const latencyWindow = 256
+
+// entryStats counts the calls to a chain entry and keeps the response
+// times of the latest successful ones.
+type entryStats struct {
+	requests atomic.Uint64
+	errors   atomic.Uint64
+
+	mu      sync.Mutex
+	samples []time.Duration // ring buffer of up to latencyWindow samples
+	next    int
+}
+
+// failed counts a failed call. A call cancelled by the caller says
+// nothing about the provider and is not counted.
+func (s *entryStats) failed(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	s.errors.Add(1)
+}
+
+// observe records the response time of a successful call.
+func (s *entryStats) observe(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.samples) < latencyWindow {
+		s.samples = append(s.samples, d)
+		return
+	}
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % latencyWindow
+}
+
+// percentiles returns the 50th, 90th and 99th percentiles of the recorded
+// response times, by nearest rank, or zeros without samples.
+func (s *entryStats) percentiles() (p50, p90, p99 time.Duration) {
+	s.mu.Lock()
+	sorted := slices.Clone(s.samples)
+	s.mu.Unlock()
+	if len(sorted) == 0 {
+		return 0, 0, 0
+	}
+	slices.Sort(sorted)
+	rank := func(p int) time.Duration {
+		i := (p*len(sorted)+99)/100 - 1
+		return sorted[max(i, 0)]
+	}
+	return rank(50), rank(90), rank(99)
+}
@@ -0,0 +1,177 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/provider"
+)
+
+func TestChain_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	slow := okProvider("slow")
+	slow.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		time.Sleep(2 * time.Millisecond)
+		return provider.CompletionResponse{Content: "slow"}, nil
+	}
+	auth, err := provider.NewAuthProfile("sk-first-key", "sk-second-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "down", Provider: failProvider(provider.ErrProviderDown), Role: provider.RolePrimary, Health: provider.HealthConfig{InitialBackoff: time.Hour, MaxBackoff: time.Hour}},
+		{Name: "slow", Provider: slow, Role: provider.RoleFallback, Auth: auth},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	served(t, chain, provider.RolePrimary, 3)
+
+	snap := chain.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("len(Snapshot()) = %d, want 2", len(snap))
+	}
+
+	down := snap[0]
+	if down.Name != "down" || down.Role != provider.RolePrimary || down.Model != "fail" {
+		t.Errorf("entry = %q %q %q, want down primary fail", down.Name, down.Role, down.Model)
+	}
+	if down.State != provider.HealthStateCooldown || down.Failures != 1 || down.Backoff != time.Hour {
+		t.Errorf("State, Failures, Backoff = %v, %d, %v, want cooldown, 1, 1h", down.State, down.Failures, down.Backoff)
+	}
+	if down.CooldownUntil.Before(before.Add(time.Hour)) {
+		t.Errorf("CooldownUntil = %v, want an hour from now", down.CooldownUntil)
+	}
+	// Skipped while cooling down: a single call.
+	if down.Requests != 1 || down.Errors != 1 {
+		t.Errorf("Requests, Errors = %d, %d, want 1, 1", down.Requests, down.Errors)
+	}
+	if down.Keys != nil {
+		t.Errorf("Keys = %v, want nil without AuthProfile", down.Keys)
+	}
+
+	up := snap[1]
+	if up.State != provider.HealthStateHealthy || up.Failures != 0 || !up.CooldownUntil.IsZero() {
+		t.Errorf("State, Failures, CooldownUntil = %v, %d, %v, want healthy", up.State, up.Failures, up.CooldownUntil)
+	}
+	if up.Requests != 3 || up.Errors != 0 {
+		t.Errorf("Requests, Errors = %d, %d, want 3, 0", up.Requests, up.Errors)
+	}
+	if up.LatencyP50 < 2*time.Millisecond || up.LatencyP90 < up.LatencyP50 || up.LatencyP99 < up.LatencyP90 {
+		t.Errorf("latency p50, p90, p99 = %v, %v, %v, want ordered and at least 2ms", up.LatencyP50, up.LatencyP90, up.LatencyP99)
+	}
+	if len(up.Keys) != 2 || !up.Keys[0].Active {
+		t.Errorf("Keys = %+v, want 2 keys, the first active", up.Keys)
+	}
+}
+
+func TestChain_SnapshotStreamErrors(t *testing.T) {
+	t.Parallel()
+
+	errBad := errors.New("bad chunk")
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "p", Provider: chunkProvider("p", provider.StreamChunk{Content: "a"}, provider.StreamChunk{Err: errBad}), Role: provider.RolePrimary},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := chain.Stream(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//nolint:revive // intentional empty drain loop
+	for range ch { //nolint:revive
+	}
+
+	snap := chain.Snapshot()
+	if snap[0].Requests != 1 || snap[0].Errors != 1 {
+		t.Errorf("Requests, Errors = %d, %d, want 1, 1", snap[0].Requests, snap[0].Errors)
+	}
+}
+
+func TestChain_Subscribe(t *testing.T) {
+	t.Parallel()
+
+	healthy := true
+	p := okProvider("p")
+	p.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		if healthy {
+			return provider.CompletionResponse{Content: "p"}, nil
+		}
+		return provider.CompletionResponse{}, provider.ErrProviderDown
+	}
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "p", Provider: p, Role: provider.RolePrimary, Health: provider.HealthConfig{InitialBackoff: time.Nanosecond}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, unsubscribe := chain.Subscribe()
+
+	healthy = false
+	if _, err := chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{}); err == nil {
+		t.Fatal("Complete() error = nil, want failure")
+	}
+	time.Sleep(time.Millisecond) // let the cooldown expire
+	healthy = true
+	served(t, chain, provider.RolePrimary, 1)
+
+	want := []provider.StateEvent{
+		{Provider: "p", From: provider.HealthStateHealthy, To: provider.HealthStateCooldown},
+		{Provider: "p", From: provider.HealthStateCooldown, To: provider.HealthStateHealthy},
+	}
+	for _, w := range want {
+		ev := <-events
+		if ev.Provider != w.Provider || ev.From != w.From || ev.To != w.To || ev.Time.IsZero() {
+			t.Errorf("event = %+v, want %s %s -> %s", ev, w.Provider, w.From, w.To)
+		}
+	}
+
+	unsubscribe()
+	unsubscribe() // idempotent
+	if _, ok := <-events; ok {
+		t.Error("channel open after unsubscribe")
+	}
+}
+
+func TestChain_SubscribeDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	// Every other call fails: each call is a state transition.
+	calls := 0
+	p := okProvider("p")
+	p.CompleteFunc = func(_ context.Context, _ provider.CompletionRequest) (provider.CompletionResponse, error) {
+		calls++
+		if calls%2 == 0 {
+			return provider.CompletionResponse{}, provider.ErrProviderDown
+		}
+		return provider.CompletionResponse{Content: "p"}, nil
+	}
+	chain, err := provider.NewChain([]provider.ChainEntry{
+		{Name: "p", Provider: p, Role: provider.RolePrimary, Health: provider.HealthConfig{InitialBackoff: time.Nanosecond}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe := chain.Subscribe()
+	defer unsubscribe()
+
+	// Nobody reads events: transitions beyond the buffer are dropped.
+	for range 100 {
+		_, _ = chain.Complete(context.Background(), provider.RolePrimary, provider.CompletionRequest{})
+		time.Sleep(10 * time.Microsecond)
+	}
+	if calls != 100 {
+		t.Errorf("calls = %d, want 100", calls)
+	}
+	if len(events) != cap(events) {
+		t.Errorf("len(events) = %d, want a full buffer", len(events))
+	}
+}
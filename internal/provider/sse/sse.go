@@ -0,0 +1,79 @@
+// Package sse reads Server-Sent Events streams, the framing used by the
+// streaming endpoints of most HTTP LLM APIs.
+package sse
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Event is one server-sent event.
+type Event struct {
+	// Type is the value of the event field, empty for unnamed events.
+	Type string
+
+	// Data is the event payload. Multiple data lines are joined with
+	// newlines.
+	Data string
+}
+
+// Reader reads events from a stream. It is not safe for concurrent use.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a reader of the events in r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next event. Comment lines and the id and retry fields
+// are ignored, and blocks without data are skipped. At the end of the
+// stream, a final event not followed by a blank line is still returned,
+// then Next returns io.EOF.
+func (r *Reader) Next() (Event, error) {
+	var (
+		ev      Event
+		data    []string
+		hasData bool
+	)
+	for {
+		line, err := r.r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Event{}, err
+		}
+		eof := err != nil
+		if eof && line == "" {
+			if hasData {
+				ev.Data = strings.Join(data, "\n")
+				return ev, nil
+			}
+			return Event{}, io.EOF
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			if hasData {
+				ev.Data = strings.Join(data, "\n")
+				return ev, nil
+			}
+			ev = Event{}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Type = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		}
+	}
+}
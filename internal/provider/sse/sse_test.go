@@ -0,0 +1,93 @@
+package sse_test
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/provider/sse"
+)
+
+func readAll(t *testing.T, input string) []sse.Event {
+	t.Helper()
+	r := sse.NewReader(strings.NewReader(input))
+	var events []sse.Event
+	for {
+		ev, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		events = append(events, ev)
+	}
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []sse.Event
+	}{
+		{
+			name:  "unnamed events",
+			input: "data: one\n\ndata: two\n\n",
+			want:  []sse.Event{{Data: "one"}, {Data: "two"}},
+		},
+		{
+			name:  "named event",
+			input: "event: message_start\ndata: {\"a\":1}\n\n",
+			want:  []sse.Event{{Type: "message_start", Data: `{"a":1}`}},
+		},
+		{
+			name:  "multi-line data",
+			input: "data: a\ndata: b\n\n",
+			want:  []sse.Event{{Data: "a\nb"}},
+		},
+		{
+			name:  "CRLF line endings",
+			input: "event: ping\r\ndata: x\r\n\r\n",
+			want:  []sse.Event{{Type: "ping", Data: "x"}},
+		},
+		{
+			name:  "comments and unknown fields ignored",
+			input: ": keep-alive\nid: 7\nretry: 100\ndata: x\n\n",
+			want:  []sse.Event{{Data: "x"}},
+		},
+		{
+			name:  "blocks without data skipped",
+			input: "event: ping\n\ndata: x\n\n",
+			want:  []sse.Event{{Data: "x"}},
+		},
+		{
+			name:  "no space after colon",
+			input: "data:x\n\n",
+			want:  []sse.Event{{Data: "x"}},
+		},
+		{
+			name:  "unterminated final event",
+			input: "data: one\n\ndata: two",
+			want:  []sse.Event{{Data: "one"}, {Data: "two"}},
+		},
+		{
+			name:  "empty stream",
+			input: "",
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := readAll(t, tt.input)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
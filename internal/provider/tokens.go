@@ -0,0 +1,75 @@
+package provider
+
+import "context"
+
+// TokenCounter is an optional interface for providers that can count the
+// prompt tokens of a request exactly, with the model's tokenizer or a
+// counting endpoint. Callers fall back to EstimateTokens when a provider
+// does not implement it or counting fails.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, req CompletionRequest) (int, error)
+}
+
+// Estimation heuristics. A token is assumed to be about bytesPerToken bytes
+// of text; each message carries a fixed overhead for its role and framing,
+// and each media part counts as a fixed amount.
+const (
+	bytesPerToken   = 4
+	messageOverhead = 4
+	mediaPartTokens = 256
+)
+
+// CountTokens returns the prompt tokens of req. It uses p's TokenCounter
+// when available and EstimateTokens otherwise; exact reports which one
+// produced the count.
+func CountTokens(ctx context.Context, p Provider, req CompletionRequest) (n int, exact bool) {
+	if tc, ok := p.(TokenCounter); ok {
+		if n, err := tc.CountTokens(ctx, req); err == nil {
+			return n, true
+		}
+	}
+	return EstimateTokens(req), false
+}
+
+// EstimateTokens returns a heuristic count of the prompt tokens of req.
+// It is cheap and model-agnostic, but only approximate: it tends to
+// over-count code and under-count text in non-Latin scripts.
+func EstimateTokens(req CompletionRequest) int {
+	return EstimateMessageTokens(req.Messages) + EstimateToolTokens(req.Tools)
+}
+
+// EstimateMessageTokens returns a heuristic token count for msgs.
+func EstimateMessageTokens(msgs []LLMMessage) int {
+	var n int
+	for _, m := range msgs {
+		n += messageOverhead
+		if len(m.Parts) == 0 {
+			n += len(m.Content) / bytesPerToken
+		}
+		for _, p := range m.Parts {
+			if p.Type == ContentPartText {
+				n += len(p.Text) / bytesPerToken
+			} else {
+				n += mediaPartTokens
+			}
+		}
+		for _, tc := range m.ToolCalls {
+			n += (len(tc.Name) + len(tc.Arguments)) / bytesPerToken
+		}
+	}
+	return n
+}
+
+// EstimateTextTokens returns a heuristic token count for text.
+func EstimateTextTokens(text string) int {
+	return len(text) / bytesPerToken
+}
+
+// EstimateToolTokens returns a heuristic token count for tool definitions.
+func EstimateToolTokens(tools []ToolDefinition) int {
+	var n int
+	for _, t := range tools {
+		n += (len(t.Name) + len(t.Description) + len(t.Parameters)) / bytesPerToken
+	}
+	return n
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	t.Parallel()
+
+	req := CompletionRequest{
+		Messages: []LLMMessage{
+			{Role: MessageRoleUser, Content: strings.Repeat("x", 400)},
+			{Role: MessageRoleUser, Parts: []ContentPart{
+				TextPart(strings.Repeat("x", 40)),
+				ImagePart("https://example.com/a.png", "image/png"),
+			}},
+			{Role: MessageRoleAssistant, ToolCalls: []ToolCall{{Name: "read", Arguments: []byte(`{"path":"a"}`)}}},
+		},
+		Tools: []ToolDefinition{{Name: "read", Description: "Read a file", Parameters: []byte(`{}`)}},
+	}
+
+	wantMsgs := 3*messageOverhead + 100 + 10 + mediaPartTokens + 4
+	if got := EstimateMessageTokens(req.Messages); got != wantMsgs {
+		t.Errorf("EstimateMessageTokens = %d, want %d", got, wantMsgs)
+	}
+	if got := EstimateToolTokens(req.Tools); got != 4 {
+		t.Errorf("EstimateToolTokens = %d, want 4", got)
+	}
+	if got := EstimateTokens(req); got != wantMsgs+4 {
+		t.Errorf("EstimateTokens = %d, want %d", got, wantMsgs+4)
+	}
+}
+
+// countingProvider is a plainProvider with a TokenCounter.
+type countingProvider struct {
+	plainProvider
+	n   int
+	err error
+}
+
+func (c countingProvider) CountTokens(context.Context, CompletionRequest) (int, error) {
+	return c.n, c.err
+}
+
+func TestCountTokens(t *testing.T) {
+	t.Parallel()
+
+	req := CompletionRequest{Messages: []LLMMessage{{Role: MessageRoleUser, Content: strings.Repeat("x", 40)}}}
+	estimate := EstimateTokens(req)
+
+	tests := []struct {
+		name      string
+		p         Provider
+		want      int
+		wantExact bool
+	}{
+		{"estimate", plainProvider{}, estimate, false},
+		{"counter", countingProvider{n: 42}, 42, true},
+		{"counter error", countingProvider{err: errors.New("boom")}, estimate, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, exact := CountTokens(context.Background(), tt.p, req)
+			if got != tt.want || exact != tt.wantExact {
+				t.Errorf("CountTokens = (%d, %v), want (%d, %v)", got, exact, tt.want, tt.wantExact)
+			}
+		})
+	}
+}
@@ -35,11 +35,24 @@ const (
 )
 
 // LLMMessage represents a single message in a conversation.
+//
+// Assistant messages that requested tools carry those requests in ToolCalls.
+// Each following MessageRoleTool message answers one of them: ToolID matches
+// the ToolCall.ID, Name holds the tool name and IsError flags a failed
+// execution. This lets adapters rebuild provider-specific tool_use/tool_result
+// pairs from the history alone.
+//
+// Multimodal messages carry their ordered content in Parts. When Parts is
+// set it is authoritative and Content holds its textual rendering, so
+// adapters that only handle text can keep reading Content.
 type LLMMessage struct {
-	Role    MessageRole `json:"role"`
-	Content string      `json:"content"`
-	Name    string      `json:"name,omitempty"`
-	ToolID  string      `json:"tool_id,omitempty"`
+	Role      MessageRole   `json:"role"`
+	Content   string        `json:"content"`
+	Parts     []ContentPart `json:"parts,omitempty"`
+	Name      string        `json:"name,omitempty"`
+	ToolID    string        `json:"tool_id,omitempty"`
+	ToolCalls []ToolCall    `json:"tool_calls,omitempty"`
+	IsError   bool          `json:"is_error,omitempty"`
 }
 
 // ToolCall represents a tool invocation requested by the model.
@@ -81,6 +94,11 @@ type StreamChunk struct {
 	FinishReason FinishReason `json:"finish_reason,omitempty"`
 	Usage        *TokenUsage  `json:"usage,omitempty"`
 	Err          error        `json:"-"`
+
+	// Reset reports that the request restarted on another provider after
+	// a mid-stream failure: everything received before this chunk must be
+	// discarded. See StreamFailoverReset.
+	Reset bool `json:"reset,omitempty"`
 }
 
 // TokenUsage tracks token consumption for a completion.
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
 )
 
@@ -24,11 +25,46 @@ func TestLLMMessageJSONRoundTrip(t *testing.T) {
 		t.Fatalf("unmarshal: %v", err)
 	}
 
-	if got != msg {
+	if !reflect.DeepEqual(got, msg) {
 		t.Errorf("round-trip mismatch: got %+v, want %+v", got, msg)
 	}
 }
 
+func TestLLMMessageToolCallsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	history := []LLMMessage{
+		{
+			Role:    MessageRoleAssistant,
+			Content: "let me check",
+			ToolCalls: []ToolCall{
+				{ID: "call_1", Name: "search", Arguments: json.RawMessage(`{"q":"go"}`)},
+			},
+		},
+		{
+			Role:    MessageRoleTool,
+			Content: "not found",
+			Name:    "search",
+			ToolID:  "call_1",
+			IsError: true,
+		},
+	}
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var got []LLMMessage
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, history) {
+		t.Errorf("round-trip mismatch: got %+v, want %+v", got, history)
+	}
+}
+
 func TestLLMMessageOmitempty(t *testing.T) {
 	t.Parallel()
 
@@ -49,6 +85,12 @@ func TestLLMMessageOmitempty(t *testing.T) {
 	if _, ok := raw["tool_id"]; ok {
 		t.Error("expected tool_id to be omitted when empty")
 	}
+	if _, ok := raw["tool_calls"]; ok {
+		t.Error("expected tool_calls to be omitted when empty")
+	}
+	if _, ok := raw["is_error"]; ok {
+		t.Error("expected is_error to be omitted when false")
+	}
 }
 
 func TestToolCallArgumentsRawMessage(t *testing.T) {
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"errors"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/agent"
+	"github.com/flemzord/sclaw/internal/prompt"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/skill"
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// DefaultApprovalTimeout is used when Agent.ApprovalTimeout is unset.
+const DefaultApprovalTimeout = 2 * time.Minute
+
+// ErrNoAgent is returned when no agent can handle an inbound message.
+var ErrNoAgent = errors.New("router: no agent for message")
+
+// Agent bundles everything the router needs to run one agent's loop.
+type Agent struct {
+	// ID identifies the agent (e.g. "main").
+	ID string
+
+	// Provider serves the agent's completions.
+	Provider provider.Provider
+
+	// Tools is the agent's tool registry. Nil means no tools.
+	Tools *tool.Registry
+
+	// Policy holds the approval policies for DM and group contexts.
+	Policy tool.PolicyConfig
+
+	// ApprovalTimeout bounds how long an "ask" approval may wait.
+	// Default: DefaultApprovalTimeout.
+	ApprovalTimeout time.Duration
+
+	// Env is the execution environment passed to tools.
+	Env tool.ExecutionEnv
+
+	// SystemPrompt is prepended to every conversation.
+	SystemPrompt string
+
+	// Prompt, when set, assembles the system prompt of each turn, with
+	// SystemPrompt as the fallback persona. See prompt.DefaultEngine.
+	Prompt *prompt.Engine
+
+	// Skills are the agent's skills, typically loaded with skill.Load from
+	// its workspace. Each turn activates those matching the user message.
+	Skills []skill.Skill
+
+	// Loop configures the reasoning loop guardrails.
+	Loop agent.LoopConfig
+}
+
+// Resolver selects the agent that handles an inbound message.
+type Resolver interface {
+	Resolve(msg *message.InboundMessage) (*Agent, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface.
+type ResolverFunc func(msg *message.InboundMessage) (*Agent, error)
+
+// Resolve calls f(msg).
+func (f ResolverFunc) Resolve(msg *message.InboundMessage) (*Agent, error) {
+	return f(msg)
+}
+
+// StaticResolver returns a Resolver that routes every message to a.
+func StaticResolver(a *Agent) Resolver {
+	return ResolverFunc(func(*message.InboundMessage) (*Agent, error) {
+		if a == nil {
+			return nil, ErrNoAgent
+		}
+		return a, nil
+	})
+}
+
+// PolicyContextFor maps a chat to the tool policy context its messages run
+// under. Only direct messages get the DM policy; groups, broadcasts and
+// unknown chat types fall back to the more restrictive group policy.
+func PolicyContextFor(chat message.Chat) tool.PolicyContext {
+	if chat.IsDirectMessage() {
+		return tool.PolicyContextDM
+	}
+	return tool.PolicyContextGroup
+}
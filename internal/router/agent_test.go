@@ -0,0 +1,46 @@
+package router
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+func TestPolicyContextFor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		chat message.Chat
+		want tool.PolicyContext
+	}{
+		{"dm", message.Chat{Type: message.ChatDM}, tool.PolicyContextDM},
+		{"group", message.Chat{Type: message.ChatGroup}, tool.PolicyContextGroup},
+		{"broadcast", message.Chat{Type: message.ChatBroadcast}, tool.PolicyContextGroup},
+		{"unknown", message.Chat{}, tool.PolicyContextGroup},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := PolicyContextFor(tt.chat); got != tt.want {
+				t.Errorf("PolicyContextFor(%q) = %q, want %q", tt.chat.Type, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticResolver(t *testing.T) {
+	t.Parallel()
+
+	a := &Agent{ID: "main"}
+	got, err := StaticResolver(a).Resolve(&message.InboundMessage{})
+	if err != nil || got != a {
+		t.Errorf("Resolve() = %v, %v; want agent main", got, err)
+	}
+
+	if _, err := StaticResolver(nil).Resolve(&message.InboundMessage{}); !errors.Is(err, ErrNoAgent) {
+		t.Errorf("nil agent: err = %v, want ErrNoAgent", err)
+	}
+}
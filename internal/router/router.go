@@ -0,0 +1,484 @@
+// Package router connects inbound channel messages to agent loops. It keys
+// sessions by channel, chat and thread, serializes turns within a session,
+// resolves the agent in charge, and sends each reply back through the
+// originating channel.
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/agent"
+	"github.com/flemzord/sclaw/internal/hook"
+	"github.com/flemzord/sclaw/internal/memory"
+	"github.com/flemzord/sclaw/internal/metrics"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// DefaultErrorReply is sent to the chat when a turn fails and
+// Config.ErrorReply is empty.
+const DefaultErrorReply = "Sorry, something went wrong while processing your message."
+
+// ErrClosed is returned when a message is submitted to a closed Router.
+var ErrClosed = errors.New("router: closed")
+
+// Sender delivers outbound messages to the channel they are addressed to.
+type Sender interface {
+	Send(ctx context.Context, channel string, msg message.OutboundMessage) error
+}
+
+// SenderFunc adapts a function to the Sender interface.
+type SenderFunc func(ctx context.Context, channel string, msg message.OutboundMessage) error
+
+// Send calls f(ctx, channel, msg).
+func (f SenderFunc) Send(ctx context.Context, channel string, msg message.OutboundMessage) error {
+	return f(ctx, channel, msg)
+}
+
+// Config holds the dependencies of a Router.
+type Config struct {
+	// Resolver selects the agent for each message. Required.
+	Resolver Resolver
+
+	// Sender delivers replies. Required.
+	Sender Sender
+
+	// Approvals returns the approval requester for tools that need user
+	// confirmation, typically bound to the message's chat. When nil, or
+	// when it returns nil, "ask" tools are denied.
+	Approvals func(msg *message.InboundMessage) tool.ApprovalRequester
+
+	// Budgets returns the token budgets charged by the message's turn,
+	// such as per-session or per-day allowances. When nil, only the
+	// agent's LoopConfig budgets apply.
+	Budgets func(msg *message.InboundMessage) []agent.Budget
+
+	// Memories returns the memories to recall for a message, most
+	// relevant first. They are rendered by the agent's Prompt engine.
+	// Optional.
+	Memories func(ctx context.Context, msg *message.InboundMessage) []string
+
+	// Hooks runs on inbound messages, outbound replies, and around the
+	// provider calls and tool executions of each turn. Optional.
+	Hooks *hook.Pipeline
+
+	// Store persists session history so that conversations resume across
+	// restarts and evictions. When nil, history lives in memory only.
+	Store memory.SessionStore
+
+	// ErrorReply is sent to the chat when a turn fails.
+	// Default: DefaultErrorReply.
+	ErrorReply string
+
+	// Metrics records the agent runs, tool executions and approval
+	// requests of each turn. Optional.
+	Metrics *metrics.Registry
+
+	// Logger receives routing logs. When nil, logs are discarded.
+	Logger *slog.Logger
+}
+
+// Router dispatches inbound messages to agent loops, one session at a time.
+type Router struct {
+	resolver   Resolver
+	sender     Sender
+	approvals  func(msg *message.InboundMessage) tool.ApprovalRequester
+	budgets    func(msg *message.InboundMessage) []agent.Budget
+	memories   func(ctx context.Context, msg *message.InboundMessage) []string
+	hooks      *hook.Pipeline
+	store      memory.SessionStore
+	errorReply string
+	metrics    *metrics.Registry
+	logger     *slog.Logger
+
+	// ctx bounds turns started by Enqueue; cancelled by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	sessions map[SessionKey]*session
+	closed   bool
+
+	// now is injectable for testing. Defaults to time.Now.
+	now func() time.Time
+}
+
+// New creates a Router from the given configuration.
+func New(cfg Config) (*Router, error) {
+	if cfg.Resolver == nil {
+		return nil, errors.New("router: resolver is required")
+	}
+	if cfg.Sender == nil {
+		return nil, errors.New("router: sender is required")
+	}
+	if cfg.ErrorReply == "" {
+		cfg.ErrorReply = DefaultErrorReply
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.New(slog.DiscardHandler)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Router{
+		resolver:   cfg.Resolver,
+		sender:     cfg.Sender,
+		approvals:  cfg.Approvals,
+		budgets:    cfg.Budgets,
+		memories:   cfg.Memories,
+		hooks:      cfg.Hooks,
+		store:      cfg.Store,
+		errorReply: cfg.ErrorReply,
+		metrics:    cfg.Metrics,
+		logger:     cfg.Logger.With("component", "router"),
+		ctx:        ctx,
+		cancel:     cancel,
+		sessions:   make(map[SessionKey]*session),
+		now:        time.Now,
+	}, nil
+}
+
+// Handle processes one inbound message synchronously: it runs the agent
+// loop on the session history and sends the reply. Turns for the same
+// session never overlap; concurrent calls wait for each other. A message
+// vetoed by an inbound hook returns an error matching hook.ErrVetoed.
+func (r *Router) Handle(ctx context.Context, msg *message.InboundMessage) error {
+	s, err := r.acquire(KeyFromInbound(msg))
+	if err != nil {
+		return err
+	}
+	defer r.release(s)
+
+	s.turnMu.Lock()
+	defer s.turnMu.Unlock()
+
+	return r.runTurn(ctx, s, msg)
+}
+
+// Enqueue submits a message for asynchronous processing. Messages of the
+// same session are processed in submission order; different sessions are
+// processed concurrently. Failures are logged.
+func (r *Router) Enqueue(msg message.InboundMessage) error {
+	key := KeyFromInbound(&msg)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrClosed
+	}
+	s := r.sessionLocked(key)
+	s.queue = append(s.queue, msg)
+	if !s.draining {
+		s.draining = true
+		r.wg.Add(1)
+		go r.drain(s)
+	}
+	return nil
+}
+
+// drain processes the session queue until it is empty.
+func (r *Router) drain(s *session) {
+	defer r.wg.Done()
+
+	for {
+		r.mu.Lock()
+		if len(s.queue) == 0 {
+			s.draining = false
+			s.lastActive = r.now()
+			r.mu.Unlock()
+			return
+		}
+		msg := s.queue[0]
+		s.queue = s.queue[1:]
+		r.mu.Unlock()
+
+		s.turnMu.Lock()
+		err := r.runTurn(r.ctx, s, &msg)
+		s.turnMu.Unlock()
+
+		if errors.Is(err, hook.ErrVetoed) {
+			r.logger.Info("message vetoed",
+				"session", s.key.String(),
+				"message_id", msg.ID,
+				"reason", err,
+			)
+		} else if err != nil {
+			r.logger.Error("turn failed",
+				"session", s.key.String(),
+				"message_id", msg.ID,
+				"error", err,
+			)
+		}
+	}
+}
+
+// Close stops accepting messages and waits for queued turns to finish.
+// If ctx expires first, in-flight turns are cancelled and ctx.Err() is
+// returned.
+func (r *Router) Close(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		r.cancel()
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// History returns a copy of the in-memory history for the given session.
+// Sessions not yet loaded from the store have no history here.
+func (r *Router) History(key SessionKey) []provider.LLMMessage {
+	r.mu.Lock()
+	s, ok := r.sessions[key]
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	s.turnMu.Lock()
+	defer s.turnMu.Unlock()
+	return slices.Clone(s.history)
+}
+
+// EvictIdle drops sessions with no running or queued turn that have been
+// inactive for longer than maxIdle. It returns the number of evicted sessions.
+// With a Store, an evicted session's history is reloaded on its next message.
+func (r *Router) EvictIdle(maxIdle time.Duration) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := r.now().Add(-maxIdle)
+	var n int
+	for key, s := range r.sessions {
+		if s.idle() && s.lastActive.Before(cutoff) {
+			delete(r.sessions, key)
+			n++
+		}
+	}
+	return n
+}
+
+// acquire returns the session for key, creating it if needed, and marks
+// it active so that it cannot be evicted mid-turn.
+func (r *Router) acquire(key SessionKey) (*session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, ErrClosed
+	}
+	s := r.sessionLocked(key)
+	s.active++
+	return s, nil
+}
+
+// release undoes acquire.
+func (r *Router) release(s *session) {
+	r.mu.Lock()
+	s.active--
+	s.lastActive = r.now()
+	r.mu.Unlock()
+}
+
+// sessionLocked returns the session for key, creating it if needed.
+// Must be called with r.mu held.
+func (r *Router) sessionLocked(key SessionKey) *session {
+	s, ok := r.sessions[key]
+	if !ok {
+		s = newSession(key, r.now())
+		r.sessions[key] = s
+	}
+	return s
+}
+
+// runTurn runs the agent loop for msg and replies. Must be called with
+// s.turnMu held.
+func (r *Router) runTurn(ctx context.Context, s *session, msg *message.InboundMessage) error {
+	ctx = hook.WithInbound(ctx, msg)
+	if err := r.hooks.Run(ctx, &hook.Event{Point: hook.PointInbound, Inbound: msg}); err != nil {
+		return fmt.Errorf("router: inbound message %s: %w", msg.ID, err)
+	}
+
+	a, err := r.resolver.Resolve(msg)
+	if err != nil {
+		return fmt.Errorf("router: resolving agent for %s: %w", s.key, err)
+	}
+
+	if err := r.loadHistory(ctx, s); err != nil {
+		return err
+	}
+
+	userMsg := provider.UserMessageFromInbound(msg)
+	history := append(slices.Clip(s.history), userMsg)
+
+	var tools []provider.ToolDefinition
+	if a.Tools != nil {
+		tools = agent.ToolDefinitions(a.Tools)
+	}
+
+	var budgets []agent.Budget
+	if r.budgets != nil {
+		budgets = r.budgets(msg)
+	}
+
+	var memories []string
+	if r.memories != nil && a.Prompt != nil {
+		memories = r.memories(ctx, msg)
+	}
+
+	resp, err := r.newLoop(a, s, msg).Run(ctx, agent.Request{
+		Messages:     history,
+		SystemPrompt: a.SystemPrompt,
+		Tools:        tools,
+		Budgets:      budgets,
+		Hooks:        r.hooks,
+		Skills:       a.Skills,
+		Prompt:       a.Prompt,
+		Inbound:      msg,
+		Memories:     memories,
+	})
+	if err != nil {
+		r.logger.Warn("agent run failed",
+			"session", s.key.String(),
+			"agent", a.ID,
+			"stop_reason", string(resp.StopReason),
+			"iterations", resp.Iterations,
+			"error", err,
+		)
+		if sendErr := r.reply(ctx, msg, r.errorReply); sendErr != nil {
+			err = errors.Join(err, sendErr)
+		}
+		return fmt.Errorf("router: agent %s: %w", a.ID, err)
+	}
+
+	if resp.History != nil {
+		// The loop compacted the conversation: continue from there. The
+		// store keeps the full transcript.
+		s.history = resp.History
+	} else {
+		s.history = append(history, resp.Messages...)
+	}
+	r.persist(ctx, s, append([]provider.LLMMessage{userMsg}, resp.Messages...))
+
+	r.logger.Debug("turn complete",
+		"session", s.key.String(),
+		"agent", a.ID,
+		"iterations", resp.Iterations,
+		"tool_calls", len(resp.ToolCalls),
+		"total_tokens", resp.TotalUsage.TotalTokens,
+	)
+
+	if resp.Content == "" {
+		return nil
+	}
+	return r.reply(ctx, msg, resp.Content)
+}
+
+// loadHistory fills the session history from the store on the first turn.
+// Must be called with s.turnMu held.
+func (r *Router) loadHistory(ctx context.Context, s *session) error {
+	if r.store == nil || s.loaded {
+		return nil
+	}
+	history, err := r.store.Load(ctx, s.key.String())
+	if err != nil {
+		return fmt.Errorf("router: loading history for %s: %w", s.key, err)
+	}
+	s.history = history
+	s.loaded = true
+	return nil
+}
+
+// persist appends the messages of a completed turn to the store. A failure
+// is logged rather than returned: the reply is still sent and the turn is
+// kept in memory.
+func (r *Router) persist(ctx context.Context, s *session, msgs []provider.LLMMessage) {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.Append(ctx, s.key.String(), msgs...); err != nil {
+		r.logger.Error("persisting turn failed",
+			"session", s.key.String(),
+			"error", err,
+		)
+	}
+}
+
+// newLoop builds an agent loop whose tool executor runs under the policy
+// context of the message's chat.
+func (r *Router) newLoop(a *Agent, s *session, msg *message.InboundMessage) *agent.Loop {
+	reg := a.Tools
+	if reg == nil {
+		reg = tool.NewRegistry()
+	}
+
+	var requester tool.ApprovalRequester
+	if r.approvals != nil {
+		requester = r.approvals(msg)
+	}
+
+	timeout := a.ApprovalTimeout
+	if timeout <= 0 {
+		timeout = DefaultApprovalTimeout
+	}
+
+	executor := agent.NewToolExecutor(agent.ToolExecutorConfig{
+		Registry:        reg,
+		PolicyCfg:       a.Policy,
+		PolicyCtx:       PolicyContextFor(msg.Chat),
+		Elevated:        s.elevated,
+		Requester:       requester,
+		ApprovalTimeout: timeout,
+		Env:             a.Env,
+		Metrics:         r.metrics,
+	})
+	loopCfg := a.Loop
+	if loopCfg.Metrics == nil {
+		loopCfg.Metrics = r.metrics
+	}
+	return agent.NewLoop(a.Provider, executor, loopCfg)
+}
+
+// reply sends text back to the chat the message came from, in the same
+// thread and, unless msg is synthetic, as a reply to it. A reply vetoed by
+// an outbound hook is dropped.
+func (r *Router) reply(ctx context.Context, msg *message.InboundMessage, text string) error {
+	out := message.NewTextMessage(msg.Chat, text)
+	out.ThreadID = msg.ThreadID
+	if !msg.Synthetic {
+		out.ReplyToID = msg.ID
+	}
+
+	if err := r.hooks.Run(ctx, &hook.Event{Point: hook.PointOutbound, Outbound: &out}); err != nil {
+		r.logger.Info("reply vetoed",
+			"message_id", msg.ID,
+			"reason", err,
+		)
+		return nil
+	}
+
+	if err := r.sender.Send(ctx, msg.Channel, out); err != nil {
+		return fmt.Errorf("router: sending reply to %s: %w", msg.Channel, err)
+	}
+	return nil
+}
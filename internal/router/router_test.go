@@ -0,0 +1,616 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/agent"
+	"github.com/flemzord/sclaw/internal/hook"
+	"github.com/flemzord/sclaw/internal/hook/hooktest"
+	"github.com/flemzord/sclaw/internal/memory"
+	"github.com/flemzord/sclaw/internal/prompt"
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/provider/providertest"
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/internal/tool/tooltest"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// sent is one recorded outbound delivery.
+type sent struct {
+	channel string
+	msg     message.OutboundMessage
+}
+
+// recordingSender records every outbound message it is asked to send.
+type recordingSender struct {
+	mu   sync.Mutex
+	msgs []sent
+}
+
+func (s *recordingSender) Send(_ context.Context, channel string, msg message.OutboundMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, sent{channel: channel, msg: msg})
+	return nil
+}
+
+func (s *recordingSender) all() []sent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sent(nil), s.msgs...)
+}
+
+// echoProvider replies with the text of the last user message and
+// records the requests it receives.
+func echoProvider() (*providertest.MockProvider, func() []provider.CompletionRequest) {
+	var mu sync.Mutex
+	var reqs []provider.CompletionRequest
+	p := &providertest.MockProvider{
+		CompleteFunc: func(_ context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+			mu.Lock()
+			reqs = append(reqs, req)
+			mu.Unlock()
+			last := req.Messages[len(req.Messages)-1]
+			return provider.CompletionResponse{Content: "echo: " + last.Content}, nil
+		},
+		ContextWindowSizeFunc: func() int { return 4096 },
+		ModelNameFunc:         func() string { return "echo" },
+	}
+	return p, func() []provider.CompletionRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]provider.CompletionRequest(nil), reqs...)
+	}
+}
+
+func newTestRouter(t *testing.T, a *Agent, sender Sender) *Router {
+	t.Helper()
+	r, err := New(Config{Resolver: StaticResolver(a), Sender: sender})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Close(context.Background()) })
+	return r
+}
+
+func inbound(id, chatID string, chatType message.ChatType, thread, text string) message.InboundMessage {
+	return message.InboundMessage{
+		ID:       id,
+		Channel:  "channel.test",
+		Chat:     message.Chat{ID: chatID, Type: chatType},
+		ThreadID: thread,
+		Blocks:   []message.ContentBlock{message.NewTextBlock(text)},
+	}
+}
+
+func TestNew_RequiresDependencies(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(Config{Sender: &recordingSender{}}); err == nil {
+		t.Error("expected error without resolver")
+	}
+	if _, err := New(Config{Resolver: StaticResolver(&Agent{})}); err == nil {
+		t.Error("expected error without sender")
+	}
+}
+
+func TestHandle_RepliesInThread(t *testing.T) {
+	t.Parallel()
+
+	p, _ := echoProvider()
+	sender := &recordingSender{}
+	r := newTestRouter(t, &Agent{ID: "main", Provider: p}, sender)
+
+	msg := inbound("m1", "c1", message.ChatGroup, "t1", "hello")
+	if err := r.Handle(context.Background(), &msg); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	out := sender.all()
+	if len(out) != 1 {
+		t.Fatalf("expected 1 reply, got %d", len(out))
+	}
+	if out[0].channel != "channel.test" {
+		t.Errorf("channel = %q, want channel.test", out[0].channel)
+	}
+	reply := out[0].msg
+	if reply.ReplyToID != "m1" || reply.ThreadID != "t1" || reply.Chat.ID != "c1" {
+		t.Errorf("reply routing = %+v, want reply to m1 in c1/t1", reply)
+	}
+	if reply.TextContent() != "echo: hello" {
+		t.Errorf("reply text = %q, want %q", reply.TextContent(), "echo: hello")
+	}
+}
+
+func TestHandle_SessionHistory(t *testing.T) {
+	t.Parallel()
+
+	p, recorded := echoProvider()
+	r := newTestRouter(t, &Agent{ID: "main", Provider: p, SystemPrompt: "be brief"}, &recordingSender{})
+
+	first := inbound("m1", "c1", message.ChatDM, "", "one")
+	second := inbound("m2", "c1", message.ChatDM, "", "two")
+	other := inbound("m3", "c1", message.ChatDM, "t9", "elsewhere")
+
+	for _, m := range []*message.InboundMessage{&first, &second, &other} {
+		if err := r.Handle(context.Background(), m); err != nil {
+			t.Fatalf("Handle(%s): %v", m.ID, err)
+		}
+	}
+
+	reqs := recorded()
+	// system + one + echo + two
+	if got := len(reqs[1].Messages); got != 4 {
+		t.Fatalf("second turn sent %d messages, want 4", got)
+	}
+	if reqs[1].Messages[2].Content != "echo: one" {
+		t.Errorf("history[2] = %q, want previous reply", reqs[1].Messages[2].Content)
+	}
+	// A different thread starts a fresh session.
+	if got := len(reqs[2].Messages); got != 2 {
+		t.Errorf("new thread sent %d messages, want 2", got)
+	}
+
+	hist := r.History(SessionKey{Channel: "channel.test", ChatID: "c1"})
+	if len(hist) != 4 {
+		t.Errorf("stored history = %d messages, want 4", len(hist))
+	}
+}
+
+func TestHandle_ResumesFromStore(t *testing.T) {
+	t.Parallel()
+
+	store := memory.NewFileStore(t.TempDir())
+	p, recorded := echoProvider()
+	a := &Agent{ID: "main", Provider: p}
+
+	first, err := New(Config{Resolver: StaticResolver(a), Sender: &recordingSender{}, Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m1 := inbound("m1", "c1", message.ChatDM, "", "one")
+	if err := first.Handle(context.Background(), &m1); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if err := first.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new router, as after a restart, picks up the stored history.
+	second, err := New(Config{Resolver: StaticResolver(a), Sender: &recordingSender{}, Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = second.Close(context.Background()) })
+
+	m2 := inbound("m2", "c1", message.ChatDM, "", "two")
+	if err := second.Handle(context.Background(), &m2); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	reqs := recorded()
+	// one + echo + two
+	if got := len(reqs[1].Messages); got != 3 {
+		t.Fatalf("resumed turn sent %d messages, want 3", got)
+	}
+	if reqs[1].Messages[1].Content != "echo: one" {
+		t.Errorf("history[1] = %q, want previous reply", reqs[1].Messages[1].Content)
+	}
+
+	stored, err := store.Load(context.Background(), KeyFromInbound(&m2).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 4 {
+		t.Errorf("store holds %d messages, want 4", len(stored))
+	}
+}
+
+// failingStore is a memory.SessionStore whose operations fail.
+type failingStore struct{ err error }
+
+func (f failingStore) Append(context.Context, string, ...provider.LLMMessage) error { return f.err }
+
+func (f failingStore) Load(context.Context, string) ([]provider.LLMMessage, error) {
+	return nil, nil
+}
+
+func (f failingStore) List(context.Context) ([]memory.SessionInfo, error) { return nil, f.err }
+
+func (f failingStore) Delete(context.Context, string) error { return f.err }
+
+func TestHandle_StoreFailureStillReplies(t *testing.T) {
+	t.Parallel()
+
+	p, _ := echoProvider()
+	sender := &recordingSender{}
+	r, err := New(Config{
+		Resolver: StaticResolver(&Agent{ID: "main", Provider: p}),
+		Sender:   sender,
+		Store:    failingStore{err: errors.New("disk full")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close(context.Background()) })
+
+	msg := inbound("m1", "c1", message.ChatDM, "", "hi")
+	if err := r.Handle(context.Background(), &msg); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if got := len(sender.all()); got != 1 {
+		t.Errorf("replies = %d, want 1", got)
+	}
+	if hist := r.History(KeyFromInbound(&msg)); len(hist) != 2 {
+		t.Errorf("in-memory history = %d messages, want 2", len(hist))
+	}
+}
+
+// countBudget is an agent.Budget that records what it is charged.
+type countBudget struct {
+	mu    sync.Mutex
+	spent int
+}
+
+func (b *countBudget) Remaining() int { return 1 << 20 }
+
+func (b *countBudget) Spend(usage provider.TokenUsage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent += usage.TotalTokens
+}
+
+func TestHandle_ChargesBudgets(t *testing.T) {
+	t.Parallel()
+
+	p := &providertest.MockProvider{
+		CompleteFunc: func(context.Context, provider.CompletionRequest) (provider.CompletionResponse, error) {
+			return provider.CompletionResponse{Content: "ok", Usage: provider.TokenUsage{TotalTokens: 7}}, nil
+		},
+		ContextWindowSizeFunc: func() int { return 4096 },
+	}
+	budget := &countBudget{}
+	var seen string
+	r, err := New(Config{
+		Resolver: StaticResolver(&Agent{ID: "main", Provider: p}),
+		Sender:   &recordingSender{},
+		Budgets: func(msg *message.InboundMessage) []agent.Budget {
+			seen = KeyFromInbound(msg).String()
+			return []agent.Budget{budget}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close(context.Background()) })
+
+	msg := inbound("m1", "c1", message.ChatDM, "", "hi")
+	if err := r.Handle(context.Background(), &msg); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if seen != "channel.test/c1" {
+		t.Errorf("Budgets called for %q, want channel.test/c1", seen)
+	}
+	if budget.spent != 7 {
+		t.Errorf("spent = %d, want 7", budget.spent)
+	}
+}
+
+func TestHandle_Hooks(t *testing.T) {
+	t.Parallel()
+
+	p, recorded := echoProvider()
+	var providerInbound *message.InboundMessage
+	h := &hooktest.MockHook{
+		On: []hook.Point{hook.PointInbound, hook.PointBeforeProvider, hook.PointOutbound},
+		HandleFunc: func(_ context.Context, ev *hook.Event) error {
+			switch ev.Point {
+			case hook.PointInbound:
+				if ev.Inbound.TextContent() == "blocked" {
+					return hook.Veto("blocked word")
+				}
+				ev.Inbound.Blocks = []message.ContentBlock{message.NewTextBlock("rewritten")}
+			case hook.PointBeforeProvider:
+				providerInbound = ev.Inbound
+			case hook.PointOutbound:
+				ev.Outbound.Blocks = append(ev.Outbound.Blocks, message.NewTextBlock("-- sclaw"))
+			}
+			return nil
+		},
+	}
+	pipeline, err := hook.NewPipeline([]hook.Hook{h}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &recordingSender{}
+	r, err := New(Config{Resolver: StaticResolver(&Agent{ID: "main", Provider: p}), Sender: sender, Hooks: pipeline})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close(context.Background()) })
+
+	blocked := inbound("m1", "c1", message.ChatDM, "", "blocked")
+	if err := r.Handle(context.Background(), &blocked); !errors.Is(err, hook.ErrVetoed) {
+		t.Fatalf("Handle(blocked) = %v, want ErrVetoed", err)
+	}
+	if len(sender.all()) != 0 || len(recorded()) != 0 {
+		t.Fatal("vetoed message reached the provider or got a reply")
+	}
+
+	msg := inbound("m2", "c1", message.ChatDM, "", "hi")
+	if err := r.Handle(context.Background(), &msg); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if got := recorded()[0].Messages; got[len(got)-1].Content != "rewritten" {
+		t.Errorf("provider got %q, want the rewritten message", got[len(got)-1].Content)
+	}
+	if providerInbound != &msg {
+		t.Error("before_provider event did not carry the inbound message")
+	}
+	out := sender.all()
+	if len(out) != 1 || out[0].msg.TextContent() != "echo: rewritten\n-- sclaw" {
+		t.Errorf("replies = %+v, want the hook signature appended", out)
+	}
+}
+
+func TestHandle_PromptEngine(t *testing.T) {
+	t.Parallel()
+
+	p, recorded := echoProvider()
+	engine, err := prompt.NewEngine(
+		prompt.Part{Section: prompt.Conversation()},
+		prompt.Part{Section: prompt.Memories()},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(Config{
+		Resolver: StaticResolver(&Agent{ID: "main", Provider: p, Prompt: engine}),
+		Sender:   &recordingSender{},
+		Memories: func(_ context.Context, msg *message.InboundMessage) []string {
+			return []string{"chat " + msg.Chat.ID + " prefers French"}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close(context.Background()) })
+
+	msg := inbound("m1", "c1", message.ChatDM, "", "hi")
+	if err := r.Handle(context.Background(), &msg); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	system := recorded()[0].Messages[0]
+	if system.Role != provider.MessageRoleSystem ||
+		!strings.Contains(system.Content, "- Chat: dm (id c1)") ||
+		!strings.Contains(system.Content, "- chat c1 prefers French") {
+		t.Errorf("system prompt = %q, want conversation and memories", system.Content)
+	}
+}
+
+func TestHandle_GroupPolicyContext(t *testing.T) {
+	t.Parallel()
+
+	reg := tool.NewRegistry()
+	shell := tooltest.SimpleTool("shell", tool.ApprovalAllow)
+	if err := reg.Register(shell); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	p := &providertest.MockProvider{
+		ContextWindowSizeFunc: func() int { return 4096 },
+		CompleteFunc: func(_ context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+			calls++
+			if calls%2 == 1 {
+				return provider.CompletionResponse{
+					ToolCalls: []provider.ToolCall{{ID: "x", Name: "shell", Arguments: json.RawMessage(`{}`)}},
+				}, nil
+			}
+			return provider.CompletionResponse{Content: req.Messages[len(req.Messages)-1].Content}, nil
+		},
+	}
+	a := &Agent{
+		ID:       "main",
+		Provider: p,
+		Tools:    reg,
+		Policy: tool.PolicyConfig{
+			DM:    tool.Policy{Default: tool.ApprovalAllow},
+			Group: tool.Policy{Deny: []string{"shell"}},
+		},
+	}
+	sender := &recordingSender{}
+	r := newTestRouter(t, a, sender)
+
+	dm := inbound("m1", "dm", message.ChatDM, "", "run")
+	group := inbound("m2", "grp", message.ChatGroup, "", "run")
+	for _, m := range []*message.InboundMessage{&dm, &group} {
+		if err := r.Handle(context.Background(), m); err != nil {
+			t.Fatalf("Handle(%s): %v", m.ID, err)
+		}
+	}
+
+	out := sender.all()
+	if got := out[0].msg.TextContent(); got != "executed: shell" {
+		t.Errorf("dm tool result = %q, want execution", got)
+	}
+	if got := out[1].msg.TextContent(); got == "executed: shell" {
+		t.Error("group tool call should have been denied by policy")
+	}
+	if shell.ExecuteCalls != 1 {
+		t.Errorf("ExecuteCalls = %d, want 1", shell.ExecuteCalls)
+	}
+}
+
+func TestHandle_ProviderErrorSendsErrorReply(t *testing.T) {
+	t.Parallel()
+
+	p := &providertest.MockProvider{
+		ContextWindowSizeFunc: func() int { return 4096 },
+		CompleteFunc: func(context.Context, provider.CompletionRequest) (provider.CompletionResponse, error) {
+			return provider.CompletionResponse{}, provider.ErrProviderDown
+		},
+	}
+	sender := &recordingSender{}
+	r := newTestRouter(t, &Agent{ID: "main", Provider: p}, sender)
+
+	msg := inbound("m1", "c1", message.ChatDM, "", "hi")
+	err := r.Handle(context.Background(), &msg)
+	if !errors.Is(err, provider.ErrProviderDown) {
+		t.Fatalf("expected ErrProviderDown, got %v", err)
+	}
+
+	out := sender.all()
+	if len(out) != 1 || out[0].msg.TextContent() != DefaultErrorReply {
+		t.Errorf("expected default error reply, got %+v", out)
+	}
+	if hist := r.History(KeyFromInbound(&msg)); len(hist) != 0 {
+		t.Errorf("failed turn should not be stored, got %d messages", len(hist))
+	}
+}
+
+func TestHandle_ResolverError(t *testing.T) {
+	t.Parallel()
+
+	r, err := New(Config{
+		Resolver: ResolverFunc(func(*message.InboundMessage) (*Agent, error) { return nil, ErrNoAgent }),
+		Sender:   &recordingSender{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := inbound("m1", "c1", message.ChatDM, "", "hi")
+	if err := r.Handle(context.Background(), &msg); !errors.Is(err, ErrNoAgent) {
+		t.Errorf("expected ErrNoAgent, got %v", err)
+	}
+}
+
+func TestEnqueue_SerializesPerSession(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	running := map[string]int{}
+	maxRunning := 0
+	var order []string
+
+	p := &providertest.MockProvider{
+		ContextWindowSizeFunc: func() int { return 4096 },
+		CompleteFunc: func(_ context.Context, req provider.CompletionRequest) (provider.CompletionResponse, error) {
+			last := req.Messages[len(req.Messages)-1].Content
+			chat := last[:1]
+
+			mu.Lock()
+			running[chat]++
+			if running[chat] > maxRunning {
+				maxRunning = running[chat]
+			}
+			order = append(order, last)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running[chat]--
+			mu.Unlock()
+			return provider.CompletionResponse{Content: last}, nil
+		},
+	}
+	sender := &recordingSender{}
+	r := newTestRouter(t, &Agent{ID: "main", Provider: p}, sender)
+
+	for _, text := range []string{"a1", "b1", "a2", "b2", "a3"} {
+		if err := r.Enqueue(inbound(text, text[:1], message.ChatDM, "", text)); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if maxRunning != 1 {
+		t.Errorf("max concurrent turns per session = %d, want 1", maxRunning)
+	}
+
+	var aOrder []string
+	for _, text := range order {
+		if text[0] == 'a' {
+			aOrder = append(aOrder, text)
+		}
+	}
+	if len(aOrder) != 3 || aOrder[0] != "a1" || aOrder[1] != "a2" || aOrder[2] != "a3" {
+		t.Errorf("session a processed in order %v, want [a1 a2 a3]", aOrder)
+	}
+	if got := len(sender.all()); got != 5 {
+		t.Errorf("replies = %d, want 5", got)
+	}
+
+	if err := r.Enqueue(inbound("late", "a", message.ChatDM, "", "late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Enqueue after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestEvictIdle(t *testing.T) {
+	t.Parallel()
+
+	p, _ := echoProvider()
+	r := newTestRouter(t, &Agent{ID: "main", Provider: p}, &recordingSender{})
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	msg := inbound("m1", "c1", message.ChatDM, "", "hi")
+	if err := r.Handle(context.Background(), &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := r.EvictIdle(time.Hour); n != 0 {
+		t.Errorf("evicted %d fresh sessions, want 0", n)
+	}
+	now = now.Add(2 * time.Hour)
+	if n := r.EvictIdle(time.Hour); n != 1 {
+		t.Errorf("evicted %d idle sessions, want 1", n)
+	}
+	if hist := r.History(KeyFromInbound(&msg)); hist != nil {
+		t.Error("expected evicted session history to be gone")
+	}
+}
+
+func TestHandle_LoopConfigApplied(t *testing.T) {
+	t.Parallel()
+
+	reg := tool.NewRegistry()
+	if err := reg.Register(tooltest.SimpleTool("again", tool.ApprovalAllow)); err != nil {
+		t.Fatal(err)
+	}
+	p := &providertest.MockProvider{
+		ContextWindowSizeFunc: func() int { return 4096 },
+		CompleteFunc: func(context.Context, provider.CompletionRequest) (provider.CompletionResponse, error) {
+			return provider.CompletionResponse{
+				ToolCalls: []provider.ToolCall{{ID: "x", Name: "again", Arguments: json.RawMessage(`{}`)}},
+			}, nil
+		},
+	}
+	a := &Agent{
+		ID:       "main",
+		Provider: p,
+		Tools:    reg,
+		Policy:   tool.PolicyConfig{DM: tool.Policy{Default: tool.ApprovalAllow}},
+		Loop:     agent.LoopConfig{MaxIterations: 2, LoopThreshold: 10},
+	}
+	r := newTestRouter(t, a, &recordingSender{})
+
+	msg := inbound("m1", "c1", message.ChatDM, "", "go")
+	if err := r.Handle(context.Background(), &msg); !errors.Is(err, agent.ErrMaxIterationsReached) {
+		t.Errorf("expected ErrMaxIterationsReached, got %v", err)
+	}
+	if p.CompleteCalls != 2 {
+		t.Errorf("CompleteCalls = %d, want 2", p.CompleteCalls)
+	}
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flemzord/sclaw/internal/provider"
+	"github.com/flemzord/sclaw/internal/tool"
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+// SessionKey identifies a conversation. Messages from the same channel,
+// chat and thread share one session and therefore one history.
+type SessionKey struct {
+	Channel  string
+	ChatID   string
+	ThreadID string
+}
+
+// KeyFromInbound derives the session key of an inbound message.
+func KeyFromInbound(msg *message.InboundMessage) SessionKey {
+	return SessionKey{
+		Channel:  msg.Channel,
+		ChatID:   msg.Chat.ID,
+		ThreadID: msg.ThreadID,
+	}
+}
+
+// String returns a stable textual form of the key, suitable for logging.
+// Format: "channel/chat" or "channel/chat/thread".
+func (k SessionKey) String() string {
+	s := k.Channel + "/" + k.ChatID
+	if k.ThreadID != "" {
+		s += "/" + k.ThreadID
+	}
+	return s
+}
+
+// session holds the state of one conversation.
+type session struct {
+	key SessionKey
+
+	// turnMu serializes agent turns: only one message per session is
+	// processed at a time. It also guards history and loaded.
+	turnMu  sync.Mutex
+	history []provider.LLMMessage
+
+	// loaded reports whether history was read from the store.
+	loaded bool
+
+	// elevated is the per-session elevated mode shared by all turns.
+	elevated *tool.ElevatedState
+
+	// The fields below are guarded by Router.mu.
+	queue      []message.InboundMessage
+	draining   bool
+	active     int
+	lastActive time.Time
+}
+
+func newSession(key SessionKey, now time.Time) *session {
+	return &session{
+		key:        key,
+		elevated:   tool.NewElevatedState(),
+		lastActive: now,
+	}
+}
+
+// idle reports whether the session has no queued or running turn.
+// Must be called with Router.mu held.
+func (s *session) idle() bool {
+	return s.active == 0 && !s.draining && len(s.queue) == 0
+}
@@ -0,0 +1,26 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/flemzord/sclaw/pkg/message"
+)
+
+func TestSessionKey(t *testing.T) {
+	t.Parallel()
+
+	msg := &message.InboundMessage{
+		Channel:  "channel.telegram",
+		Chat:     message.Chat{ID: "42"},
+		ThreadID: "7",
+	}
+	key := KeyFromInbound(msg)
+	if key.String() != "channel.telegram/42/7" {
+		t.Errorf("String() = %q", key.String())
+	}
+
+	msg.ThreadID = ""
+	if got := KeyFromInbound(msg).String(); got != "channel.telegram/42" {
+		t.Errorf("String() without thread = %q", got)
+	}
+}
@@ -0,0 +1,224 @@
+// Package skill loads Markdown skills: instruction files that the agent
+// follows when a conversation calls for them. A skill is a Markdown file
+// with a YAML front-matter block:
+//
+//	---
+//	name: code-review
+//	description: Review a diff for bugs and style issues
+//	tools: [read_file, grep]
+//	triggers: ["review", "code review"]
+//	---
+//	When reviewing code, ...
+//
+// The body holds the instructions added to the system prompt while the
+// skill is active.
+package skill
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dir is the directory of the workspace that holds skill files.
+const Dir = "skills"
+
+// indexFile is the skill file inside a per-skill directory.
+const indexFile = "SKILL.md"
+
+// frontMatterDelim opens and closes the front-matter block.
+const frontMatterDelim = "---"
+
+// Skill is a set of instructions for a kind of task.
+type Skill struct {
+	// Name identifies the skill. Users activate it explicitly with
+	// "/name". Defaults to the file name without extension, or to the
+	// directory name for SKILL.md files.
+	Name string `yaml:"name"`
+
+	// Description summarizes what the skill is for.
+	Description string `yaml:"description"`
+
+	// Tools lists the tools the skill needs. While a skill that declares
+	// tools is active, the agent can only use the tools declared by active
+	// skills. Empty means the skill does not restrict tools.
+	Tools []string `yaml:"tools"`
+
+	// Triggers are phrases that activate the skill when a user message
+	// contains one of them, ignoring case.
+	Triggers []string `yaml:"triggers"`
+
+	// Instructions is the Markdown body of the file.
+	Instructions string `yaml:"-"`
+
+	// Path is the file the skill was loaded from.
+	Path string `yaml:"-"`
+}
+
+// Parse parses a skill file. The front-matter block is optional; without
+// it, the whole file is the instructions and name must be set by the
+// caller.
+func Parse(data []byte) (Skill, error) {
+	var s Skill
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	if rest, ok := strings.CutPrefix(text, frontMatterDelim+"\n"); ok {
+		header, body, found := cutFrontMatter(rest)
+		if !found {
+			return Skill{}, errors.New("skill: unterminated front-matter")
+		}
+		if err := yaml.Unmarshal([]byte(header), &s); err != nil {
+			return Skill{}, fmt.Errorf("skill: parsing front-matter: %w", err)
+		}
+		text = body
+	}
+
+	s.Name = strings.TrimSpace(s.Name)
+	s.Instructions = strings.TrimSpace(text)
+	return s, nil
+}
+
+// cutFrontMatter splits text after the opening delimiter at the closing
+// delimiter line.
+func cutFrontMatter(text string) (header, body string, found bool) {
+	if rest, ok := strings.CutPrefix(text, frontMatterDelim+"\n"); ok {
+		return "", rest, true
+	}
+	if text == frontMatterDelim {
+		return "", "", true
+	}
+	header, body, found = strings.Cut(text, "\n"+frontMatterDelim+"\n")
+	if !found {
+		header, found = strings.CutSuffix(text, "\n"+frontMatterDelim)
+	}
+	return header, body, found
+}
+
+// Load discovers the skills of a workspace: every *.md file in its
+// skills directory, and every skills/<name>/SKILL.md file. A workspace
+// without a skills directory has no skills. Skills are sorted by name;
+// two skills with the same name are an error.
+func Load(workspace string) ([]Skill, error) {
+	root := filepath.Join(workspace, Dir)
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("skill: reading %s: %w", root, err)
+	}
+
+	var skills []Skill
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		path := filepath.Join(root, entry.Name())
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		switch {
+		case entry.IsDir():
+			path = filepath.Join(path, indexFile)
+			name = entry.Name()
+			if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+		case !strings.EqualFold(filepath.Ext(entry.Name()), ".md"):
+			continue
+		}
+
+		s, err := loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if s.Name == "" {
+			s.Name = name
+		}
+		if prev, dup := seen[s.Name]; dup {
+			return nil, fmt.Errorf("skill: %q defined in both %s and %s", s.Name, prev, path)
+		}
+		seen[s.Name] = path
+		skills = append(skills, s)
+	}
+
+	slices.SortFunc(skills, func(a, b Skill) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return skills, nil
+}
+
+func loadFile(path string) (Skill, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Skill{}, fmt.Errorf("skill: reading %s: %w", path, err)
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return Skill{}, fmt.Errorf("%s: %w", path, err)
+	}
+	s.Path = path
+	return s, nil
+}
+
+// Matches reports whether text activates the skill: it starts with the
+// "/name" command or contains one of the triggers, ignoring case.
+func (s *Skill) Matches(text string) bool {
+	if cmd, _, _ := strings.Cut(strings.TrimSpace(text), " "); strings.EqualFold(cmd, "/"+s.Name) {
+		return true
+	}
+	lower := strings.ToLower(text)
+	for _, trigger := range s.Triggers {
+		if trigger = strings.TrimSpace(trigger); trigger != "" && strings.Contains(lower, strings.ToLower(trigger)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the skills activated by text, in order.
+func Select(skills []Skill, text string) []Skill {
+	var active []Skill
+	for _, s := range skills {
+		if s.Matches(text) {
+			active = append(active, s)
+		}
+	}
+	return active
+}
+
+// Instructions renders the instructions of active skills for the system
+// prompt.
+func Instructions(active []Skill) string {
+	var b strings.Builder
+	for i, s := range active {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "## Skill: %s\n", s.Name)
+		if s.Description != "" {
+			fmt.Fprintf(&b, "%s\n", s.Description)
+		}
+		if s.Instructions != "" {
+			fmt.Fprintf(&b, "\n%s", s.Instructions)
+		}
+	}
+	return b.String()
+}
+
+// AllowedTools returns the tools the active skills restrict the agent
+// to, or nil when none of them declares tools.
+func AllowedTools(active []Skill) []string {
+	var tools []string
+	for _, s := range active {
+		for _, t := range s.Tools {
+			if !slices.Contains(tools, t) {
+				tools = append(tools, t)
+			}
+		}
+	}
+	return tools
+}
@@ -0,0 +1,181 @@
+package skill
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		src  string
+		want Skill
+	}{
+		{
+			name: "front-matter",
+			src: "---\nname: review\ndescription: Review code\ntools: [read, grep]\ntriggers: [\"code review\"]\n---\n\n" +
+				"Look for bugs.\n",
+			want: Skill{
+				Name:         "review",
+				Description:  "Review code",
+				Tools:        []string{"read", "grep"},
+				Triggers:     []string{"code review"},
+				Instructions: "Look for bugs.",
+			},
+		},
+		{
+			name: "no front-matter",
+			src:  "# Notes\nBe brief.",
+			want: Skill{Instructions: "# Notes\nBe brief."},
+		},
+		{
+			name: "crlf and empty body",
+			src:  "---\r\nname: x\r\n---",
+			want: Skill{Name: "x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Parse([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got.Name != tt.want.Name || got.Description != tt.want.Description ||
+				got.Instructions != tt.want.Instructions ||
+				!slices.Equal(got.Tools, tt.want.Tools) || !slices.Equal(got.Triggers, tt.want.Triggers) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	t.Parallel()
+
+	for _, src := range []string{
+		"---\nname: x\nno end",
+		"---\nname: [unclosed\n---\nbody",
+	} {
+		if _, err := Parse([]byte(src)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", src)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	ws := t.TempDir()
+	writeFile(t, filepath.Join(ws, Dir, "weather.md"), "Use the forecast tool.")
+	writeFile(t, filepath.Join(ws, Dir, "research", "SKILL.md"), "---\ndescription: Dig deep\n---\nCite sources.")
+	writeFile(t, filepath.Join(ws, Dir, "notes.txt"), "ignored")
+	writeFile(t, filepath.Join(ws, Dir, "empty", "README.md"), "ignored")
+
+	skills, err := Load(ws)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var names []string
+	for _, s := range skills {
+		names = append(names, s.Name)
+	}
+	if !slices.Equal(names, []string{"research", "weather"}) {
+		t.Fatalf("names = %v, want [research weather]", names)
+	}
+	if skills[0].Description != "Dig deep" || skills[0].Instructions != "Cite sources." {
+		t.Errorf("research = %+v", skills[0])
+	}
+	if skills[1].Path != filepath.Join(ws, Dir, "weather.md") {
+		t.Errorf("Path = %q", skills[1].Path)
+	}
+}
+
+func TestLoad_NoSkillsDir(t *testing.T) {
+	t.Parallel()
+
+	skills, err := Load(t.TempDir())
+	if err != nil || skills != nil {
+		t.Errorf("Load = %v, %v; want nil, nil", skills, err)
+	}
+}
+
+func TestLoad_DuplicateName(t *testing.T) {
+	t.Parallel()
+
+	ws := t.TempDir()
+	writeFile(t, filepath.Join(ws, Dir, "a.md"), "---\nname: same\n---\n")
+	writeFile(t, filepath.Join(ws, Dir, "b.md"), "---\nname: same\n---\n")
+
+	_, err := Load(ws)
+	if err == nil || !strings.Contains(err.Error(), `"same"`) {
+		t.Errorf("Load = %v, want duplicate name error", err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	skills := []Skill{
+		{Name: "review", Triggers: []string{"Code Review", "PR"}, Tools: []string{"read", "grep"}},
+		{Name: "weather", Tools: []string{"forecast", "read"}},
+		{Name: "tone", Triggers: []string{"polite"}},
+	}
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"please do a code review", []string{"review"}},
+		{"/weather in Paris", []string{"weather"}},
+		{"/WEATHER", []string{"weather"}},
+		{"be polite and check this pr", []string{"review", "tone"}},
+		{"what about /weather?", nil},
+		{"hello", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, s := range Select(skills, tt.text) {
+			got = append(got, s.Name)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Select(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+
+	if got := AllowedTools(skills[:2]); !slices.Equal(got, []string{"read", "grep", "forecast"}) {
+		t.Errorf("AllowedTools = %v", got)
+	}
+	if got := AllowedTools(skills[2:]); got != nil {
+		t.Errorf("AllowedTools without declared tools = %v, want nil", got)
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	t.Parallel()
+
+	got := Instructions([]Skill{
+		{Name: "a", Description: "First", Instructions: "Do A."},
+		{Name: "b", Instructions: "Do B."},
+	})
+	want := "## Skill: a\nFirst\n\nDo A.\n\n## Skill: b\n\nDo B."
+	if got != want {
+		t.Errorf("Instructions = %q, want %q", got, want)
+	}
+}
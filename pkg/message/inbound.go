@@ -17,6 +17,11 @@ type InboundMessage struct {
 	Blocks    []ContentBlock  `json:"blocks"`
 	Mentions  *Mentions       `json:"mentions,omitempty"`
 	Raw       json.RawMessage `json:"raw,omitempty"`
+
+	// Synthetic marks a message made up by sclaw itself, such as a
+	// scheduled prompt, rather than received from the platform. Its ID
+	// names no platform message, so it cannot be replied to.
+	Synthetic bool `json:"synthetic,omitempty"`
 }
 
 // MarshalJSON implements json.Marshaler. It normalizes empty Mentions to nil